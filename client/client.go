@@ -0,0 +1,302 @@
+// Package client calls the user service over its HTTP API. New returns the Service interface of
+// the server, so code written against it runs remotely; the types of its methods are re-exported
+// here, as the server keeps them in internal packages.
+package client
+
+import (
+	"context"
+	"net/http"
+	"testgenerate_backend_user/internal"
+	"testgenerate_backend_user/internal/app"
+	"time"
+)
+
+type (
+	Service   = internal.Service
+	Endpoints = internal.Endpoints
+	Option    = internal.ClientOption
+
+	User               = app.User
+	UserFilter         = app.UserFilter
+	StatusChange       = app.StatusChange
+	Role               = app.Role
+	GroupRole          = app.GroupRole
+	Group              = app.Group
+	Invitation         = app.Invitation
+	Organization       = app.Organization
+	RoleAssignment     = app.RoleAssignment
+	DesiredRoles       = app.DesiredRoles
+	RolePlan           = app.RolePlan
+	RoleChange         = app.RoleChange
+	UserRecord         = app.UserRecord
+	ImportOptions      = app.ImportOptions
+	ImportReport       = app.ImportReport
+	ImportRow          = app.ImportRow
+	UserBatch          = app.UserBatch
+	UserOperation      = app.UserOperation
+	BatchReport        = app.BatchReport
+	BatchResult        = app.BatchResult
+	UserPatch          = app.UserPatch
+	JSONPatchOperation = app.JSONPatchOperation
+	AuditEvent         = app.AuditEvent
+	AuditFilter        = app.AuditFilter
+
+	ValidationError = internal.ValidationError
+	FieldError      = internal.FieldError
+)
+
+const (
+	UserStatusActive   = app.UserStatusActive
+	UserStatusDisabled = app.UserStatusDisabled
+	UserStatusLocked   = app.UserStatusLocked
+)
+
+// Errors of the server, decoded from its responses. Test them with errors.Is.
+var (
+	ErrNotFound              = internal.ErrNotFound
+	ErrAlreadyExists         = internal.ErrAlreadyExists
+	ErrInconsistentIDs       = internal.ErrInconsistentIDs
+	ErrForbidden             = internal.ErrForbidden
+	ErrPreconditionRequired  = internal.ErrPreconditionRequired
+	ErrInvalidArgument       = internal.ErrInvalidArgument
+	ErrInvitationInvalid     = internal.ErrInvitationInvalid
+	ErrAccountDisabled       = internal.ErrAccountDisabled
+	ErrLastAdministrator     = internal.ErrLastAdministrator
+	ErrIdempotencyKeyReused  = internal.ErrIdempotencyKeyReused
+	ErrValidation            = internal.ErrValidation
+	ErrRequestTooLarge       = internal.ErrRequestTooLarge
+	ErrIdempotencyInProgress = internal.ErrIdempotencyInProgress
+
+	// ErrNotSupported is returned by the maintenance jobs and CountUsersByStatus of Service,
+	// which run in the server only.
+	ErrNotSupported = internal.ErrNotSupported
+)
+
+// Token sends the token as the bearer token of calls that carry none in their context, see WithToken.
+func Token(token string) Option { return internal.ClientToken(token) }
+
+// Tenant sends X-Tenant-ID, for superadmins working in one organization.
+func Tenant(id int) Option { return internal.ClientTenant(id) }
+
+// Timeout limits a call including its retries, 10 seconds by default.
+func Timeout(timeout time.Duration) Option { return internal.ClientTimeout(timeout) }
+
+// Retries sets how often a call is repeated after failures of the network or the server, 2 times
+// by default. The first retry waits backoff, each further one twice as long as the one before.
+func Retries(retries int, backoff time.Duration) Option {
+	return internal.ClientRetries(retries, backoff)
+}
+
+// HTTPClient sets the client that sends the requests, http.DefaultClient by default.
+func HTTPClient(client *http.Client) Option { return internal.ClientHTTPClient(client) }
+
+// WithToken sends the token with the calls of ctx instead of the one of Token,
+// so a service can call on behalf of its own caller.
+func WithToken(ctx context.Context, token string) context.Context {
+	return internal.WithClientToken(ctx, token)
+}
+
+// MakeClientEndpoints returns the endpoints of the server at baseURL, e.g. "http://users:8091".
+func MakeClientEndpoints(baseURL string, options ...Option) (Endpoints, error) {
+	return internal.MakeClientEndpoints(baseURL, options...)
+}
+
+// New returns the Service of the server at baseURL. The server acts as the caller of the token:
+// actor arguments are ignored, GetUser and RecordLogin work on the user of the token.
+func New(baseURL string, options ...Option) (Service, error) {
+	e, err := MakeClientEndpoints(baseURL, options...)
+	if err != nil {
+		return nil, err
+	}
+	return service{e}, nil
+}
+
+type service struct {
+	e Endpoints
+}
+
+func (s service) GetRoles(ctx context.Context) ([]Role, error) {
+	return s.e.GetRoles(ctx, "", "")
+}
+
+func (s service) GetRolePermissions(ctx context.Context, roleID int) ([]string, error) {
+	return s.e.GetRolePermissions(ctx, roleID)
+}
+
+func (s service) UpdateRole(ctx context.Context, role Role, actor string) error {
+	return s.e.PutRole(ctx, role, actor)
+}
+
+func (s service) GetUser(ctx context.Context, userName, userRole string) (User, error) {
+	return s.e.GetUser(ctx, userName, userRole)
+}
+
+func (s service) GetUsersRole(ctx context.Context, filter UserFilter) ([]User, error) {
+	return s.e.GetUsersRole(ctx, filter)
+}
+
+// StreamUsers emits the users of one listing, which is read as a whole.
+func (s service) StreamUsers(ctx context.Context, filter UserFilter, emit func(User) error) error {
+	users, err := s.e.GetUsersRole(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err = emit(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s service) AddUser(ctx context.Context, userAdd User) error {
+	return s.e.PostUser(ctx, userAdd)
+}
+
+func (s service) UpdateUser(ctx context.Context, user User) error {
+	return s.e.PutUser(ctx, user)
+}
+
+func (s service) PatchUser(ctx context.Context, userName string, patch UserPatch) (User, error) {
+	return s.e.PatchUser(ctx, userName, patch)
+}
+
+func (s service) DeleteUser(ctx context.Context, userName string) error {
+	return s.e.DeleteUser(ctx, userName)
+}
+
+func (s service) RestoreUser(ctx context.Context, userName string) error {
+	return s.e.PostRestore(ctx, userName)
+}
+
+func (s service) RenameUser(ctx context.Context, userName, newName, actor string) (User, error) {
+	return s.e.PostRename(ctx, userName, newName, actor)
+}
+
+func (s service) GetGroups(ctx context.Context) ([]Group, error) {
+	return s.e.GetGroups(ctx)
+}
+
+func (s service) CreateGroup(ctx context.Context, group Group, actor string) (Group, error) {
+	return s.e.PostGroup(ctx, group, actor)
+}
+
+func (s service) UpdateGroup(ctx context.Context, group Group, actor string) error {
+	return s.e.PutGroup(ctx, group, actor)
+}
+
+func (s service) DeleteGroup(ctx context.Context, groupID int, actor string) error {
+	return s.e.DeleteGroup(ctx, groupID, actor)
+}
+
+func (s service) AddGroupMember(ctx context.Context, groupID int, userName, actor string) error {
+	return s.e.PostGroupMember(ctx, groupID, userName, actor)
+}
+
+func (s service) RemoveGroupMember(ctx context.Context, groupID int, userName, actor string) error {
+	return s.e.DeleteGroupMember(ctx, groupID, userName, actor)
+}
+
+func (s service) AddGroupManager(ctx context.Context, groupID int, userName, actor string) error {
+	return s.e.PostGroupManager(ctx, groupID, userName, actor)
+}
+
+func (s service) RemoveGroupManager(ctx context.Context, groupID int, userName, actor string) error {
+	return s.e.DeleteGroupManager(ctx, groupID, userName, actor)
+}
+
+func (s service) GrantGroupRole(ctx context.Context, groupID int, role Role, actor string) error {
+	return s.e.PostGroupRole(ctx, groupID, role, actor)
+}
+
+func (s service) RevokeGroupRole(ctx context.Context, groupID, roleID int, actor string) error {
+	return s.e.DeleteGroupRole(ctx, groupID, roleID, actor)
+}
+
+func (s service) GrantRole(ctx context.Context, userName string, role Role, actor string) error {
+	return s.e.PostUserRole(ctx, userName, role, actor)
+}
+
+func (s service) RevokeRole(ctx context.Context, userName string, roleID int, actor string) error {
+	return s.e.DeleteUserRole(ctx, userName, roleID, actor)
+}
+
+func (s service) ImportUsers(ctx context.Context, records []UserRecord, opts ImportOptions, actor string) (ImportReport, error) {
+	return s.e.ImportUsers(ctx, records, opts, actor)
+}
+
+func (s service) ExportUsers(ctx context.Context) ([]UserRecord, error) {
+	return s.e.ExportUsers(ctx)
+}
+
+func (s service) BatchUsers(ctx context.Context, batch UserBatch, actor string) (BatchReport, error) {
+	return s.e.BatchUsers(ctx, batch, actor)
+}
+
+func (s service) PlanRoles(ctx context.Context, desired DesiredRoles) (RolePlan, error) {
+	return s.e.PlanRoles(ctx, desired)
+}
+
+func (s service) ApplyRoles(ctx context.Context, desired DesiredRoles, actor string) (RolePlan, error) {
+	return s.e.ApplyRoles(ctx, desired, actor)
+}
+
+func (s service) GetRoleExpirations(ctx context.Context, within time.Duration, filter UserFilter) ([]User, error) {
+	return s.e.GetRoleExpirations(ctx, within, filter)
+}
+
+func (s service) ExpireRoleGrants(context.Context) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (s service) PurgeDeletedUsers(context.Context) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (s service) RecordLogin(ctx context.Context, userName string) (User, error) {
+	return s.e.PostLogin(ctx, userName)
+}
+
+func (s service) SetUserStatus(ctx context.Context, userName string, change StatusChange) error {
+	return s.e.PostUserStatus(ctx, userName, change)
+}
+
+func (s service) UnlockExpiredAccounts(context.Context) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (s service) CountUsersByStatus(context.Context) (map[string]int, error) {
+	return nil, ErrNotSupported
+}
+
+func (s service) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	return s.e.GetAuditEvents(ctx, filter)
+}
+
+func (s service) CreateInvitation(ctx context.Context, invitation Invitation, createdBy string) (Invitation, error) {
+	return s.e.PostInvitation(ctx, invitation, createdBy)
+}
+
+func (s service) GetInvitations(ctx context.Context) ([]Invitation, error) {
+	return s.e.GetInvitations(ctx)
+}
+
+func (s service) RevokeInvitation(ctx context.Context, id int) error {
+	return s.e.DeleteInvitation(ctx, id)
+}
+
+func (s service) RedeemInvitation(ctx context.Context, code string, userAdd User) error {
+	return s.e.RedeemInvitation(ctx, code, userAdd)
+}
+
+func (s service) CleanupInvitations(context.Context) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (s service) GetOrganizations(ctx context.Context) ([]Organization, error) {
+	return s.e.GetOrganizations(ctx)
+}
+
+func (s service) CreateOrganization(ctx context.Context, org Organization, actor string) (Organization, error) {
+	return s.e.PostOrganization(ctx, org, actor)
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	var calls []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/user":
+			_, _ = w.Write([]byte(`{"user":{"user_name":"alice","role_name":"editor","role_id":2}}`))
+		case "/v1/user/bob":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"DeleteUser: user bob: not found"}`))
+		case "/v1/roles":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":"role is not administrator"}`))
+		case "/v1/user/bob/enable":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"error":"validation failed","fields":[{"field":"reason","reason":"is too long"}]}`))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := New(srv.URL, Token("t"), Tenant(3), Retries(0, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	user, err := s.GetUser(ctx, "", "")
+	if err != nil || user.Name != "alice" || user.Role != "editor" || user.RoleID != 2 {
+		t.Errorf("GetUser: %+v, %v", user, err)
+	}
+	if got := calls[0].Header.Get("Authorization"); got != "Bearer t" {
+		t.Errorf("Authorization %q, want the token of Token", got)
+	}
+	if got := calls[0].Header.Get("X-Tenant-ID"); got != "3" {
+		t.Errorf("X-Tenant-ID %q, want 3", got)
+	}
+	if _, err = s.GetUser(WithToken(ctx, "other"), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls[1].Header.Get("Authorization"); got != "Bearer other" {
+		t.Errorf("Authorization %q, want the token of WithToken", got)
+	}
+
+	// Errors of the server are the re-exported errors
+	if err = s.DeleteUser(ctx, "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteUser: %v, want ErrNotFound", err)
+	}
+	if _, err = s.GetRoles(ctx); !errors.Is(err, ErrForbidden) {
+		t.Errorf("GetRoles: %v, want ErrForbidden", err)
+	}
+	err = s.SetUserStatus(ctx, "bob", StatusChange{Status: UserStatusActive})
+	var invalid *ValidationError
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &invalid) ||
+		len(invalid.Fields) != 1 || invalid.Fields[0] != (FieldError{Field: "reason", Reason: "is too long"}) {
+		t.Errorf("SetUserStatus: %v, want a ValidationError of reason", err)
+	}
+}
+
+func TestNotSupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s %s", r.Method, r.URL)
+	}))
+	defer srv.Close()
+	s, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		method string
+		call   func() error
+	}{
+		{"ExpireRoleGrants", func() error { _, err := s.ExpireRoleGrants(ctx); return err }},
+		{"PurgeDeletedUsers", func() error { _, err := s.PurgeDeletedUsers(ctx); return err }},
+		{"UnlockExpiredAccounts", func() error { _, err := s.UnlockExpiredAccounts(ctx); return err }},
+		{"CleanupInvitations", func() error { _, err := s.CleanupInvitations(ctx); return err }},
+		{"CountUsersByStatus", func() error { _, err := s.CountUsersByStatus(ctx); return err }},
+	} {
+		if err := tc.call(); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("%s: %v, want ErrNotSupported", tc.method, err)
+		}
+	}
+}
+
+func TestNewInvalidURL(t *testing.T) {
+	if _, err := New("http://users:port"); err == nil {
+		t.Error("no error for an invalid URL")
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"github.com/sirupsen/logrus"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -43,7 +45,29 @@ func main() {
 		Formatter: &logrus.JSONFormatter{},
 	}
 
+	//Organizations are kept apart by row-level security, which superusers bypass
+	if err := internal.CheckDatabaseRole(context.Background()); err != nil {
+		logger.Fatal(err)
+	}
+
+	//Subcommands work on the database directly instead of starting the server
+	commands := map[string]func([]string, *logrus.Logger, io.Writer) error{
+		"reconcile": runReconcile,
+		"import":    runImport,
+		"export":    runExport,
+	}
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:], &logger, os.Stdout); err != nil {
+				logger.Error(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	port := app.GetEnv("LISTEN_PORT", ":8091")
+	grpcPort := app.GetEnv("GRPC_LISTEN_PORT", ":9090")
 
 	fieldKeys := []string{"method", "error"}
 	requestCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
@@ -59,15 +83,40 @@ func main() {
 		Help:      "Total duration of requests in microseconds",
 	}, fieldKeys)
 
-	unitLog := internal.NewUnitLogHandler(logger)
+	grpcFieldKeys := []string{"method", "code"}
+	grpcRequestCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "api_test_generate",
+		Subsystem: "user",
+		Name:      "grpc_request_count",
+		Help:      "Number of gRPC requests received.",
+	}, grpcFieldKeys)
+	grpcRequestLatency := kitprometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
+		Namespace: "api_test_generate",
+		Subsystem: "user",
+		Name:      "grpc_request_latency_seconds",
+		Help:      "Total duration of gRPC requests in seconds",
+	}, grpcFieldKeys)
+	accounts := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "api_test_generate",
+		Subsystem: "user",
+		Name:      "accounts",
+		Help:      "Number of user accounts by status.",
+	}, []string{"status"})
+
+	unitLog := internal.NewUnitLogHandler(&logger)
 
 	var (
 		s = internal.NewService(&logger, requestCount, requestLatency)
 	)
 
+	idempotencyKeys, err := internal.NewIdempotencyStore(app.GetEnv("IDEMPOTENCY_STORE", "postgres"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	var h http.Handler
 	{
-		h = internal.MakeHTTPHandler(s, *unitLog)
+		h = internal.MakeHTTPHandler(s, *unitLog, idempotencyKeys)
 	}
 
 	srv := &http.Server{
@@ -75,6 +124,12 @@ func main() {
 		Handler: h,
 	}
 
+	grpcSrv := internal.MakeGRPCServer(s, *unitLog, grpcRequestCount, grpcRequestLatency)
+	grpcListener, err := net.Listen("tcp", grpcPort)
+	if err != nil {
+		logger.Fatal("Error during gRPC Listen(). ", err)
+	}
+
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
@@ -85,11 +140,46 @@ func main() {
 		}
 	}()
 
+	go func() {
+		if err := grpcSrv.Serve(grpcListener); err != nil {
+			logger.Fatal("Error during gRPC Serve(). ", err)
+		}
+	}()
+
+	//Background jobs maintain the rows of every organization
+	jobCtx, stopJobs := context.WithCancel(app.WithAllTenants(context.Background()))
+	defer stopJobs()
+	go internal.RunPeriodic(jobCtx, time.Duration(app.GetEnvAsInt("INVITATION_CLEANUP_MINUTES", 60))*time.Minute,
+		func(ctx context.Context) {
+			_, _ = s.CleanupInvitations(ctx)
+		})
+	go internal.RunPeriodic(jobCtx, time.Duration(app.GetEnvAsInt("USER_PURGE_MINUTES", 60))*time.Minute,
+		func(ctx context.Context) {
+			_, _ = s.PurgeDeletedUsers(ctx)
+		})
+	go internal.RunPeriodic(jobCtx, time.Duration(app.GetEnvAsInt("IDEMPOTENCY_CLEANUP_MINUTES", 60))*time.Minute,
+		func(ctx context.Context) {
+			_, _ = idempotencyKeys.Purge(ctx)
+		})
+	go internal.RunPeriodic(jobCtx, time.Duration(app.GetEnvAsInt("ACCOUNT_STATUS_SECONDS", 60))*time.Second,
+		func(ctx context.Context) {
+			_, _ = s.ExpireRoleGrants(ctx)
+			_, _ = s.UnlockExpiredAccounts(ctx)
+			counts, err := s.CountUsersByStatus(ctx)
+			if err != nil {
+				return
+			}
+			for status, count := range counts {
+				accounts.With("status", status).Set(float64(count))
+			}
+		})
+
 	time.Sleep(time.Second * 1)
-	logger.Info("Start service. Listen HTTP port=", port)
+	logger.Info("Start service. Listen HTTP port=", port, " gRPC port=", grpcPort)
 
 	<-done
 	logger.Info("Server stopped. Signal ")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer func() {
@@ -99,5 +189,6 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("Server Exited error = ", err.Error())
 	}
+	grpcSrv.GracefulStop()
 	logger.Info("Server Exited Properly")
 }
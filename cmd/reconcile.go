@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"testgenerate_backend_user/internal"
+	"testgenerate_backend_user/internal/app"
+)
+
+// runReconcile implements "reconcile -file roles.json [-apply] [-ignore-unlisted] [-tenant 2]".
+// It prints the plan for the desired role document and executes it with -apply.
+// The command works on the database directly with the DB_* settings of the service.
+func runReconcile(args []string, logger *logrus.Logger, out io.Writer) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	file := fs.String("file", "", "desired role document, - for stdin")
+	apply := fs.Bool("apply", false, "execute the plan instead of printing it only")
+	ignoreUnlisted := fs.Bool("ignore-unlisted", false, "keep users that are not in the document")
+	tenant := fs.Int("tenant", app.GetEnvAsInt("DEFAULT_TENANT_ID", 1), "organization to reconcile")
+	actor := fs.String("actor", "reconcile", "name recorded in the audit events")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("reconcile: -file is required")
+	}
+
+	var in io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("reconcile: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	var desired app.DesiredRoles
+	if err := json.NewDecoder(in).Decode(&desired); err != nil {
+		return fmt.Errorf("reconcile: %s: %v", *file, err)
+	}
+	desired.IgnoreUnlisted = desired.IgnoreUnlisted || *ignoreUnlisted
+
+	ctx := app.WithTenant(context.Background(), *tenant)
+	s := internal.NewBasicService(logger)
+	var (
+		plan app.RolePlan
+		err  error
+	)
+	if *apply {
+		plan, err = s.ApplyRoles(ctx, desired, *actor)
+	} else {
+		plan, err = s.PlanRoles(ctx, desired)
+	}
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(plan)
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"testgenerate_backend_user/internal/app"
+	"time"
+)
+
+func (u userService) SetUserStatus(ctx context.Context, userName string, change app.StatusChange) error {
+	switch change.Status {
+	case app.UserStatusActive:
+		change.Reason, change.Until = "", nil
+	case app.UserStatusDisabled:
+		change.Until = nil
+	case app.UserStatusLocked:
+		if change.Until != nil && !change.Until.After(time.Now()) {
+			return fmt.Errorf("SetUserStatus: until is in the past: %w", ErrInvalidArgument)
+		}
+	default:
+		return fmt.Errorf("SetUserStatus: status %q: %w", change.Status, ErrInvalidArgument)
+	}
+
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("SetUserStatus. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		admins, errT := protectAccount(ctx, tx, userName)
+		if errT != nil {
+			return errT
+		}
+		tag, errT := tx.Exec(ctx, `update users set status = $2, status_reason = nullif($3, ''), locked_until = $4,
+					status_changed_at = now(), status_changed_by = $5
+				where user_name = $1 and deleted_at is null and org_visible(org_id)`,
+			userName, change.Status, change.Reason, change.Until, change.ChangedBy)
+		if errT != nil {
+			return errT
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("user %s: %w", userName, ErrNotFound)
+		}
+		return checkAdministrators(ctx, tx, userName, admins)
+	})
+	if isGuardError(err) {
+		return fmt.Errorf("SetUserStatus: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("SetUserStatus conn.Exec: %v\n", err)
+	}
+	return nil
+}
+
+// UnlockExpiredAccounts persists the unlock of accounts whose locked_until has passed.
+// Authentication already treats them as unlocked, this only keeps the table in line.
+func (u userService) UnlockExpiredAccounts(ctx context.Context) (int64, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("UnlockExpiredAccounts. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tag, err := conn.Exec(ctx, `update users set status = 'active', status_reason = null, locked_until = null,
+				status_changed_at = now(), status_changed_by = 'system'
+			where status = 'locked' and locked_until < now() and org_visible(org_id)`)
+	if err != nil {
+		return 0, fmt.Errorf("UnlockExpiredAccounts conn.Exec: %v\n", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (u userService) CountUsersByStatus(ctx context.Context) (map[string]int, error) {
+	counts := map[string]int{
+		app.UserStatusActive:   0,
+		app.UserStatusDisabled: 0,
+		app.UserStatusLocked:   0,
+	}
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("CountUsersByStatus. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, `select status, count(*) from users where deleted_at is null and org_visible(org_id)
+			group by status`)
+	if err != nil {
+		return counts, fmt.Errorf("CountUsersByStatus Query: %v\n", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			status string
+			count  int
+		)
+		if err = rows.Scan(&status, &count); err != nil {
+			return counts, fmt.Errorf("CountUsersByStatus rows.Scan: %v\n", err)
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+// account is what authorization needs to know about the caller.
+// LeadsTeam is set for managers of at least one group. UserName is the current
+// name of the account, which differs from the token after a rename.
+type account struct {
+	UserName  string
+	OrgID     int
+	Roles     []app.Role
+	LeadsTeam bool
+}
+
+// checkAccount rejects tokens of disabled and deleted accounts and of accounts with a running lock,
+// and returns the roles the caller acts with: the primary role first, then the other direct roles and
+// the roles of the caller's groups. An expired time-bound primary
+// role is replaced by its fallback role at once, even if the downgrade is not persisted yet.
+// Tokens of users that are not in the database are refused, the role of the token grants nothing:
+// the user was never added, was purged, or was renamed and the alias of the old name expired.
+func checkAccount(ctx context.Context, userName string) (account, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return account{}, fmt.Errorf("checkAccount. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+	return lookupAccount(ctx, conn, userName)
+}
+
+func lookupAccount(ctx context.Context, db queryRower, userName string) (account, error) {
+	var (
+		blocked bool
+		acc     account
+		roles   string
+	)
+	err := db.QueryRow(ctx, `select u.user_name, u.deleted_at is not null or u.status = 'disabled'
+				or (u.status = 'locked' and coalesce(u.locked_until > now(), true)),
+				u.org_id, exists(select 1 from group_manager gm where gm.user_name = u.user_name),
+				coalesce((select json_agg(json_build_object('id', t.id, 'role_name', t.role_name)
+						order by t.secondary, t.id) from (
+						select r.role_name, x.role <> u.role as secondary, r.id
+						from user_roles x join user_role r on r.id = case
+							when x.role = u.role and u.role_expires_at <= now() then coalesce(u.fallback_role, x.role)
+							else x.role end
+						where x.user_name = u.user_name
+						union
+						select r.role_name, true, r.id
+						from user_effective_group eg join group_role gr on gr.group_id = eg.group_id
+							join user_role r on r.id = gr.role
+						where eg.user_name = u.user_name) t), '[]')
+			from users u where u.user_name = resolve_user_name($1)`, userName).Scan(&acc.UserName, &blocked, &acc.OrgID,
+		&acc.LeadsTeam, &roles)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return account{}, fmt.Errorf("user %s is unknown: %w", userName, ErrForbidden)
+	}
+	if err != nil {
+		return account{}, fmt.Errorf("checkAccount QueryRow: %v\n", err)
+	}
+	if blocked {
+		return account{}, fmt.Errorf("user %s: %w", userName, ErrAccountDisabled)
+	}
+	if err = json.Unmarshal([]byte(roles), &acc.Roles); err != nil {
+		return account{}, fmt.Errorf("checkAccount json.Unmarshal: %v\n", err)
+	}
+	return acc, nil
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+)
+
+// fakeRow scans values into the destinations of Scan, or fails with err.
+type fakeRow struct {
+	values []interface{}
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+	return nil
+}
+
+// fakeDB answers every query with row and keeps the queries and their arguments.
+type fakeDB struct {
+	row     fakeRow
+	queries []string
+	args    [][]any
+}
+
+func (db *fakeDB) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	db.queries = append(db.queries, sql)
+	db.args = append(db.args, args)
+	return db.row
+}
+
+func TestLookupAccount(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		row  fakeRow
+		want account
+		err  error
+	}{
+		{"unknown user", fakeRow{err: pgx.ErrNoRows}, account{}, ErrForbidden},
+		{"blocked user", fakeRow{values: []interface{}{"bob", true, 2, false, `[]`}}, account{}, ErrAccountDisabled},
+		{"active user", fakeRow{values: []interface{}{"bob", false, 2, true,
+			`[{"id":3,"role_name":"editor"},{"id":5,"role_name":"viewer"}]`}},
+			account{UserName: "bob", OrgID: 2, LeadsTeam: true,
+				Roles: []app.Role{{ID: 3, Role: "editor"}, {ID: 5, Role: "viewer"}}}, nil},
+	} {
+		acc, err := lookupAccount(context.Background(), &fakeDB{row: tc.row}, "bob")
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if !reflect.DeepEqual(acc, tc.want) {
+			t.Errorf("%s: account %+v, want %+v", tc.name, acc, tc.want)
+		}
+	}
+}
+
+// TestPurgedUserToken checks that the token of a purged user, whose row is gone, is refused
+// instead of being authenticated with the role it names.
+func TestPurgedUserToken(t *testing.T) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"username": "bob", "role": roleSuperadmin})
+	signed, err := token.SignedString([]byte(app.GetEnv("SECRET_KEY", "secretkey")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/v1/usersrole", nil)
+	r.Header.Set("Authorization", "Bearer "+signed)
+
+	db := &fakeDB{row: fakeRow{err: pgx.ErrNoRows}}
+	caller, err := authenticateAccount(context.Background(), r, func(ctx context.Context, userName string) (account, error) {
+		return lookupAccount(ctx, db, userName)
+	})
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("error %v, want ErrForbidden", err)
+	}
+	if caller.AllTenants || len(caller.Permissions) != 0 || len(caller.Roles) != 0 {
+		t.Errorf("caller %+v of a purged user", caller)
+	}
+	if len(db.args) != 1 || db.args[0][0] != "bob" {
+		t.Errorf("looked up %v, want bob", db.args)
+	}
+}
+
+func TestRenamedUserToken(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		row  fakeRow
+		user string
+		err  error
+	}{
+		{"alias resolves", fakeRow{values: []interface{}{"robert", false, 1, false, `[{"id":3,"role_name":"editor"}]`}}, "robert", nil},
+		{"alias expired", fakeRow{err: pgx.ErrNoRows}, "", ErrForbidden},
+	} {
+		db := &fakeDB{row: tc.row}
+		acc, err := lookupAccount(context.Background(), db, "bob")
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+		}
+		if acc.UserName != tc.user {
+			t.Errorf("%s: user %q, want %q", tc.name, acc.UserName, tc.user)
+		}
+		if !strings.Contains(db.queries[0], "u.user_name = resolve_user_name($1)") {
+			t.Errorf("%s: token name is not resolved: %s", tc.name, db.queries[0])
+		}
+	}
+}
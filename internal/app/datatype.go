@@ -1,13 +1,252 @@
 package app
 
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+	UserStatusLocked   = "locked"
+)
+
+// User.Role and User.RoleID are the primary role, Roles lists every role granted to the user
+// directly and InheritedRoles the roles the user holds through groups.
 type User struct {
-	Name       string `json:"user_name"`
-	Role       string `json:"role_name"`
-	RoleID     int    `json:"role_id"`
-	CreateTime string `json:"create_time,omitempty"`
+	ID          string     `json:"id,omitempty"`
+	Name        string     `json:"user_name" validate:"required,max=255"`
+	Role        string     `json:"role_name"`
+	RoleID      int        `json:"role_id"`
+	CreateTime  string     `json:"create_time,omitempty"`
+	Email       string     `json:"email,omitempty" validate:"max=255"`
+	DisplayName string     `json:"display_name,omitempty" validate:"max=255"`
+	Status      string     `json:"status,omitempty" validate:"oneof=active disabled locked"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	Locale      string     `json:"locale,omitempty" validate:"max=35"`
+	Timezone    string     `json:"timezone,omitempty" validate:"max=64"`
+
+	Roles          []Role      `json:"roles,omitempty"`
+	InheritedRoles []GroupRole `json:"inherited_roles,omitempty"`
+
+	StatusReason string     `json:"status_reason,omitempty"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+
+	RoleExpiresAt    *time.Time `json:"role_expires_at,omitempty"`
+	FallbackRoleID   int        `json:"fallback_role_id,omitempty"`
+	FallbackRoleName string     `json:"fallback_role_name,omitempty"`
+
+	OrgID int `json:"org_id,omitempty"`
+
+	//Former names that still resolve to the user after a rename
+	Aliases []string `json:"aliases,omitempty"`
 }
 
+// UserFilter narrows user listings. Soft deleted users are left out unless IncludeDeleted is set.
+// ManagedBy restricts the listing to the members of the groups a team lead manages.
+type UserFilter struct {
+	IncludeDeleted bool
+	ManagedBy      string
+}
+
+// StatusChange disables, locks or re-activates an account.
+// Until is only used for locks: the account is unlocked automatically after it.
+type StatusChange struct {
+	Status    string     `json:"-"`
+	Reason    string     `json:"reason,omitempty" validate:"max=1000"`
+	Until     *time.Time `json:"until,omitempty"`
+	ChangedBy string     `json:"-"`
+}
+
+// Role.Permissions are the permissions granted to the role directly,
+// the role also inherits every permission of its Parents.
 type Role struct {
-	ID   int    `json:"id"`
-	Role string `json:"role_name"`
+	ID          int      `json:"id"`
+	Role        string   `json:"role_name" validate:"max=255"`
+	Parents     []int    `json:"parents,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	OrgID       *int     `json:"org_id,omitempty"`
+}
+
+type Invitation struct {
+	ID         int        `json:"id"`
+	UserName   string     `json:"user_name,omitempty" validate:"max=255"`
+	Email      string     `json:"email,omitempty" validate:"max=255"`
+	Role       string     `json:"role_name"`
+	RoleID     int        `json:"role_id"`
+	Status     string     `json:"status,omitempty"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreateTime *time.Time `json:"create_time,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RedeemedAt *time.Time `json:"redeemed_at,omitempty"`
+	RedeemedBy string     `json:"redeemed_by,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Code       string     `json:"code,omitempty"`
+	OrgID      int        `json:"org_id,omitempty"`
+}
+
+// Group members get every role of the group and of its parent groups.
+// Managers administer the members of the group and of its subgroups.
+type Group struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name" validate:"required,max=255"`
+	Description string   `json:"description,omitempty" validate:"max=1000"`
+	ParentID    *int     `json:"parent_id,omitempty"`
+	Members     []string `json:"members,omitempty"`
+	Managers    []string `json:"managers,omitempty"`
+	Roles       []Role   `json:"roles,omitempty"`
+	OrgID       int      `json:"org_id,omitempty"`
+}
+
+// GroupRole is a role a user holds through a group.
+type GroupRole struct {
+	ID        int    `json:"id"`
+	Role      string `json:"role_name"`
+	GroupID   int    `json:"group_id"`
+	GroupName string `json:"group_name"`
+}
+
+type AuditEvent struct {
+	ID         int64                  `json:"id"`
+	CreateTime *time.Time             `json:"create_time,omitempty"`
+	Actor      string                 `json:"actor"`
+	Action     string                 `json:"action"`
+	UserName   string                 `json:"user_name,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// AuditFilter narrows audit event listings to the events about UserNames, or about the members
+// of the groups the team lead ManagedBy manages. Limit keeps the latest events of each of the
+// users when UserNames is set, else the latest events of the whole listing.
+type AuditFilter struct {
+	UserNames []string
+	ManagedBy string
+	Limit     int
+}
+
+// Organization is a tenant. Users, groups, invitations and the roles of an organization
+// are only visible within it, roles without an organization are shared by all of them.
+type Organization struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name" validate:"required,max=255"`
+	CreateTime *time.Time `json:"create_time,omitempty"`
+}
+
+// RoleAssignment lists the roles a user should hold, the primary role first.
+type RoleAssignment struct {
+	UserName string   `json:"user_name" validate:"required,max=255"`
+	Roles    []string `json:"roles" validate:"min=1"`
+}
+
+// DesiredRoles is the role assignment of every user of an organization as kept in code.
+// Users missing from Users are deleted unless IgnoreUnlisted is set.
+type DesiredRoles struct {
+	Users          []RoleAssignment `json:"users"`
+	IgnoreUnlisted bool             `json:"ignore_unlisted,omitempty"`
+}
+
+// RolePlan is the difference between DesiredRoles and the database.
+type RolePlan struct {
+	Create []RoleAssignment `json:"create"`
+	Change []RoleChange     `json:"change"`
+	Delete []string         `json:"delete"`
+}
+
+type RoleChange struct {
+	UserName string   `json:"user_name"`
+	From     []string `json:"from"`
+	To       []string `json:"to"`
+}
+
+// UserRecord is a user in import and export files. Roles are the roles besides the primary Role.
+type UserRecord struct {
+	Line        int      `json:"-"`
+	UserName    string   `json:"user_name"`
+	Role        string   `json:"role_name"`
+	Roles       []string `json:"roles,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	DisplayName string   `json:"display_name,omitempty"`
+	Status      string   `json:"status,omitempty"`
+	Locale      string   `json:"locale,omitempty"`
+	Timezone    string   `json:"timezone,omitempty"`
+}
+
+// ImportOptions control a bulk import. Upsert updates existing users instead of rejecting them.
+// With ChunkSize 0 the import is a single transaction that fails as a whole, otherwise every
+// chunk of ChunkSize rows is committed with the rows that passed. DryRun rolls everything back.
+type ImportOptions struct {
+	DryRun    bool `json:"dry_run"`
+	Upsert    bool `json:"upsert"`
+	ChunkSize int  `json:"chunk_size"`
+}
+
+type ImportReport struct {
+	Total     int         `json:"total"`
+	Created   int         `json:"created"`
+	Updated   int         `json:"updated"`
+	Failed    int         `json:"failed"`
+	Committed bool        `json:"committed"`
+	Rows      []ImportRow `json:"rows"`
+}
+
+// ImportRow is the outcome of one row: created, updated or failed. Committed in
+// ImportReport tells whether the created and updated rows were kept.
+type ImportRow struct {
+	Line     int    `json:"line"`
+	UserName string `json:"user_name"`
+	Result   string `json:"result"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Operations and modes of POST /users/batch.
+const (
+	BatchAdd        = "add"
+	BatchUpdate     = "update"
+	BatchDelete     = "delete"
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+// UserBatch is a list of user operations run in one transaction. In BatchAtomic mode, the default,
+// the first failing operation rolls back all of them. In BatchBestEffort mode every operation runs
+// in a savepoint and the ones that pass are committed.
+type UserBatch struct {
+	Mode       string          `json:"mode" validate:"oneof=atomic best_effort"`
+	Operations []UserOperation `json:"operations"`
+}
+
+// UserOperation adds, updates or deletes User like POST /user, PUT /user and DELETE /user/{username}.
+type UserOperation struct {
+	Op   string `json:"op" validate:"required,oneof=add update delete"`
+	User User   `json:"user"`
+}
+
+type BatchReport struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchResult is the outcome of one operation, ok or failed, in the order of the batch.
+type BatchResult struct {
+	Index    int    `json:"index"`
+	Op       string `json:"op"`
+	UserName string `json:"user_name"`
+	Result   string `json:"result"`
+	Error    string `json:"error,omitempty"`
+}
+
+// UserPatch changes some fields of a user. Either MergePatch, a JSON Merge Patch (RFC 7396),
+// or JSONPatch, a JSON Patch (RFC 6902), is set.
+type UserPatch struct {
+	MergePatch json.RawMessage      `json:"merge_patch,omitempty"`
+	JSONPatch  []JSONPatchOperation `json:"json_patch,omitempty"`
+}
+
+type JSONPatchOperation struct {
+	Op    string          `json:"op" validate:"required,oneof=add remove replace test"`
+	Path  string          `json:"path" validate:"required"`
+	Value json.RawMessage `json:"value,omitempty"`
 }
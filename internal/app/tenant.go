@@ -0,0 +1,34 @@
+package app
+
+import "context"
+
+type tenantKey struct{}
+
+// tenantScope is the organization a request works in. All is set for platform
+// superadmins and background jobs that operate across organizations.
+type tenantScope struct {
+	ID  int
+	All bool
+}
+
+func WithTenant(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantScope{ID: id})
+}
+
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantScope{All: true})
+}
+
+// TenantFrom returns the organization of ctx, or all == true when ctx spans all organizations.
+// Without a tenant in ctx it falls back to DEFAULT_TENANT_ID.
+func TenantFrom(ctx context.Context) (id int, all bool) {
+	if scope, ok := ctx.Value(tenantKey{}).(tenantScope); ok {
+		if scope.All {
+			return 0, true
+		}
+		if scope.ID != 0 {
+			return scope.ID, false
+		}
+	}
+	return GetEnvAsInt("DEFAULT_TENANT_ID", 1), false
+}
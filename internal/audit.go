@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"testgenerate_backend_user/internal/app"
+)
+
+// execer is implemented by both *pgx.Conn and pgx.Tx, so audit events can be written
+// inside the transaction of the change they describe.
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// recordAuditEvent files the event under the organization of the user it is about,
+// events without a user under the organization of the connection. The id of the user
+// keeps the event with the user after a rename.
+func recordAuditEvent(ctx context.Context, db execer, actor, action, userName string, details map[string]interface{}) error {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("recordAuditEvent json.Marshal: %v\n", err)
+	}
+	_, err = db.Exec(ctx, `insert into audit_event(actor, action, user_name, details, org_id, user_id)
+			values($1, $2, nullif($3, ''), $4,
+				coalesce((select org_id from users where user_name = $3), current_org()),
+				(select id from users where user_name = $3))`,
+		actor, action, userName, raw)
+	if err != nil {
+		return fmt.Errorf("recordAuditEvent insert into audit_event: %v\n", err)
+	}
+	return nil
+}
+
+// GetAuditEvents lists the latest events first. Events are matched to users by their id, so
+// they carry the current name of a renamed user.
+func (u userService) GetAuditEvents(ctx context.Context, filter app.AuditFilter) ([]app.AuditEvent, error) {
+	var events []app.AuditEvent
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return events, fmt.Errorf("GetAuditEvents. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	names := filter.UserNames
+	if names == nil {
+		names = []string{}
+	}
+	rows, err := conn.Query(ctx, `select to_json(t.*) from (
+				select e.id, e.create_time, e.actor, e.action, coalesce(u.user_name, e.user_name) as user_name, e.details,
+					row_number() over (partition by case when cardinality($1::text[]) > 0
+						then coalesce(u.user_name, e.user_name) end order by e.id desc) as n
+				from audit_event e left join users u on u.id = e.user_id
+				where org_visible(e.org_id)
+					and (cardinality($1::text[]) = 0 or coalesce(u.user_name, e.user_name) = any($1::text[]))
+					and ($2 = '' or coalesce(u.user_name, e.user_name) in (`+managedUsers+`))) t
+			where $3::int <= 0 or t.n <= $3::int
+			order by t.id desc`, names, filter.ManagedBy, filter.Limit)
+	if err != nil {
+		return events, fmt.Errorf("GetAuditEvents Query: %v\n", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res string
+		if err = rows.Scan(&res); err != nil {
+			return events, fmt.Errorf("GetAuditEvents rows.Scan: %v\n", err)
+		}
+		var event app.AuditEvent
+		if err = json.Unmarshal([]byte(res), &event); err != nil {
+			return events, fmt.Errorf("GetAuditEvents json.Unmarshal: %v\n", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+)
+
+// batchMaxOperations caps the operations of one POST /users/batch request.
+func batchMaxOperations() int {
+	return app.GetEnvAsInt("BATCH_MAX_OPERATIONS", 100)
+}
+
+// BatchUsers runs the operations of the batch in order in one transaction, see app.UserBatch.
+// Atomic batches fail with the error of the first failing operation and change nothing.
+func (u userService) BatchUsers(ctx context.Context, batch app.UserBatch, actor string) (app.BatchReport, error) {
+	report := app.BatchReport{Results: make([]app.BatchResult, 0, len(batch.Operations))}
+	if err := checkBatch(batch); err != nil {
+		return report, fmt.Errorf("BatchUsers: %w", err)
+	}
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return report, fmt.Errorf("BatchUsers. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	bestEffort := batch.Mode == app.BatchBestEffort
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		for i, op := range batch.Operations {
+			result := app.BatchResult{Index: i, Op: op.Op, UserName: op.User.Name, Result: "ok"}
+			if !bestEffort {
+				if errB := runOperation(ctx, tx, op, actor); errB != nil {
+					return fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.User.Name, errB)
+				}
+				report.Results = append(report.Results, result)
+				continue
+			}
+
+			sp, errB := tx.Begin(ctx)
+			if errB != nil {
+				return errB
+			}
+			if errB = runOperation(ctx, sp, op, actor); errB != nil {
+				_ = sp.Rollback(ctx)
+				if !isGuardError(errB) {
+					return errB
+				}
+				result.Result, result.Error = "failed", errB.Error()
+			} else if errB = sp.Commit(ctx); errB != nil {
+				return errB
+			}
+			report.Results = append(report.Results, result)
+		}
+		return nil
+	})
+	if isGuardError(err) {
+		return app.BatchReport{}, fmt.Errorf("BatchUsers: %w", err)
+	}
+	if err != nil {
+		return app.BatchReport{}, fmt.Errorf("BatchUsers: %v\n", err)
+	}
+
+	report.Committed = true
+	for _, result := range report.Results {
+		if result.Result == "ok" {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// checkBatch rejects unknown modes and operations and batches above BATCH_MAX_OPERATIONS.
+func checkBatch(batch app.UserBatch) error {
+	switch batch.Mode {
+	case "", app.BatchAtomic, app.BatchBestEffort:
+	default:
+		return fmt.Errorf("mode %q is not one of atomic, best_effort: %w", batch.Mode, ErrInvalidArgument)
+	}
+	if len(batch.Operations) > batchMaxOperations() {
+		return fmt.Errorf("%d operations exceed the limit of %d: %w",
+			len(batch.Operations), batchMaxOperations(), ErrInvalidArgument)
+	}
+	for i, op := range batch.Operations {
+		switch op.Op {
+		case app.BatchAdd, app.BatchUpdate, app.BatchDelete:
+		default:
+			return fmt.Errorf("operation %d: op %q is not one of add, update, delete: %w", i, op.Op, ErrInvalidArgument)
+		}
+		if strings.TrimSpace(op.User.Name) == "" {
+			return fmt.Errorf("operation %d: user_name is required: %w", i, ErrInvalidArgument)
+		}
+	}
+	return nil
+}
+
+// runOperation applies one operation with the rules of the single request and records it.
+func runOperation(ctx context.Context, tx pgx.Tx, op app.UserOperation, actor string) error {
+	var err error
+	switch op.Op {
+	case app.BatchAdd:
+		//Like AddUser, new users start with the 'user' role and an update in the batch may change it
+		user := op.User
+		user.Status = app.UserStatusActive
+		if user.Name, err = checkUserName("user_name", user.Name); err != nil {
+			return err
+		}
+		if err = validateProfile(&user); err != nil {
+			return err
+		}
+		op.User.Name = user.Name
+		err = insertUser(ctx, tx, user, 3, orgOf(ctx))
+	case app.BatchUpdate:
+		if op.User.Name, err = resolveName(ctx, tx, op.User.Name); err == nil {
+			err = updateUser(ctx, tx, op.User)
+		}
+	case app.BatchDelete:
+		if op.User.Name, err = resolveName(ctx, tx, op.User.Name); err == nil {
+			err = deleteUser(ctx, tx, op.User.Name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return recordAuditEvent(ctx, tx, actor, "user.batch", op.User.Name, map[string]interface{}{"op": op.Op})
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"errors"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+)
+
+func TestCheckBatch(t *testing.T) {
+	t.Setenv("BATCH_MAX_OPERATIONS", "3")
+	op := func(kind, name string) app.UserOperation {
+		return app.UserOperation{Op: kind, User: app.User{Name: name}}
+	}
+	valid := []app.UserOperation{op(app.BatchAdd, "alice"), op(app.BatchUpdate, "bob"), op(app.BatchDelete, "carol")}
+
+	for _, tc := range []struct {
+		name  string
+		batch app.UserBatch
+		err   error
+	}{
+		{"atomic by default", app.UserBatch{Operations: valid}, nil},
+		{"atomic", app.UserBatch{Mode: app.BatchAtomic, Operations: valid}, nil},
+		{"best effort", app.UserBatch{Mode: app.BatchBestEffort, Operations: valid}, nil},
+		{"empty", app.UserBatch{}, nil},
+		{"unknown mode", app.UserBatch{Mode: "parallel", Operations: valid}, ErrInvalidArgument},
+		{"mode is case sensitive", app.UserBatch{Mode: "Atomic", Operations: valid}, ErrInvalidArgument},
+		{"above BATCH_MAX_OPERATIONS", app.UserBatch{Operations: append(valid, op(app.BatchAdd, "dave"))}, ErrInvalidArgument},
+		{"unknown op", app.UserBatch{Operations: []app.UserOperation{op("rename", "alice")}}, ErrInvalidArgument},
+		{"missing op", app.UserBatch{Operations: []app.UserOperation{op("", "alice")}}, ErrInvalidArgument},
+		{"empty user_name", app.UserBatch{Operations: []app.UserOperation{op(app.BatchAdd, "")}}, ErrInvalidArgument},
+		{"blank user_name", app.UserBatch{Operations: []app.UserOperation{op(app.BatchDelete, "  ")}}, ErrInvalidArgument},
+		// Best effort skips failing operations when they run, not invalid ones: the batch is refused
+		{"best effort with an unknown op", app.UserBatch{Mode: app.BatchBestEffort,
+			Operations: []app.UserOperation{op(app.BatchAdd, "alice"), op("rename", "bob")}}, ErrInvalidArgument},
+		{"best effort above BATCH_MAX_OPERATIONS", app.UserBatch{Mode: app.BatchBestEffort,
+			Operations: append(valid, op(app.BatchAdd, "dave"))}, ErrInvalidArgument},
+	} {
+		err := checkBatch(tc.batch)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+)
+
+// userScope is the set of users a caller administers. Callers that hold a users permission
+// themselves act on every user of their organization, team leads act on the members of the
+// groups they manage and of the subgroups of those.
+type userScope struct {
+	All  bool
+	Lead string
+}
+
+// scopeOf returns the scope in which the caller may use permission, users.read or users.write.
+func scopeOf(caller principal, permission string) (userScope, error) {
+	switch {
+	case caller.Can(permission):
+		return userScope{All: true}, nil
+	case caller.LeadsTeam:
+		return userScope{Lead: caller.User}, nil
+	default:
+		return userScope{}, ErrForbidden
+	}
+}
+
+// filter narrows a user listing to the scope.
+func (s userScope) filter(filter app.UserFilter) app.UserFilter {
+	filter.ManagedBy = s.Lead
+	return filter
+}
+
+// authorizeUserChange checks that the caller may change the user and grant it the given roles.
+// The user has to be in the caller's scope and must not hold a permission the caller lacks,
+// so nobody administers users above their own level. Granted roles are limited the same way.
+// Superadmins are not restricted.
+func authorizeUserChange(ctx context.Context, caller principal, scope userScope, userName string, grants ...app.Role) error {
+	if caller.HasRole(roleSuperadmin) {
+		return nil
+	}
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("authorizeUserChange. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+	return authorizeUser(ctx, conn, caller, scope, userName, grants...)
+}
+
+func authorizeUser(ctx context.Context, db queryRower, caller principal, scope userScope, userName string,
+	grants ...app.Role) error {
+	var (
+		inScope bool
+		held    []int
+	)
+	err := db.QueryRow(ctx, `select $2 = '' or users.user_name in (`+managedUsers+`),
+				array(select x.role from user_roles x where x.user_name = users.user_name
+					union
+					select gr.role from user_effective_group eg join group_role gr on gr.group_id = eg.group_id
+					where eg.user_name = users.user_name)
+			from users where users.user_name = resolve_user_name($1) and org_visible(users.org_id)`,
+		userName, scope.Lead).Scan(&inScope, &held)
+	if errors.Is(err, pgx.ErrNoRows) {
+		//Unknown users are reported by the service, except to team leads
+		if scope.All {
+			return nil
+		}
+		return fmt.Errorf("user %s is not in a team of %s: %w", userName, caller.User, ErrForbidden)
+	}
+	if err != nil {
+		return fmt.Errorf("authorizeUserChange QueryRow: %v\n", err)
+	}
+	if !inScope {
+		return fmt.Errorf("user %s is not in a team of %s: %w", userName, caller.User, ErrForbidden)
+	}
+
+	roles := make([]app.Role, 0, len(held))
+	for _, id := range held {
+		roles = append(roles, app.Role{ID: id})
+	}
+	if err = checkLevel(ctx, caller, roles); err != nil {
+		return fmt.Errorf("user %s: %w", userName, err)
+	}
+
+	for _, grant := range grants {
+		if err = checkGrant(ctx, db, caller, grant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizeGrant checks that the caller may hand out the role, to a group or by an invitation,
+// like the roles granted by authorizeUserChange. Superadmins are not restricted.
+func authorizeGrant(ctx context.Context, caller principal, role app.Role) error {
+	if caller.HasRole(roleSuperadmin) {
+		return nil
+	}
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("authorizeGrant. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+	return checkGrant(ctx, conn, caller, role)
+}
+
+// authorizeImport checks the rows of an import like authorizeUserChange checks single changes:
+// the roles of every row, and with upsert the users that exist already. Unknown roles are left
+// to the row that names them.
+func authorizeImport(ctx context.Context, caller principal, records []app.UserRecord, upsert bool) error {
+	if caller.HasRole(roleSuperadmin) {
+		return nil
+	}
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("authorizeImport. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	checked := map[string]bool{}
+	for _, record := range records {
+		if upsert {
+			err = authorizeUser(ctx, conn, caller, userScope{All: true}, normalizeUserName(record.UserName))
+			if err != nil {
+				return fmt.Errorf("line %d: %w", record.Line, err)
+			}
+		}
+		for _, name := range append([]string{record.Role}, record.Roles...) {
+			if checked[strings.ToLower(name)] {
+				continue
+			}
+			checked[strings.ToLower(name)] = true
+			err = checkGrant(ctx, conn, caller, app.Role{Role: name})
+			if err != nil && !errors.Is(err, ErrInvalidArgument) {
+				return fmt.Errorf("line %d: %w", record.Line, err)
+			}
+		}
+	}
+	return nil
+}
+
+// authorizeRolePlan authorizes applying a desired role document like the single changes of its
+// plan: every role granted, and every user changed or deleted. The plan is computed again
+// under lock when it is applied.
+func authorizeRolePlan(ctx context.Context, caller principal, desired app.DesiredRoles, force bool) error {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("authorizeRolePlan. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	superadmin := caller.HasRole(roleSuperadmin)
+	roles := func(names []string) []app.Role {
+		grants := make([]app.Role, 0, len(names))
+		for _, name := range names {
+			grants = append(grants, app.Role{Role: name})
+		}
+		return grants
+	}
+	err = pgx.BeginTxFunc(ctx, conn, pgx.TxOptions{AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		plan, _, errP := planRoles(ctx, tx, desired, false)
+		if errP != nil {
+			return errP
+		}
+		for _, c := range plan.Create {
+			if superadmin {
+				break
+			}
+			for _, grant := range roles(c.Roles) {
+				if errP = checkGrant(ctx, tx, caller, grant); errP != nil {
+					return fmt.Errorf("user %s: %w", c.UserName, errP)
+				}
+			}
+		}
+		for _, c := range plan.Change {
+			if errP = checkSelfChange(caller, c.UserName, force); errP != nil {
+				return errP
+			}
+			if !superadmin {
+				if errP = authorizeUser(ctx, tx, caller, userScope{All: true}, c.UserName, roles(c.To)...); errP != nil {
+					return errP
+				}
+			}
+		}
+		for _, name := range plan.Delete {
+			if errP = checkSelfChange(caller, name, force); errP != nil {
+				return errP
+			}
+			if !superadmin {
+				if errP = authorizeUser(ctx, tx, caller, userScope{All: true}, name); errP != nil {
+					return errP
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil && !isGuardError(err) {
+		return fmt.Errorf("authorizeRolePlan: %v\n", err)
+	}
+	return err
+}
+
+func checkGrant(ctx context.Context, db queryRower, caller principal, grant app.Role) error {
+	if grant.ID == 0 && grant.Role == "" {
+		return nil
+	}
+	err := resolveRole(ctx, db, &grant)
+	if errors.Is(err, ErrInvalidArgument) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("checkGrant select role: %v\n", err)
+	}
+	if err = checkLevel(ctx, caller, []app.Role{grant}); err != nil {
+		return fmt.Errorf("role %s: %w", grant.Role, err)
+	}
+	return nil
+}
+
+// authorizeRole checks a change of a role like a grant of it: the permissions the role carries
+// directly and through its parents must not exceed the caller's, or the caller could raise
+// their own role.
+func authorizeRole(ctx context.Context, caller principal, role app.Role) error {
+	if err := checkPermissionNames(role.Permissions); err != nil {
+		return err
+	}
+	if caller.HasRole(roleSuperadmin) {
+		return nil
+	}
+	for _, p := range role.Permissions {
+		if !caller.Can(p) {
+			return fmt.Errorf("permission %s exceeds the caller's: %w", p, ErrForbidden)
+		}
+	}
+	parents := make([]app.Role, 0, len(role.Parents))
+	for _, id := range role.Parents {
+		parents = append(parents, app.Role{ID: id})
+	}
+	if err := checkLevel(ctx, caller, parents); err != nil {
+		return fmt.Errorf("parents of role %d: %w", role.ID, err)
+	}
+	return nil
+}
+
+// checkLevel rejects roles that carry a permission the caller does not hold.
+func checkLevel(ctx context.Context, caller principal, roles []app.Role) error {
+	perms, err := roleGraph.permissions(ctx, roles)
+	if err != nil {
+		return err
+	}
+	for p := range perms {
+		if !caller.Can(p) {
+			return fmt.Errorf("permission %s exceeds the caller's: %w", p, ErrForbidden)
+		}
+	}
+	return nil
+}
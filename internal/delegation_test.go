@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+)
+
+func TestAuthorizeRole(t *testing.T) {
+	// role 1 is an administrator, 2 an editor, 3 a viewer
+	useRoleGraph(t, map[int][]string{
+		1: {PermGroupsManage, PermRolesWrite, PermUsersRead, PermUsersWrite},
+		2: {PermRolesWrite, PermUsersRead},
+		3: {PermUsersRead},
+	})
+	editor := principal{User: "ed", Roles: []string{"editor"}, RoleID: 2,
+		Permissions: map[string]bool{PermRolesWrite: true, PermUsersRead: true}}
+	superadmin := principal{User: "root", Roles: []string{roleSuperadmin}, Permissions: map[string]bool{}}
+
+	for _, tc := range []struct {
+		name   string
+		caller principal
+		role   app.Role
+		err    error
+	}{
+		{"held permissions", editor, app.Role{ID: 2, Permissions: []string{PermRolesWrite, PermUsersRead}}, nil},
+		{"lower parent", editor, app.Role{ID: 2, Parents: []int{3}}, nil},
+		{"unknown parent left to UpdateRole", editor, app.Role{ID: 2, Parents: []int{9}}, nil},
+		{"raising the own role", editor, app.Role{ID: 2, Permissions: []string{PermUsersRead, PermUsersWrite}}, ErrForbidden},
+		{"permission of another role", editor, app.Role{ID: 3, Permissions: []string{PermGroupsManage}}, ErrForbidden},
+		{"inheriting from an administrator", editor, app.Role{ID: 2, Parents: []int{3, 1}}, ErrForbidden},
+		{"typo", editor, app.Role{ID: 3, Permissions: []string{"user.read"}}, ErrValidation},
+		{"superadmin", superadmin, app.Role{ID: 2, Parents: []int{1}, Permissions: []string{PermUsersWrite}}, nil},
+		{"typo of a superadmin", superadmin, app.Role{ID: 2, Permissions: []string{"users.delete"}}, ErrValidation},
+	} {
+		err := authorizeRole(context.Background(), tc.caller, tc.role)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestScopeOf(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		caller principal
+		scope  userScope
+		err    error
+	}{
+		{"permission", principal{User: "ann", Permissions: map[string]bool{PermUsersWrite: true}}, userScope{All: true}, nil},
+		{"permission and team", principal{User: "ann", Permissions: map[string]bool{PermUsersWrite: true}, LeadsTeam: true},
+			userScope{All: true}, nil},
+		{"team lead", principal{User: "lea", Permissions: map[string]bool{PermUsersRead: true}, LeadsTeam: true},
+			userScope{Lead: "lea"}, nil},
+		{"other permission", principal{User: "ed", Permissions: map[string]bool{PermUsersRead: true}}, userScope{}, ErrForbidden},
+		{"nothing", principal{User: "ed"}, userScope{}, ErrForbidden},
+	} {
+		scope, err := scopeOf(tc.caller, PermUsersWrite)
+		if scope != tc.scope || !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: %+v, %v, want %+v, %v", tc.name, scope, err, tc.scope, tc.err)
+		}
+	}
+}
+
+func TestAuthorizeUser(t *testing.T) {
+	// role 1 is an administrator, 3 a viewer
+	useRoleGraph(t, map[int][]string{
+		1: {PermUsersRead, PermUsersWrite, PermRolesWrite},
+		3: {PermUsersRead},
+	})
+	caller := principal{User: "lea", LeadsTeam: true, Permissions: map[string]bool{PermUsersRead: true, PermUsersWrite: true}}
+	all, lead := userScope{All: true}, userScope{Lead: "lea"}
+
+	for _, tc := range []struct {
+		name  string
+		scope userScope
+		row   fakeRow
+		err   error
+	}{
+		{"any user", all, fakeRow{values: []interface{}{true, []int{3}}}, nil},
+		{"user in the team", lead, fakeRow{values: []interface{}{true, []int{3}}}, nil},
+		{"user outside the team", lead, fakeRow{values: []interface{}{false, []int{3}}}, ErrForbidden},
+		{"user above the caller", all, fakeRow{values: []interface{}{true, []int{1}}}, ErrForbidden},
+		{"team member above the lead", lead, fakeRow{values: []interface{}{true, []int{3, 1}}}, ErrForbidden},
+		{"user without roles", lead, fakeRow{values: []interface{}{true, []int{}}}, nil},
+		// Unknown users pass with scope.All, the service reports them as not found
+		{"unknown user", all, fakeRow{err: pgx.ErrNoRows}, nil},
+		{"unknown user for a team lead", lead, fakeRow{err: pgx.ErrNoRows}, ErrForbidden},
+	} {
+		db := &fakeDB{row: tc.row}
+		err := authorizeUser(context.Background(), db, caller, tc.scope, "bob")
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+		}
+		if len(db.args) != 1 || db.args[0][0] != "bob" || db.args[0][1] != tc.scope.Lead {
+			t.Errorf("%s: arguments %v, want bob and %q", tc.name, db.args, tc.scope.Lead)
+		}
+	}
+}
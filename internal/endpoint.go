@@ -4,25 +4,108 @@ import (
 	"context"
 	"github.com/go-kit/kit/endpoint"
 	"testgenerate_backend_user/internal/app"
+	"time"
 )
 
 type Endpoints struct {
-	getRolesEndpoint     endpoint.Endpoint
-	GetUserEndpoint      endpoint.Endpoint
-	GetUsersRoleEndpoint endpoint.Endpoint
-	PostUserEndpoint     endpoint.Endpoint
-	PutUserEndpoint      endpoint.Endpoint
-	DeleteUserEndpoint   endpoint.Endpoint
+	getRolesEndpoint           endpoint.Endpoint
+	GetRolePermissionsEndpoint endpoint.Endpoint
+	PutRoleEndpoint            endpoint.Endpoint
+	GetUserEndpoint            endpoint.Endpoint
+	GetUsersRoleEndpoint       endpoint.Endpoint
+	GetRoleExpirationsEndpoint endpoint.Endpoint
+	GetAuditEventsEndpoint     endpoint.Endpoint
+	ImportUsersEndpoint        endpoint.Endpoint
+	ExportUsersEndpoint        endpoint.Endpoint
+	BatchUsersEndpoint         endpoint.Endpoint
+	PlanRolesEndpoint          endpoint.Endpoint
+	ApplyRolesEndpoint         endpoint.Endpoint
+	PostUserEndpoint           endpoint.Endpoint
+	PutUserEndpoint            endpoint.Endpoint
+	PatchUserEndpoint          endpoint.Endpoint
+	DeleteUserEndpoint         endpoint.Endpoint
+	PostLoginEndpoint          endpoint.Endpoint
+	PostUserStatusEndpoint     endpoint.Endpoint
+	PostRestoreEndpoint        endpoint.Endpoint
+	PostRenameEndpoint         endpoint.Endpoint
+	PostUserRoleEndpoint       endpoint.Endpoint
+	DeleteUserRoleEndpoint     endpoint.Endpoint
+
+	GetGroupsEndpoint          endpoint.Endpoint
+	PostGroupEndpoint          endpoint.Endpoint
+	PutGroupEndpoint           endpoint.Endpoint
+	DeleteGroupEndpoint        endpoint.Endpoint
+	PostGroupMemberEndpoint    endpoint.Endpoint
+	DeleteGroupMemberEndpoint  endpoint.Endpoint
+	PostGroupManagerEndpoint   endpoint.Endpoint
+	DeleteGroupManagerEndpoint endpoint.Endpoint
+	PostGroupRoleEndpoint      endpoint.Endpoint
+	DeleteGroupRoleEndpoint    endpoint.Endpoint
+
+	PostInvitationEndpoint   endpoint.Endpoint
+	GetInvitationsEndpoint   endpoint.Endpoint
+	DeleteInvitationEndpoint endpoint.Endpoint
+
+	GetOrganizationsEndpoint endpoint.Endpoint
+	PostOrganizationEndpoint endpoint.Endpoint
 }
 
+// MakeServerEndpoints returns the endpoints of s as every transport serves them. All but
+// PostUserEndpoint, which registers and redeems invitations, refuse callers the transport
+// did not authenticate, so a transport cannot serve them anonymously by mistake.
 func MakeServerEndpoints(s Service) Endpoints {
 	return Endpoints{
-		getRolesEndpoint:     MakeGetRolesEndpoint(s),
-		GetUserEndpoint:      MakeGetUserEndpoint(s),
-		GetUsersRoleEndpoint: MakeGetUsersRoleEndpoint(s),
-		PostUserEndpoint:     MakePostUserEndpoint(s),
-		PutUserEndpoint:      MakePutUserEndpoint(s),
-		DeleteUserEndpoint:   MakeDeleteUserEndpoint(s),
+		getRolesEndpoint:           authenticated(MakeGetRolesEndpoint(s)),
+		GetRolePermissionsEndpoint: authenticated(MakeGetRolePermissionsEndpoint(s)),
+		PutRoleEndpoint:            authenticated(MakePutRoleEndpoint(s)),
+		GetUserEndpoint:            authenticated(MakeGetUserEndpoint(s)),
+		GetUsersRoleEndpoint:       authenticated(MakeGetUsersRoleEndpoint(s)),
+		GetRoleExpirationsEndpoint: authenticated(MakeGetRoleExpirationsEndpoint(s)),
+		GetAuditEventsEndpoint:     authenticated(MakeGetAuditEventsEndpoint(s)),
+		ImportUsersEndpoint:        authenticated(MakeImportUsersEndpoint(s)),
+		ExportUsersEndpoint:        authenticated(MakeExportUsersEndpoint(s)),
+		BatchUsersEndpoint:         authenticated(MakeBatchUsersEndpoint(s)),
+		PlanRolesEndpoint:          authenticated(MakePlanRolesEndpoint(s)),
+		ApplyRolesEndpoint:         authenticated(MakeApplyRolesEndpoint(s)),
+		PostUserEndpoint:           MakePostUserEndpoint(s),
+		PutUserEndpoint:            authenticated(MakePutUserEndpoint(s)),
+		PatchUserEndpoint:          authenticated(MakePatchUserEndpoint(s)),
+		DeleteUserEndpoint:         authenticated(MakeDeleteUserEndpoint(s)),
+		PostLoginEndpoint:          authenticated(MakePostLoginEndpoint(s)),
+		PostUserStatusEndpoint:     authenticated(MakePostUserStatusEndpoint(s)),
+		PostRestoreEndpoint:        authenticated(MakePostRestoreEndpoint(s)),
+		PostRenameEndpoint:         authenticated(MakePostRenameEndpoint(s)),
+		PostUserRoleEndpoint:       authenticated(MakePostUserRoleEndpoint(s)),
+		DeleteUserRoleEndpoint:     authenticated(MakeDeleteUserRoleEndpoint(s)),
+
+		GetGroupsEndpoint:          authenticated(MakeGetGroupsEndpoint(s)),
+		PostGroupEndpoint:          authenticated(MakePostGroupEndpoint(s)),
+		PutGroupEndpoint:           authenticated(MakePutGroupEndpoint(s)),
+		DeleteGroupEndpoint:        authenticated(MakeDeleteGroupEndpoint(s)),
+		PostGroupMemberEndpoint:    authenticated(MakePostGroupMemberEndpoint(s)),
+		DeleteGroupMemberEndpoint:  authenticated(MakeDeleteGroupMemberEndpoint(s)),
+		PostGroupManagerEndpoint:   authenticated(MakePostGroupManagerEndpoint(s)),
+		DeleteGroupManagerEndpoint: authenticated(MakeDeleteGroupManagerEndpoint(s)),
+		PostGroupRoleEndpoint:      authenticated(MakePostGroupRoleEndpoint(s)),
+		DeleteGroupRoleEndpoint:    authenticated(MakeDeleteGroupRoleEndpoint(s)),
+
+		PostInvitationEndpoint:   authenticated(MakePostInvitationEndpoint(s)),
+		GetInvitationsEndpoint:   authenticated(MakeGetInvitationsEndpoint(s)),
+		DeleteInvitationEndpoint: authenticated(MakeDeleteInvitationEndpoint(s)),
+
+		GetOrganizationsEndpoint: authenticated(MakeGetOrganizationsEndpoint(s)),
+		PostOrganizationEndpoint: authenticated(MakePostOrganizationEndpoint(s)),
+	}
+}
+
+// authenticated refuses requests whose context has no authenticated caller. The transports
+// authenticate before decoding, and the decoders check the permissions of the caller.
+func authenticated(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		if _, err := getPermissionParams(ctx); err != nil {
+			return nil, err
+		}
+		return next(ctx, request)
 	}
 }
 
@@ -36,6 +119,26 @@ func (e Endpoints) GetRoles(ctx context.Context, user, role string) ([]app.Role,
 	return resp.Roles, resp.Err
 }
 
+func (e Endpoints) GetRolePermissions(ctx context.Context, roleID int) ([]string, error) {
+	request := getRolePermissionsRequest{roleID}
+	response, err := e.GetRolePermissionsEndpoint(ctx, request)
+	if err != nil {
+		return []string{}, err
+	}
+	resp := response.(getRolePermissionsResponse)
+	return resp.Permissions, resp.Err
+}
+
+func (e Endpoints) PutRole(ctx context.Context, role app.Role, actor string) error {
+	request := putRoleRequest{role, actor}
+	response, err := e.PutRoleEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(putRoleResponse)
+	return resp.Err
+}
+
 func (e Endpoints) GetUser(ctx context.Context, user, role string) (app.User, error) {
 	request := getUserRequest{user, role}
 	response, err := e.GetUserEndpoint(ctx, request)
@@ -46,8 +149,8 @@ func (e Endpoints) GetUser(ctx context.Context, user, role string) (app.User, er
 	return resp.User, resp.Err
 }
 
-func (e Endpoints) GetUsersRole(ctx context.Context) ([]app.User, error) {
-	request := getUsersRoleRequest{}
+func (e Endpoints) GetUsersRole(ctx context.Context, filter app.UserFilter) ([]app.User, error) {
+	request := getUsersRoleRequest{Filter: filter}
 	response, err := e.GetUsersRoleEndpoint(ctx, request)
 	if err != nil {
 		return []app.User{}, err
@@ -56,8 +159,87 @@ func (e Endpoints) GetUsersRole(ctx context.Context) ([]app.User, error) {
 	return resp.Users, resp.Err
 }
 
+func (e Endpoints) ImportUsers(ctx context.Context, records []app.UserRecord, opts app.ImportOptions,
+	actor string) (app.ImportReport, error) {
+	request := importUsersRequest{records, opts, actor}
+	response, err := e.ImportUsersEndpoint(ctx, request)
+	if err != nil {
+		return app.ImportReport{}, err
+	}
+	resp := response.(importUsersResponse)
+	return resp.Report, resp.Err
+}
+
+func (e Endpoints) ExportUsers(ctx context.Context) ([]app.UserRecord, error) {
+	request := exportUsersRequest{FormatNDJSON}
+	response, err := e.ExportUsersEndpoint(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	//Client endpoints read the records of the export file
+	if resp, ok := response.(exportUsersResponse); ok {
+		return resp.Records, nil
+	}
+	var records []app.UserRecord
+	err = response.(streamUsersResponse).Stream(func(user app.User) error {
+		records = append(records, userRecordOf(user))
+		return nil
+	})
+	return records, err
+}
+
+func (e Endpoints) BatchUsers(ctx context.Context, batch app.UserBatch, actor string) (app.BatchReport, error) {
+	request := batchUsersRequest{batch, actor}
+	response, err := e.BatchUsersEndpoint(ctx, request)
+	if err != nil {
+		return app.BatchReport{}, err
+	}
+	resp := response.(batchUsersResponse)
+	return resp.Report, resp.Err
+}
+
+func (e Endpoints) PlanRoles(ctx context.Context, desired app.DesiredRoles) (app.RolePlan, error) {
+	request := rolePlanRequest{Desired: desired}
+	response, err := e.PlanRolesEndpoint(ctx, request)
+	if err != nil {
+		return app.RolePlan{}, err
+	}
+	resp := response.(rolePlanResponse)
+	return resp.Plan, resp.Err
+}
+
+func (e Endpoints) ApplyRoles(ctx context.Context, desired app.DesiredRoles, actor string) (app.RolePlan, error) {
+	request := rolePlanRequest{desired, actor}
+	response, err := e.ApplyRolesEndpoint(ctx, request)
+	if err != nil {
+		return app.RolePlan{}, err
+	}
+	resp := response.(rolePlanResponse)
+	return resp.Plan, resp.Err
+}
+
+func (e Endpoints) GetRoleExpirations(ctx context.Context, within time.Duration, filter app.UserFilter) ([]app.User, error) {
+	request := getRoleExpirationsRequest{within, filter}
+	response, err := e.GetRoleExpirationsEndpoint(ctx, request)
+	if err != nil {
+		return []app.User{}, err
+	}
+	resp := response.(getRoleExpirationsResponse)
+	return resp.Users, resp.Err
+}
+
+func (e Endpoints) GetAuditEvents(ctx context.Context, filter app.AuditFilter) ([]app.AuditEvent, error) {
+	request := getAuditEventsRequest{filter}
+	response, err := e.GetAuditEventsEndpoint(ctx, request)
+	if err != nil {
+		return []app.AuditEvent{}, err
+	}
+	resp := response.(getAuditEventsResponse)
+	return resp.Events, resp.Err
+}
+
 func (e Endpoints) PostUser(ctx context.Context, user app.User) error {
-	request := postUserRequest{user}
+	request := postUserRequest{User: user}
 	response, err := e.PostUserEndpoint(ctx, request)
 	if err != nil {
 		return err
@@ -76,6 +258,16 @@ func (e Endpoints) PutUser(ctx context.Context, user app.User) error {
 	return resp.Err
 }
 
+func (e Endpoints) PatchUser(ctx context.Context, userName string, patch app.UserPatch) (app.User, error) {
+	request := patchUserRequest{userName, patch}
+	response, err := e.PatchUserEndpoint(ctx, request)
+	if err != nil {
+		return app.User{}, err
+	}
+	resp := response.(patchUserResponse)
+	return resp.User, resp.Err
+}
+
 func (e Endpoints) DeleteUser(ctx context.Context, userName string) error {
 	request := deleteUserRequest{userName}
 	response, err := e.DeleteUserEndpoint(ctx, request)
@@ -86,6 +278,226 @@ func (e Endpoints) DeleteUser(ctx context.Context, userName string) error {
 	return resp.Err
 }
 
+func (e Endpoints) PostLogin(ctx context.Context, userName string) (app.User, error) {
+	request := postLoginRequest{userName}
+	response, err := e.PostLoginEndpoint(ctx, request)
+	if err != nil {
+		return app.User{}, err
+	}
+	resp := response.(postLoginResponse)
+	return resp.User, resp.Err
+}
+
+func (e Endpoints) PostUserStatus(ctx context.Context, userName string, change app.StatusChange) error {
+	request := postUserStatusRequest{userName, change}
+	response, err := e.PostUserStatusEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(postUserStatusResponse)
+	return resp.Err
+}
+
+func (e Endpoints) PostRestore(ctx context.Context, userName string) error {
+	request := postRestoreRequest{userName}
+	response, err := e.PostRestoreEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(postRestoreResponse)
+	return resp.Err
+}
+
+func (e Endpoints) PostRename(ctx context.Context, userName, newName, actor string) (app.User, error) {
+	request := postRenameRequest{userName, newName, actor}
+	response, err := e.PostRenameEndpoint(ctx, request)
+	if err != nil {
+		return app.User{}, err
+	}
+	resp := response.(postRenameResponse)
+	return resp.User, resp.Err
+}
+
+func (e Endpoints) PostUserRole(ctx context.Context, userName string, role app.Role, actor string) error {
+	request := postUserRoleRequest{userName, role, actor}
+	response, err := e.PostUserRoleEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(postUserRoleResponse)
+	return resp.Err
+}
+
+func (e Endpoints) DeleteUserRole(ctx context.Context, userName string, roleID int, actor string) error {
+	request := deleteUserRoleRequest{userName, roleID, actor}
+	response, err := e.DeleteUserRoleEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(deleteUserRoleResponse)
+	return resp.Err
+}
+
+func (e Endpoints) GetGroups(ctx context.Context) ([]app.Group, error) {
+	request := getGroupsRequest{}
+	response, err := e.GetGroupsEndpoint(ctx, request)
+	if err != nil {
+		return []app.Group{}, err
+	}
+	resp := response.(getGroupsResponse)
+	return resp.Groups, resp.Err
+}
+
+func (e Endpoints) PostGroup(ctx context.Context, group app.Group, actor string) (app.Group, error) {
+	request := postGroupRequest{group, actor}
+	response, err := e.PostGroupEndpoint(ctx, request)
+	if err != nil {
+		return app.Group{}, err
+	}
+	resp := response.(postGroupResponse)
+	return resp.Group, resp.Err
+}
+
+func (e Endpoints) PutGroup(ctx context.Context, group app.Group, actor string) error {
+	request := putGroupRequest{group, actor}
+	response, err := e.PutGroupEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(groupChangeResponse)
+	return resp.Err
+}
+
+func (e Endpoints) DeleteGroup(ctx context.Context, groupID int, actor string) error {
+	request := deleteGroupRequest{groupID, actor}
+	response, err := e.DeleteGroupEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(groupChangeResponse)
+	return resp.Err
+}
+
+func (e Endpoints) PostGroupMember(ctx context.Context, groupID int, userName, actor string) error {
+	request := groupMemberRequest{groupID, userName, actor}
+	response, err := e.PostGroupMemberEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(groupChangeResponse)
+	return resp.Err
+}
+
+func (e Endpoints) DeleteGroupMember(ctx context.Context, groupID int, userName, actor string) error {
+	request := groupMemberRequest{groupID, userName, actor}
+	response, err := e.DeleteGroupMemberEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(groupChangeResponse)
+	return resp.Err
+}
+
+func (e Endpoints) PostGroupManager(ctx context.Context, groupID int, userName, actor string) error {
+	request := groupMemberRequest{groupID, userName, actor}
+	response, err := e.PostGroupManagerEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(groupChangeResponse)
+	return resp.Err
+}
+
+func (e Endpoints) DeleteGroupManager(ctx context.Context, groupID int, userName, actor string) error {
+	request := groupMemberRequest{groupID, userName, actor}
+	response, err := e.DeleteGroupManagerEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(groupChangeResponse)
+	return resp.Err
+}
+
+func (e Endpoints) PostGroupRole(ctx context.Context, groupID int, role app.Role, actor string) error {
+	request := groupRoleRequest{groupID, role, actor}
+	response, err := e.PostGroupRoleEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(groupChangeResponse)
+	return resp.Err
+}
+
+func (e Endpoints) DeleteGroupRole(ctx context.Context, groupID, roleID int, actor string) error {
+	request := groupRoleRequest{groupID, app.Role{ID: roleID}, actor}
+	response, err := e.DeleteGroupRoleEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(groupChangeResponse)
+	return resp.Err
+}
+
+func (e Endpoints) RedeemInvitation(ctx context.Context, code string, user app.User) error {
+	request := postUserRequest{User: user, InviteCode: code}
+	response, err := e.PostUserEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(postUserResponse)
+	return resp.Err
+}
+
+func (e Endpoints) PostInvitation(ctx context.Context, invitation app.Invitation, createdBy string) (app.Invitation, error) {
+	request := postInvitationRequest{invitation, createdBy}
+	response, err := e.PostInvitationEndpoint(ctx, request)
+	if err != nil {
+		return app.Invitation{}, err
+	}
+	resp := response.(postInvitationResponse)
+	return resp.Invitation, resp.Err
+}
+
+func (e Endpoints) GetInvitations(ctx context.Context) ([]app.Invitation, error) {
+	request := getInvitationsRequest{}
+	response, err := e.GetInvitationsEndpoint(ctx, request)
+	if err != nil {
+		return []app.Invitation{}, err
+	}
+	resp := response.(getInvitationsResponse)
+	return resp.Invitations, resp.Err
+}
+
+func (e Endpoints) DeleteInvitation(ctx context.Context, id int) error {
+	request := deleteInvitationRequest{id}
+	response, err := e.DeleteInvitationEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	resp := response.(deleteInvitationResponse)
+	return resp.Err
+}
+
+func (e Endpoints) GetOrganizations(ctx context.Context) ([]app.Organization, error) {
+	request := getOrganizationsRequest{}
+	response, err := e.GetOrganizationsEndpoint(ctx, request)
+	if err != nil {
+		return []app.Organization{}, err
+	}
+	resp := response.(getOrganizationsResponse)
+	return resp.Organizations, resp.Err
+}
+
+func (e Endpoints) PostOrganization(ctx context.Context, org app.Organization, actor string) (app.Organization, error) {
+	request := postOrganizationRequest{org, actor}
+	response, err := e.PostOrganizationEndpoint(ctx, request)
+	if err != nil {
+		return app.Organization{}, err
+	}
+	resp := response.(postOrganizationResponse)
+	return resp.Organization, resp.Err
+}
+
 // ----------------------------------------------------------------------------------------------------------------------
 type getRolesRequest struct{}
 
@@ -94,6 +506,31 @@ type getRolesResponse struct {
 	Err   error      `json:"err,omitempty"`
 }
 
+func (r getRolesResponse) error() error { return r.Err }
+
+type getRolePermissionsRequest struct {
+	RoleID int
+}
+
+type getRolePermissionsResponse struct {
+	RoleID      int      `json:"role_id"`
+	Permissions []string `json:"permissions"`
+	Err         error    `json:"err,omitempty"`
+}
+
+func (r getRolePermissionsResponse) error() error { return r.Err }
+
+type putRoleRequest struct {
+	Role  app.Role
+	Actor string
+}
+
+type putRoleResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r putRoleResponse) error() error { return r.Err }
+
 type getUserRequest struct {
 	User string
 	Role string
@@ -104,7 +541,12 @@ type getUserResponse struct {
 	Err  error    `json:"err,omitempty"`
 }
 
+func (r getUserResponse) error() error { return r.Err }
+
+// getUsersRoleRequest with a Format is answered with a streamUsersResponse.
 type getUsersRoleRequest struct {
+	Filter app.UserFilter
+	Format string
 }
 
 type getUsersRoleResponse struct {
@@ -112,14 +554,96 @@ type getUsersRoleResponse struct {
 	Err   error      `json:"err,omitempty"`
 }
 
+func (r getUsersRoleResponse) error() error { return r.Err }
+
+type importUsersRequest struct {
+	Records []app.UserRecord
+	Options app.ImportOptions
+	Actor   string
+}
+
+type importUsersResponse struct {
+	Report app.ImportReport `json:"report"`
+	Err    error            `json:"err,omitempty"`
+}
+
+func (r importUsersResponse) error() error { return r.Err }
+
+type exportUsersRequest struct {
+	Format string
+}
+
+// streamUsersResponse is written row by row by encodeResponse while Stream reads the users
+// from the database. Records writes the rows in the import format instead of full users.
+type streamUsersResponse struct {
+	Format  string
+	Records bool
+	Stream  func(emit func(app.User) error) error
+}
+
+// exportUsersResponse holds the records of an export file read by MakeClientEndpoints.
+type exportUsersResponse struct {
+	Records []app.UserRecord
+}
+
+type batchUsersRequest struct {
+	Batch app.UserBatch
+	Actor string
+}
+
+type batchUsersResponse struct {
+	Report app.BatchReport `json:"report"`
+	Err    error           `json:"err,omitempty"`
+}
+
+func (r batchUsersResponse) error() error { return r.Err }
+
+type rolePlanRequest struct {
+	Desired app.DesiredRoles
+	Actor   string
+}
+
+type rolePlanResponse struct {
+	Plan app.RolePlan `json:"plan"`
+	Err  error        `json:"err,omitempty"`
+}
+
+func (r rolePlanResponse) error() error { return r.Err }
+
+type getRoleExpirationsRequest struct {
+	Within time.Duration
+	Filter app.UserFilter
+}
+
+type getRoleExpirationsResponse struct {
+	Users []app.User `json:"users,omitempty"`
+	Err   error      `json:"err,omitempty"`
+}
+
+func (r getRoleExpirationsResponse) error() error { return r.Err }
+
+type getAuditEventsRequest struct {
+	Filter app.AuditFilter
+}
+
+type getAuditEventsResponse struct {
+	Events []app.AuditEvent `json:"events,omitempty"`
+	Err    error            `json:"err,omitempty"`
+}
+
+func (r getAuditEventsResponse) error() error { return r.Err }
+
 type postUserRequest struct {
-	User app.User
+	User       app.User
+	InviteCode string
 }
 
 type postUserResponse struct {
 	Err error `json:"err,omitempty"`
 }
 
+func (r postUserResponse) error() error { return r.Err }
+
 type putUserRequest struct {
 	User app.User
 }
@@ -128,6 +652,20 @@ type putUserResponse struct {
 	Err error `json:"err,omitempty"`
 }
 
+func (r putUserResponse) error() error { return r.Err }
+
+type patchUserRequest struct {
+	UserName string
+	Patch    app.UserPatch
+}
+
+type patchUserResponse struct {
+	User app.User `json:"user"`
+	Err  error    `json:"err,omitempty"`
+}
+
+func (r patchUserResponse) error() error { return r.Err }
+
 type deleteUserRequest struct {
 	UserName string
 }
@@ -136,6 +674,179 @@ type deleteUserResponse struct {
 	Err error `json:"err,omitempty"`
 }
 
+func (r deleteUserResponse) error() error { return r.Err }
+
+type postLoginRequest struct {
+	UserName string
+}
+
+type postLoginResponse struct {
+	User app.User `json:"user,omitempty"`
+	Err  error    `json:"err,omitempty"`
+}
+
+func (r postLoginResponse) error() error { return r.Err }
+
+type postUserStatusRequest struct {
+	UserName string
+	Change   app.StatusChange
+}
+
+type postUserStatusResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r postUserStatusResponse) error() error { return r.Err }
+
+type postRestoreRequest struct {
+	UserName string
+}
+
+type postRestoreResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r postRestoreResponse) error() error { return r.Err }
+
+type postRenameRequest struct {
+	UserName string
+	NewName  string
+	Actor    string
+}
+
+type postRenameResponse struct {
+	User app.User `json:"user"`
+	Err  error    `json:"err,omitempty"`
+}
+
+func (r postRenameResponse) error() error { return r.Err }
+
+type postUserRoleRequest struct {
+	UserName string
+	Role     app.Role
+	Actor    string
+}
+
+type postUserRoleResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r postUserRoleResponse) error() error { return r.Err }
+
+type deleteUserRoleRequest struct {
+	UserName string
+	RoleID   int
+	Actor    string
+}
+
+type deleteUserRoleResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r deleteUserRoleResponse) error() error { return r.Err }
+
+type getGroupsRequest struct{}
+
+type getGroupsResponse struct {
+	Groups []app.Group `json:"groups,omitempty"`
+	Err    error       `json:"err,omitempty"`
+}
+
+func (r getGroupsResponse) error() error { return r.Err }
+
+type postGroupRequest struct {
+	Group app.Group
+	Actor string
+}
+
+type postGroupResponse struct {
+	Group app.Group `json:"group,omitempty"`
+	Err   error     `json:"err,omitempty"`
+}
+
+func (r postGroupResponse) error() error { return r.Err }
+
+type putGroupRequest struct {
+	Group app.Group
+	Actor string
+}
+
+type deleteGroupRequest struct {
+	GroupID int
+	Actor   string
+}
+
+type groupMemberRequest struct {
+	GroupID  int
+	UserName string
+	Actor    string
+}
+
+type groupRoleRequest struct {
+	GroupID int
+	Role    app.Role
+	Actor   string
+}
+
+// groupChangeResponse is the response of every group change that returns nothing but an error.
+type groupChangeResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r groupChangeResponse) error() error { return r.Err }
+
+type postInvitationRequest struct {
+	Invitation app.Invitation
+	CreatedBy  string
+}
+
+type postInvitationResponse struct {
+	Invitation app.Invitation `json:"invitation,omitempty"`
+	Err        error          `json:"err,omitempty"`
+}
+
+func (r postInvitationResponse) error() error { return r.Err }
+
+type getInvitationsRequest struct{}
+
+type getInvitationsResponse struct {
+	Invitations []app.Invitation `json:"invitations,omitempty"`
+	Err         error            `json:"err,omitempty"`
+}
+
+func (r getInvitationsResponse) error() error { return r.Err }
+
+type deleteInvitationRequest struct {
+	ID int
+}
+
+type deleteInvitationResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r deleteInvitationResponse) error() error { return r.Err }
+
+type getOrganizationsRequest struct{}
+
+type getOrganizationsResponse struct {
+	Organizations []app.Organization `json:"organizations,omitempty"`
+	Err           error              `json:"err,omitempty"`
+}
+
+func (r getOrganizationsResponse) error() error { return r.Err }
+
+type postOrganizationRequest struct {
+	Organization app.Organization
+	Actor        string
+}
+
+type postOrganizationResponse struct {
+	Organization app.Organization `json:"organization,omitempty"`
+	Err          error            `json:"err,omitempty"`
+}
+
+func (r postOrganizationResponse) error() error { return r.Err }
+
 // ----------------------------------------------------------------------------------------------------------------------
 func MakeGetRolesEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
@@ -144,6 +855,22 @@ func MakeGetRolesEndpoint(s Service) endpoint.Endpoint {
 	}
 }
 
+func MakeGetRolePermissionsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getRolePermissionsRequest)
+		t, e := s.GetRolePermissions(ctx, req.RoleID)
+		return getRolePermissionsResponse{req.RoleID, t, e}, nil
+	}
+}
+
+func MakePutRoleEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(putRoleRequest)
+		e := s.UpdateRole(ctx, req.Role, req.Actor)
+		return putRoleResponse{e}, nil
+	}
+}
+
 func MakeGetUserEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(getUserRequest)
@@ -154,15 +881,81 @@ func MakeGetUserEndpoint(s Service) endpoint.Endpoint {
 
 func MakeGetUsersRoleEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		//req := request.(getUsersRoleRequest)
-		t, e := s.GetUsersRole(ctx)
+		req := request.(getUsersRoleRequest)
+		if req.Format != "" {
+			return streamUsersResponse{Format: req.Format, Stream: func(emit func(app.User) error) error {
+				return s.StreamUsers(ctx, req.Filter, emit)
+			}}, nil
+		}
+		t, e := s.GetUsersRole(ctx, req.Filter)
 		return getUsersRoleResponse{t, e}, nil
 	}
 }
 
+func MakeImportUsersEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(importUsersRequest)
+		t, e := s.ImportUsers(ctx, req.Records, req.Options, req.Actor)
+		return importUsersResponse{t, e}, nil
+	}
+}
+
+func MakeExportUsersEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(exportUsersRequest)
+		return streamUsersResponse{Format: req.Format, Records: true, Stream: func(emit func(app.User) error) error {
+			return s.StreamUsers(ctx, app.UserFilter{}, emit)
+		}}, nil
+	}
+}
+
+func MakeBatchUsersEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(batchUsersRequest)
+		t, e := s.BatchUsers(ctx, req.Batch, req.Actor)
+		return batchUsersResponse{t, e}, nil
+	}
+}
+
+func MakePlanRolesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(rolePlanRequest)
+		t, e := s.PlanRoles(ctx, req.Desired)
+		return rolePlanResponse{t, e}, nil
+	}
+}
+
+func MakeApplyRolesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(rolePlanRequest)
+		t, e := s.ApplyRoles(ctx, req.Desired, req.Actor)
+		return rolePlanResponse{t, e}, nil
+	}
+}
+
+func MakeGetRoleExpirationsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getRoleExpirationsRequest)
+		t, e := s.GetRoleExpirations(ctx, req.Within, req.Filter)
+		return getRoleExpirationsResponse{t, e}, nil
+	}
+}
+
+func MakeGetAuditEventsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getAuditEventsRequest)
+		t, e := s.GetAuditEvents(ctx, req.Filter)
+		return getAuditEventsResponse{t, e}, nil
+	}
+}
+
 func MakePostUserEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(postUserRequest)
+		if req.InviteCode != "" {
+			e := s.RedeemInvitation(ctx, req.InviteCode, req.User)
+			return postUserResponse{e}, nil
+		}
 		e := s.AddUser(ctx, req.User)
 		return postUserResponse{e}, nil
 	}
@@ -176,6 +969,14 @@ func MakePutUserEndpoint(s Service) endpoint.Endpoint {
 	}
 }
 
+func MakePatchUserEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(patchUserRequest)
+		t, e := s.PatchUser(ctx, req.UserName, req.Patch)
+		return patchUserResponse{t, e}, nil
+	}
+}
+
 func MakeDeleteUserEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(deleteUserRequest)
@@ -183,3 +984,168 @@ func MakeDeleteUserEndpoint(s Service) endpoint.Endpoint {
 		return deleteUserResponse{e}, nil
 	}
 }
+
+func MakePostLoginEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(postLoginRequest)
+		t, e := s.RecordLogin(ctx, req.UserName)
+		return postLoginResponse{t, e}, nil
+	}
+}
+
+func MakePostUserStatusEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(postUserStatusRequest)
+		e := s.SetUserStatus(ctx, req.UserName, req.Change)
+		return postUserStatusResponse{e}, nil
+	}
+}
+
+func MakePostRestoreEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(postRestoreRequest)
+		e := s.RestoreUser(ctx, req.UserName)
+		return postRestoreResponse{e}, nil
+	}
+}
+
+func MakePostRenameEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(postRenameRequest)
+		t, e := s.RenameUser(ctx, req.UserName, req.NewName, req.Actor)
+		return postRenameResponse{t, e}, nil
+	}
+}
+
+func MakePostUserRoleEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(postUserRoleRequest)
+		e := s.GrantRole(ctx, req.UserName, req.Role, req.Actor)
+		return postUserRoleResponse{e}, nil
+	}
+}
+
+func MakeDeleteUserRoleEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteUserRoleRequest)
+		e := s.RevokeRole(ctx, req.UserName, req.RoleID, req.Actor)
+		return deleteUserRoleResponse{e}, nil
+	}
+}
+
+func MakeGetGroupsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		t, e := s.GetGroups(ctx)
+		return getGroupsResponse{t, e}, nil
+	}
+}
+
+func MakePostGroupEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(postGroupRequest)
+		t, e := s.CreateGroup(ctx, req.Group, req.Actor)
+		return postGroupResponse{t, e}, nil
+	}
+}
+
+func MakePutGroupEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(putGroupRequest)
+		e := s.UpdateGroup(ctx, req.Group, req.Actor)
+		return groupChangeResponse{e}, nil
+	}
+}
+
+func MakeDeleteGroupEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteGroupRequest)
+		e := s.DeleteGroup(ctx, req.GroupID, req.Actor)
+		return groupChangeResponse{e}, nil
+	}
+}
+
+func MakePostGroupMemberEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(groupMemberRequest)
+		e := s.AddGroupMember(ctx, req.GroupID, req.UserName, req.Actor)
+		return groupChangeResponse{e}, nil
+	}
+}
+
+func MakeDeleteGroupMemberEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(groupMemberRequest)
+		e := s.RemoveGroupMember(ctx, req.GroupID, req.UserName, req.Actor)
+		return groupChangeResponse{e}, nil
+	}
+}
+
+func MakePostGroupManagerEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(groupMemberRequest)
+		e := s.AddGroupManager(ctx, req.GroupID, req.UserName, req.Actor)
+		return groupChangeResponse{e}, nil
+	}
+}
+
+func MakeDeleteGroupManagerEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(groupMemberRequest)
+		e := s.RemoveGroupManager(ctx, req.GroupID, req.UserName, req.Actor)
+		return groupChangeResponse{e}, nil
+	}
+}
+
+func MakePostGroupRoleEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(groupRoleRequest)
+		e := s.GrantGroupRole(ctx, req.GroupID, req.Role, req.Actor)
+		return groupChangeResponse{e}, nil
+	}
+}
+
+func MakeDeleteGroupRoleEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(groupRoleRequest)
+		e := s.RevokeGroupRole(ctx, req.GroupID, req.Role.ID, req.Actor)
+		return groupChangeResponse{e}, nil
+	}
+}
+
+func MakePostInvitationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(postInvitationRequest)
+		t, e := s.CreateInvitation(ctx, req.Invitation, req.CreatedBy)
+		return postInvitationResponse{t, e}, nil
+	}
+}
+
+func MakeGetInvitationsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		t, e := s.GetInvitations(ctx)
+		return getInvitationsResponse{t, e}, nil
+	}
+}
+
+func MakeDeleteInvitationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteInvitationRequest)
+		e := s.RevokeInvitation(ctx, req.ID)
+		return deleteInvitationResponse{e}, nil
+	}
+}
+
+func MakeGetOrganizationsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		t, e := s.GetOrganizations(ctx)
+		return getOrganizationsResponse{t, e}, nil
+	}
+}
+
+func MakePostOrganizationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(postOrganizationRequest)
+		t, e := s.CreateOrganization(ctx, req.Organization, req.Actor)
+		return postOrganizationResponse{t, e}, nil
+	}
+}
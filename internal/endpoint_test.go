@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAuthenticated(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		ctx    context.Context
+		err    error
+		served bool
+	}{
+		{"not authenticated by the transport", context.Background(), ErrPreconditionRequired, false},
+		{"no token", context.WithValue(context.Background(), principalKey{},
+			authentication{err: ErrPreconditionRequired}), ErrPreconditionRequired, false},
+		{"unknown user", context.WithValue(context.Background(), principalKey{},
+			authentication{err: ErrForbidden}), ErrForbidden, false},
+		{"authenticated", context.WithValue(context.Background(), principalKey{},
+			authentication{caller: principal{User: "alice"}}), nil, true},
+	} {
+		served := false
+		_, err := authenticated(func(context.Context, interface{}) (interface{}, error) {
+			served = true
+			return nil, nil
+		})(tc.ctx, nil)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) || served != tc.served {
+			t.Errorf("%s: error %v, served %v, want %v, %v", tc.name, err, served, tc.err, tc.served)
+		}
+	}
+}
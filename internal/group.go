@@ -0,0 +1,328 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+)
+
+func (u userService) GetGroups(ctx context.Context) ([]app.Group, error) {
+	var groups []app.Group
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return groups, fmt.Errorf("GetGroups. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, `select to_json(t.*)
+					from (select ug.id, ug.name, ug.description, ug.parent as parent_id, ug.org_id,
+								(select array_agg(gm.user_name order by gm.user_name)
+									from group_member gm where gm.group_id = ug.id) as members,
+								(select array_agg(gm.user_name order by gm.user_name)
+									from group_manager gm where gm.group_id = ug.id) as managers,
+								(select json_agg(json_build_object('id', r.id, 'role_name', r.role_name) order by r.id)
+									from group_role gr join user_role r on r.id = gr.role
+									where gr.group_id = ug.id) as roles
+							from user_group ug
+							where org_visible(ug.org_id)
+							order by ug.id) t`)
+	if err != nil {
+		return groups, fmt.Errorf("GetGroups Query: %v\n", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res string
+		if err = rows.Scan(&res); err != nil {
+			return groups, fmt.Errorf("GetGroups rows.Scan: %v\n", err)
+		}
+		var result app.Group
+		if err = json.Unmarshal([]byte(res), &result); err != nil {
+			return groups, fmt.Errorf("GetGroups json.Unmarshal: %v\n", err)
+		}
+		groups = append(groups, result)
+	}
+	return groups, rows.Err()
+}
+
+func (u userService) CreateGroup(ctx context.Context, group app.Group, actor string) (app.Group, error) {
+	if err := checkGroup(&group); err != nil {
+		return app.Group{}, fmt.Errorf("CreateGroup: %w", err)
+	}
+
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return app.Group{}, fmt.Errorf("CreateGroup. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	group.OrgID = orgOf(ctx)
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if errC := checkGroupParent(ctx, tx, group); errC != nil {
+			return errC
+		}
+		errC := tx.QueryRow(ctx, `insert into user_group(name, description, parent, org_id) values($1, nullif($2, ''), $3, $4)
+				returning id`, group.Name, group.Description, group.ParentID, group.OrgID).Scan(&group.ID)
+		if errC != nil {
+			return errC
+		}
+		return recordAuditEvent(ctx, tx, actor, "group.created", "", map[string]interface{}{
+			"group_id": group.ID,
+			"name":     group.Name,
+		})
+	})
+	if err = groupError(err); err != nil {
+		return app.Group{}, fmt.Errorf("CreateGroup: %w", err)
+	}
+	return group, nil
+}
+
+// UpdateGroup changes the name, the description and the parent of a group.
+// The parent must not be the group itself or one of its subgroups.
+func (u userService) UpdateGroup(ctx context.Context, group app.Group, actor string) error {
+	if err := checkGroup(&group); err != nil {
+		return fmt.Errorf("UpdateGroup: %w", err)
+	}
+
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateGroup. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		//Concurrent updates could otherwise close a cycle between them
+		if _, errU := tx.Exec(ctx, `lock table user_group in share row exclusive mode`); errU != nil {
+			return errU
+		}
+		if errU := checkGroupParent(ctx, tx, group); errU != nil {
+			return errU
+		}
+		//Members lose the roles of the former parent groups
+		org, admins, errU := protectGroup(ctx, tx, group.ID)
+		if errU != nil {
+			return errU
+		}
+		if group.ParentID != nil {
+			var cycle bool
+			errU := tx.QueryRow(ctx, `with recursive ancestors(id) as (
+						select $2::int
+						union
+						select ug.parent from user_group ug join ancestors a on ug.id = a.id where ug.parent is not null)
+					select exists(select 1 from ancestors where id = $1)`, group.ID, *group.ParentID).Scan(&cycle)
+			if errU != nil {
+				return errU
+			}
+			if cycle {
+				return fmt.Errorf("parent %d of group %d would form a cycle: %w", *group.ParentID, group.ID, ErrInvalidArgument)
+			}
+		}
+		tag, errU := tx.Exec(ctx, `update user_group set name = $2, description = nullif($3, ''), parent = $4
+				where id = $1 and org_visible(org_id)`,
+			group.ID, group.Name, group.Description, group.ParentID)
+		if errU != nil {
+			return errU
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("group %d: %w", group.ID, ErrNotFound)
+		}
+		errU = recordAuditEvent(ctx, tx, actor, "group.updated", "", map[string]interface{}{
+			"group_id":  group.ID,
+			"name":      group.Name,
+			"parent_id": group.ParentID,
+		})
+		if errU != nil {
+			return errU
+		}
+		return checkOrganizationAdministrators(ctx, tx, org, admins)
+	})
+	if err = groupError(err); err != nil {
+		return fmt.Errorf("UpdateGroup: %w", err)
+	}
+	return nil
+}
+
+func (u userService) DeleteGroup(ctx context.Context, groupID int, actor string) error {
+	return u.changeGroup(ctx, "DeleteGroup", `delete from user_group where id = $1 and org_visible(org_id)`,
+		[]interface{}{groupID}, actor, "group.deleted", "", map[string]interface{}{"group_id": groupID})
+}
+
+func (u userService) AddGroupMember(ctx context.Context, groupID int, userName, actor string) error {
+	return u.changeGroup(ctx, "AddGroupMember", `insert into group_member(group_id, user_name)
+				select g.id, users.user_name from users join user_group g on g.org_id = users.org_id
+				where g.id = $1 and users.user_name = $2 and users.deleted_at is null and org_visible(g.org_id)
+			on conflict do nothing`,
+		[]interface{}{groupID, userName}, actor, "group.member_added", userName, map[string]interface{}{"group_id": groupID})
+}
+
+func (u userService) RemoveGroupMember(ctx context.Context, groupID int, userName, actor string) error {
+	return u.changeGroup(ctx, "RemoveGroupMember", `delete from group_member where group_id = $1 and user_name = $2
+				and exists(select 1 from user_group g where g.id = group_id and org_visible(g.org_id))`,
+		[]interface{}{groupID, userName}, actor, "group.member_removed", userName, map[string]interface{}{"group_id": groupID})
+}
+
+// AddGroupManager makes a user of the group's organization the team lead of the group.
+func (u userService) AddGroupManager(ctx context.Context, groupID int, userName, actor string) error {
+	return u.changeGroup(ctx, "AddGroupManager", `insert into group_manager(group_id, user_name)
+				select g.id, users.user_name from users join user_group g on g.org_id = users.org_id
+				where g.id = $1 and users.user_name = $2 and users.deleted_at is null and org_visible(g.org_id)
+			on conflict do nothing`,
+		[]interface{}{groupID, userName}, actor, "group.manager_added", userName, map[string]interface{}{"group_id": groupID})
+}
+
+func (u userService) RemoveGroupManager(ctx context.Context, groupID int, userName, actor string) error {
+	return u.changeGroup(ctx, "RemoveGroupManager", `delete from group_manager where group_id = $1 and user_name = $2
+				and exists(select 1 from user_group g where g.id = group_id and org_visible(g.org_id))`,
+		[]interface{}{groupID, userName}, actor, "group.manager_removed", userName, map[string]interface{}{"group_id": groupID})
+}
+
+func (u userService) GrantGroupRole(ctx context.Context, groupID int, role app.Role, actor string) error {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("GrantGroupRole. Unable to connect to database: %v\n", err)
+	}
+	err = resolveRole(ctx, conn, &role)
+	conn.Close(ctx)
+	if errors.Is(err, ErrInvalidArgument) {
+		return fmt.Errorf("GrantGroupRole: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("GrantGroupRole select role: %v\n", err)
+	}
+	if err = checkGrantable(role); err != nil {
+		return fmt.Errorf("GrantGroupRole: %w", err)
+	}
+
+	return u.changeGroup(ctx, "GrantGroupRole", `insert into group_role(group_id, role)
+				select g.id, r.id from user_group g join user_role r on r.org_id is null or r.org_id = g.org_id
+				where g.id = $1 and r.id = $2 and org_visible(g.org_id)
+			on conflict do nothing`,
+		[]interface{}{groupID, role.ID}, actor, "group.role_granted", "",
+		map[string]interface{}{"group_id": groupID, "role_id": role.ID})
+}
+
+func (u userService) RevokeGroupRole(ctx context.Context, groupID, roleID int, actor string) error {
+	return u.changeGroup(ctx, "RevokeGroupRole", `delete from group_role where group_id = $1 and role = $2
+				and exists(select 1 from user_group g where g.id = group_id and org_visible(g.org_id))`,
+		[]interface{}{groupID, roleID}, actor, "group.role_revoked", "",
+		map[string]interface{}{"group_id": groupID, "role_id": roleID})
+}
+
+// changeGroup runs a single statement on a group together with its audit event.
+// A statement that changes no row means that the group, the member or the grant
+// does not exist, except for inserts of rows that are already there.
+// Members may hold the administrator role through the group, so the statement
+// must leave the organization with MIN_ADMINISTRATORS administrators.
+func (u userService) changeGroup(ctx context.Context, method, sql string, args []interface{},
+	actor, action, userName string, details map[string]interface{}) error {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("%s. Unable to connect to database: %v\n", method, err)
+	}
+	defer conn.Close(ctx)
+
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		org, admins, errC := protectGroup(ctx, tx, args[0].(int))
+		if errC != nil {
+			return errC
+		}
+		tag, errC := tx.Exec(ctx, sql, args...)
+		if errC != nil {
+			return errC
+		}
+		if tag.RowsAffected() == 0 {
+			if !tag.Insert() {
+				return ErrNotFound
+			}
+			//Nothing inserted: the row exists already or the user does not
+			var exists bool
+			errC = tx.QueryRow(ctx, `select exists(select 1 from user_group where id = $1 and org_visible(org_id))
+					and ($2 = '' or exists(select 1 from users
+						where user_name = $2 and deleted_at is null and org_visible(org_id)))`,
+				args[0], userName).Scan(&exists)
+			if errC != nil {
+				return errC
+			}
+			if !exists {
+				return ErrNotFound
+			}
+			return nil
+		}
+		if errC = recordAuditEvent(ctx, tx, actor, action, userName, details); errC != nil {
+			return errC
+		}
+		return checkOrganizationAdministrators(ctx, tx, org, admins)
+	})
+	if isForeignKeyViolation(err) {
+		err = ErrNotFound
+	}
+	if err = groupError(err); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return nil
+}
+
+// protectGroup locks the administrators of the organization of a group before a change to the group.
+func protectGroup(ctx context.Context, tx pgx.Tx, groupID int) (org, admins int, err error) {
+	err = tx.QueryRow(ctx, `select org_id from user_group where id = $1 and org_visible(org_id)`, groupID).Scan(&org)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, fmt.Errorf("group %d: %w", groupID, ErrNotFound)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	admins, err = protectAdministrators(ctx, tx, org)
+	return org, admins, err
+}
+
+// checkGroup trims the name of a group and checks what needs no database: the name is required
+// and a group is not its own parent. Longer cycles are found by UpdateGroup.
+func checkGroup(group *app.Group) error {
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" {
+		return fmt.Errorf("name is required: %w", ErrInvalidArgument)
+	}
+	if group.ID != 0 && group.ParentID != nil && *group.ParentID == group.ID {
+		return fmt.Errorf("group %d cannot be its own parent: %w", group.ID, ErrInvalidArgument)
+	}
+	return nil
+}
+
+// checkGroupParent makes sure that the parent of a group is in the organization of the group.
+func checkGroupParent(ctx context.Context, tx pgx.Tx, group app.Group) error {
+	if group.ParentID == nil {
+		return nil
+	}
+	var ok bool
+	err := tx.QueryRow(ctx, `select exists(select 1 from user_group p
+				where p.id = $1 and org_visible(p.org_id)
+					and p.org_id = coalesce((select g.org_id from user_group g where g.id = $2), $3))`,
+		*group.ParentID, group.ID, group.OrgID).Scan(&ok)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("parent group %d: %w", *group.ParentID, ErrInvalidArgument)
+	}
+	return nil
+}
+
+// groupError maps constraint violations to the errors of the API.
+func groupError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isUniqueViolation(err):
+		return fmt.Errorf("group name: %w", ErrAlreadyExists)
+	case isForeignKeyViolation(err):
+		return fmt.Errorf("unknown group, parent group or role: %w", ErrInvalidArgument)
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrInvalidArgument), errors.Is(err, ErrLastAdministrator):
+		return err
+	default:
+		return fmt.Errorf("%v\n", err)
+	}
+}
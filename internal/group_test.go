@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgconn"
+	"net/http/httptest"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+)
+
+func TestCheckGroup(t *testing.T) {
+	parent := func(id int) *int { return &id }
+	for _, tc := range []struct {
+		name  string
+		group app.Group
+		want  string
+		err   error
+	}{
+		{"new group", app.Group{Name: "backend"}, "backend", nil},
+		{"trimmed name", app.Group{Name: "  backend\t"}, "backend", nil},
+		{"missing name", app.Group{Name: "   "}, "", ErrInvalidArgument},
+		{"subgroup", app.Group{ID: 4, Name: "backend", ParentID: parent(2)}, "backend", nil},
+		{"new subgroup", app.Group{Name: "backend", ParentID: parent(2)}, "backend", nil},
+		{"own parent", app.Group{ID: 4, Name: "backend", ParentID: parent(4)}, "backend", ErrInvalidArgument},
+	} {
+		group := tc.group
+		err := checkGroup(&group)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if err == nil && group.Name != tc.want {
+			t.Errorf("%s: name %q, want %q", tc.name, group.Name, tc.want)
+		}
+	}
+}
+
+func TestGroupError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no error", nil, nil},
+		{"duplicate name", &pgconn.PgError{Code: "23505"}, ErrAlreadyExists},
+		{"unknown parent or role", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23503"}), ErrInvalidArgument},
+		{"unknown group", fmt.Errorf("group 4: %w", ErrNotFound), ErrNotFound},
+		{"cycle", fmt.Errorf("parent 2 of group 4 would form a cycle: %w", ErrInvalidArgument), ErrInvalidArgument},
+		{"last administrator", ErrLastAdministrator, ErrLastAdministrator},
+	} {
+		err := groupError(tc.err)
+		if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	// Other errors are not passed on, they would leak details of the database
+	if err := groupError(errors.New("connection reset")); err == nil || errors.Unwrap(err) != nil {
+		t.Errorf("other error: %v", err)
+	}
+}
+
+func TestDecodeGroupRequests(t *testing.T) {
+	manager := principal{User: "ann", Permissions: map[string]bool{PermGroupsManage: true}}
+	reader := principal{User: "ed", Permissions: map[string]bool{PermUsersRead: true}}
+	create, update, remove := decodePostGroupRequest, decodePutGroupRequest, decodeDeleteGroupRequest
+
+	for _, tc := range []struct {
+		name   string
+		decode httptransport.DecodeRequestFunc
+		caller principal
+		id     string
+		body   string
+		err    error
+	}{
+		{"create", create, manager, "", `{"name":"backend","parent_id":2}`, nil},
+		{"create without groups.manage", create, reader, "", `{"name":"backend"}`, ErrForbidden},
+		{"update", update, manager, "4", `{"name":"backend"}`, nil},
+		{"update of another group", update, manager, "4", `{"id":5,"name":"backend"}`, ErrInconsistentIDs},
+		{"update with a bad id", update, manager, "four", `{"name":"backend"}`, ErrInvalidArgument},
+		{"update without groups.manage", update, reader, "4", `{"name":"backend"}`, ErrForbidden},
+		{"delete", remove, manager, "4", "", nil},
+		{"delete without groups.manage", remove, reader, "4", "", ErrForbidden},
+	} {
+		r := httptest.NewRequest("POST", "/v1/groups", strings.NewReader(tc.body))
+		r.Header.Set("Content-Type", "application/json")
+		r = mux.SetURLVars(r, map[string]string{"id": tc.id})
+		ctx := context.WithValue(context.Background(), principalKey{}, authentication{caller: tc.caller})
+		_, err := tc.decode(ctx, r)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
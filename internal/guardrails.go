@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+)
+
+// roleAdministrator is the role that every organization keeps at least MIN_ADMINISTRATORS
+// active holders of. Superadmins are created in the database only.
+const roleAdministrator = "administrator"
+
+func minAdministrators() int {
+	return app.GetEnvAsInt("MIN_ADMINISTRATORS", 1)
+}
+
+// protectAccount runs first in the transaction of a change to userName. It rejects changes to
+// superadmins and locks the administrators of the user's organization until the transaction ends,
+// so that concurrent changes cannot remove the last of them between the check and the commit.
+// It returns the number of active administrators before the change for checkAdministrators.
+func protectAccount(ctx context.Context, tx pgx.Tx, userName string) (int, error) {
+	var (
+		org        int
+		superadmin bool
+	)
+	err := tx.QueryRow(ctx, `select users.org_id, exists(select 1 from user_roles x join user_role r on r.id = x.role
+				where x.user_name = users.user_name and lower(r.role_name) = $2)
+			from users where users.user_name = $1`, userName, roleSuperadmin).Scan(&org, &superadmin)
+	if errors.Is(err, pgx.ErrNoRows) {
+		//The change reports unknown users
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if superadmin {
+		return 0, fmt.Errorf("superadmin %s cannot be changed through the API: %w", userName, ErrForbidden)
+	}
+	return protectAdministrators(ctx, tx, org)
+}
+
+// checkAdministrators runs last in the transaction of a change to userName and fails it
+// when the change leaves the organization with fewer than MIN_ADMINISTRATORS administrators.
+// Organizations that are already below the minimum are not blocked by unrelated changes.
+func checkAdministrators(ctx context.Context, tx pgx.Tx, userName string, before int) error {
+	var org int
+	err := tx.QueryRow(ctx, `select org_id from users where user_name = $1`, userName).Scan(&org)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return checkOrganizationAdministrators(ctx, tx, org, before)
+}
+
+// protectAdministrators locks the administrators of the organization like protectAccount
+// and returns their number for checkOrganizationAdministrators.
+func protectAdministrators(ctx context.Context, tx pgx.Tx, org int) (int, error) {
+	rows, err := tx.Query(ctx, `select users.user_name from users
+			where users.org_id = $1 and `+holdsAdministrator+`
+			for update of users`, org, roleAdministrator)
+	if err != nil {
+		return 0, err
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	return countAdministrators(ctx, tx, org)
+}
+
+func checkOrganizationAdministrators(ctx context.Context, tx pgx.Tx, org, before int) error {
+	after, err := countAdministrators(ctx, tx, org)
+	if err != nil {
+		return err
+	}
+	if after < before && after < minAdministrators() {
+		return fmt.Errorf("%d administrators must remain: %w", minAdministrators(), ErrLastAdministrator)
+	}
+	return nil
+}
+
+// holdsAdministrator matches users that hold the administrator role, given by $2, directly
+// or through one of their groups. A primary role past its role_expires_at does not count.
+const holdsAdministrator = `(exists(select 1 from user_roles x join user_role r on r.id = x.role
+				where x.user_name = users.user_name and lower(r.role_name) = $2
+					and (users.role <> x.role or users.role_expires_at is null or users.role_expires_at > now()))
+			or exists(select 1 from user_effective_group eg
+					join group_role gr on gr.group_id = eg.group_id join user_role r on r.id = gr.role
+				where eg.user_name = users.user_name and lower(r.role_name) = $2))`
+
+func countAdministrators(ctx context.Context, tx pgx.Tx, org int) (int, error) {
+	var count int
+	err := tx.QueryRow(ctx, `select count(*) from users
+			where users.org_id = $1 and users.deleted_at is null
+				and (users.status = 'active' or (users.status = 'locked' and users.locked_until <= now()))
+				and `+holdsAdministrator,
+		org, roleAdministrator).Scan(&count)
+	return count, err
+}
+
+// isGuardError tells the errors of the API apart from database errors.
+func isGuardError(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden) || errors.Is(err, ErrLastAdministrator) ||
+		errors.Is(err, ErrInvalidArgument) || errors.Is(err, ErrAlreadyExists) || errors.Is(err, ErrValidation)
+}
+
+// checkGrantable rejects roles that are not assigned through the API.
+func checkGrantable(role app.Role) error {
+	if strings.EqualFold(role.Role, roleSuperadmin) {
+		return fmt.Errorf("role %s is assigned in the database only: %w", role.Role, ErrForbidden)
+	}
+	return nil
+}
+
+// checkSelfChange keeps callers from deleting, disabling, locking or demoting themselves
+// by accident. The request has to confirm it with ?force=true.
+func checkSelfChange(caller principal, userName string, force bool) error {
+	if userName != caller.User || force {
+		return nil
+	}
+	return fmt.Errorf("%s cannot change their own account without force=true: %w", userName, ErrForbidden)
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+)
+
+// fakeTx answers QueryRow like db, other methods of pgx.Tx are not used by the tests.
+type fakeTx struct {
+	pgx.Tx
+	db *fakeDB
+}
+
+func (tx fakeTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return tx.db.QueryRow(ctx, sql, args...)
+}
+
+func TestCheckSelfChange(t *testing.T) {
+	caller := principal{User: "alice"}
+	for _, tc := range []struct {
+		name, userName string
+		force          bool
+		err            error
+	}{
+		{"other user", "bob", false, nil},
+		{"own account", "alice", false, ErrForbidden},
+		{"own account with force", "alice", true, nil},
+		{"other user with force", "bob", true, nil},
+	} {
+		err := checkSelfChange(caller, tc.userName, tc.force)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestCheckGrantable(t *testing.T) {
+	for _, tc := range []struct {
+		role app.Role
+		err  error
+	}{
+		{app.Role{ID: 1, Role: roleAdministrator}, nil},
+		{app.Role{ID: 3, Role: "user"}, nil},
+		{app.Role{ID: 9, Role: roleSuperadmin}, ErrForbidden},
+		{app.Role{ID: 9, Role: "SuperAdmin"}, ErrForbidden},
+	} {
+		err := checkGrantable(tc.role)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.role.Role, err, tc.err)
+		}
+	}
+}
+
+// TestProtectSuperadmin checks that changes to superadmins are refused before anything is locked.
+func TestProtectSuperadmin(t *testing.T) {
+	db := &fakeDB{row: fakeRow{values: []interface{}{1, true}}}
+	if _, err := protectAccount(context.Background(), fakeTx{db: db}, "root"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("error %v, want ErrForbidden", err)
+	}
+	if len(db.queries) != 1 {
+		t.Errorf("%d queries, want 1", len(db.queries))
+	}
+}
+
+// TestCheckStatusUnchanged checks that PUT /user and batch updates cannot disable or lock a user,
+// the own account included, past the status endpoints and their force=true.
+func TestCheckStatusUnchanged(t *testing.T) {
+	for _, tc := range []struct {
+		name, status string
+		row          fakeRow
+		err          error
+	}{
+		{"omitted", "", fakeRow{err: errors.New("not queried")}, nil},
+		{"unchanged", app.UserStatusActive, fakeRow{values: []interface{}{app.UserStatusActive}}, nil},
+		{"disabled", app.UserStatusDisabled, fakeRow{values: []interface{}{app.UserStatusActive}}, ErrValidation},
+		{"locked", app.UserStatusLocked, fakeRow{values: []interface{}{app.UserStatusActive}}, ErrValidation},
+		{"enabled", app.UserStatusActive, fakeRow{values: []interface{}{app.UserStatusLocked}}, ErrValidation},
+		{"unknown user", app.UserStatusActive, fakeRow{err: pgx.ErrNoRows}, ErrNotFound},
+	} {
+		tx := fakeTx{db: &fakeDB{row: tc.row}}
+		err := checkStatusUnchanged(context.Background(), tx, app.User{Name: "alice", Status: tc.status})
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
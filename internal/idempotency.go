@@ -0,0 +1,332 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"testgenerate_backend_user/internal/app"
+	"time"
+)
+
+// IdempotentResponse is the response stored for an Idempotency-Key and replayed for repeats.
+type IdempotentResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// IdempotencyStore keeps the keys of mutating requests. Keys belong to a scope, the organization
+// and the user of the request, and live until their TTL ends.
+type IdempotencyStore interface {
+	// Reserve claims the key for a request with the fingerprint. It returns nil when the request
+	// should run, the stored response when it ran already, ErrIdempotencyKeyReused when the key was
+	// used for a different request and ErrIdempotencyInProgress while the first request still runs.
+	Reserve(ctx context.Context, scope, key, fingerprint string, ttl time.Duration) (*IdempotentResponse, error)
+	// Complete stores the response of a reserved key.
+	Complete(ctx context.Context, scope, key string, resp IdempotentResponse) error
+	// Release forgets a reserved key, so the request can be retried.
+	Release(ctx context.Context, scope, key string) error
+	// Purge deletes the expired keys.
+	Purge(ctx context.Context) (int64, error)
+}
+
+// NewIdempotencyStore returns the store named by IDEMPOTENCY_STORE: postgres, shared by all
+// instances, or memory for a single instance.
+func NewIdempotencyStore(kind string) (IdempotencyStore, error) {
+	switch kind {
+	case "postgres":
+		return postgresIdempotencyStore{}, nil
+	case "memory":
+		return newMemoryIdempotencyStore(), nil
+	default:
+		return nil, fmt.Errorf("idempotency store %q is not one of postgres, memory", kind)
+	}
+}
+
+// idempotencyTTL is how long a response is replayed for its key.
+func idempotencyTTL() time.Duration {
+	return time.Duration(app.GetEnvAsInt("IDEMPOTENCY_TTL_HOURS", 24)) * time.Hour
+}
+
+// idempotency replays the stored response of POST, PUT, PATCH and DELETE requests that repeat an
+// Idempotency-Key. Requests without the header are passed through, and so are the requests of
+// callers that identify does not authenticate, as their keys could not be kept apart. Responses
+// with a server error are not stored, so the request can be retried with the same key.
+func idempotency(store IdempotencyStore, logger UnitLogHandler,
+	identify func(ctx context.Context, r *http.Request) (principal, error)) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				key = ""
+			}
+			if key == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			if len(key) > 255 {
+				encodeError(ctx, fmt.Errorf("Idempotency-Key is longer than 255 characters: %w", ErrInvalidArgument), w)
+				return
+			}
+			caller, err := identify(ctx, r)
+			if err != nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBytes(r)))
+			if err != nil {
+				encodeError(ctx, decodeError(err), w)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scope := idempotencyScope(caller)
+			stored, err := store.Reserve(ctx, scope, key, requestFingerprint(r, body), idempotencyTTL())
+			if err != nil {
+				encodeError(ctx, err, w)
+				return
+			}
+			if stored != nil {
+				for name, values := range stored.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.Status)
+				_, _ = w.Write(stored.Body)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(rec, r)
+			if rec.status >= http.StatusInternalServerError {
+				err = store.Release(ctx, scope, key)
+			} else {
+				err = store.Complete(ctx, scope, key, IdempotentResponse{rec.status, w.Header().Clone(), rec.body.Bytes()})
+			}
+			if err != nil {
+				logger.Handle(ctx, err)
+			}
+		})
+	}
+}
+
+// idempotencyScope keeps the keys of different users and organizations apart. It is the
+// organization the authenticated caller works in, "all" for superadmins without X-Tenant-ID.
+func idempotencyScope(caller principal) string {
+	tenant := strconv.Itoa(caller.Tenant)
+	if caller.AllTenants {
+		tenant = "all"
+	}
+	return tenant + "/" + caller.User
+}
+
+// requestFingerprint tells a repeated request apart from another request with the same key.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	_, _ = hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder writes the response through and keeps a copy for the store.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush keeps streamed listings streaming through the recorder.
+func (rec *responseRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+type postgresIdempotencyStore struct{}
+
+func (postgresIdempotencyStore) Reserve(ctx context.Context, scope, key, fingerprint string,
+	ttl time.Duration) (*IdempotentResponse, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Reserve. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	//Expired keys are taken over as if they were new
+	var reserved bool
+	err = conn.QueryRow(ctx, `insert into idempotency_key(scope, key, fingerprint, expires_at)
+				values($1, $2, $3, now() + make_interval(secs => $4))
+			on conflict (scope, key) do update set fingerprint = excluded.fingerprint, status = null,
+				header = null, body = null, create_time = now(), expires_at = excluded.expires_at
+				where idempotency_key.expires_at <= now()
+			returning true`, scope, key, fingerprint, ttl.Seconds()).Scan(&reserved)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("Reserve insert into idempotency_key: %v\n", err)
+	}
+
+	var (
+		stored  string
+		status  *int
+		header  []byte
+		content []byte
+	)
+	err = conn.QueryRow(ctx, `select fingerprint, status, header, body from idempotency_key
+			where scope = $1 and key = $2`, scope, key).Scan(&stored, &status, &header, &content)
+	if err != nil {
+		return nil, fmt.Errorf("Reserve select idempotency_key: %v\n", err)
+	}
+	if stored != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if status == nil {
+		return nil, ErrIdempotencyInProgress
+	}
+	resp := &IdempotentResponse{Status: *status, Body: content}
+	if err = json.Unmarshal(header, &resp.Header); err != nil {
+		return nil, fmt.Errorf("Reserve json.Unmarshal: %v\n", err)
+	}
+	return resp, nil
+}
+
+func (postgresIdempotencyStore) Complete(ctx context.Context, scope, key string, resp IdempotentResponse) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return fmt.Errorf("Complete json.Marshal: %v\n", err)
+	}
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("Complete. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, `update idempotency_key set status = $3, header = $4, body = $5
+			where scope = $1 and key = $2`, scope, key, resp.Status, header, resp.Body)
+	if err != nil {
+		return fmt.Errorf("Complete update idempotency_key: %v\n", err)
+	}
+	return nil
+}
+
+func (postgresIdempotencyStore) Release(ctx context.Context, scope, key string) error {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("Release. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, `delete from idempotency_key where scope = $1 and key = $2 and status is null`, scope, key)
+	if err != nil {
+		return fmt.Errorf("Release delete from idempotency_key: %v\n", err)
+	}
+	return nil
+}
+
+func (postgresIdempotencyStore) Purge(ctx context.Context) (int64, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Purge. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tag, err := conn.Exec(ctx, `delete from idempotency_key where expires_at <= now()`)
+	if err != nil {
+		return 0, fmt.Errorf("Purge delete from idempotency_key: %v\n", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+type memoryIdempotencyKey struct {
+	fingerprint string
+	resp        *IdempotentResponse
+	expiresAt   time.Time
+}
+
+type memoryIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]*memoryIdempotencyKey
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{keys: map[string]*memoryIdempotencyKey{}}
+}
+
+func (m *memoryIdempotencyStore) Reserve(_ context.Context, scope, key, fingerprint string,
+	ttl time.Duration) (*IdempotentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.keys[scope+"\n"+key]
+	switch {
+	case !ok || !entry.expiresAt.After(time.Now()):
+		m.keys[scope+"\n"+key] = &memoryIdempotencyKey{fingerprint: fingerprint, expiresAt: time.Now().Add(ttl)}
+		return nil, nil
+	case entry.fingerprint != fingerprint:
+		return nil, ErrIdempotencyKeyReused
+	case entry.resp == nil:
+		return nil, ErrIdempotencyInProgress
+	}
+	return entry.resp, nil
+}
+
+func (m *memoryIdempotencyStore) Complete(_ context.Context, scope, key string, resp IdempotentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.keys[scope+"\n"+key]; ok {
+		entry.resp = &resp
+	}
+	return nil
+}
+
+func (m *memoryIdempotencyStore) Release(_ context.Context, scope, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.keys[scope+"\n"+key]; ok && entry.resp == nil {
+		delete(m.keys, scope+"\n"+key)
+	}
+	return nil
+}
+
+func (m *memoryIdempotencyStore) Purge(_ context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for k, entry := range m.keys {
+		if !entry.expiresAt.After(time.Now()) {
+			delete(m.keys, k)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestFingerprint(t *testing.T) {
+	base := requestFingerprint(httptest.NewRequest("POST", "/v1/user?invite=a", nil), []byte(`{"user_name":"alice"}`))
+	for _, tc := range []struct {
+		name, method, target, body string
+		same                       bool
+	}{
+		{"same request", "POST", "/v1/user?invite=a", `{"user_name":"alice"}`, true},
+		{"other method", "PUT", "/v1/user?invite=a", `{"user_name":"alice"}`, false},
+		{"other path", "POST", "/user?invite=a", `{"user_name":"alice"}`, false},
+		{"other query", "POST", "/v1/user?invite=b", `{"user_name":"alice"}`, false},
+		{"other body", "POST", "/v1/user?invite=a", `{"user_name":"bob"}`, false},
+		{"whitespace in body", "POST", "/v1/user?invite=a", `{"user_name": "alice"}`, false},
+	} {
+		got := requestFingerprint(httptest.NewRequest(tc.method, tc.target, nil), []byte(tc.body))
+		if (got == base) != tc.same {
+			t.Errorf("%s: fingerprint equal %v, want %v", tc.name, got == base, tc.same)
+		}
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryIdempotencyStore()
+	resp := IdempotentResponse{Status: http.StatusCreated, Body: []byte(`{}`)}
+
+	for _, step := range []struct {
+		name     string
+		do       func() (*IdempotentResponse, error)
+		replayed bool
+		err      error
+	}{
+		{"first request runs", func() (*IdempotentResponse, error) {
+			return store.Reserve(ctx, "1/alice", "k", "f", time.Hour)
+		}, false, nil},
+		{"repeat while running", func() (*IdempotentResponse, error) {
+			return store.Reserve(ctx, "1/alice", "k", "f", time.Hour)
+		}, false, ErrIdempotencyInProgress},
+		{"released key runs again", func() (*IdempotentResponse, error) {
+			_ = store.Release(ctx, "1/alice", "k")
+			return store.Reserve(ctx, "1/alice", "k", "f", time.Hour)
+		}, false, nil},
+		{"completed key replays", func() (*IdempotentResponse, error) {
+			_ = store.Complete(ctx, "1/alice", "k", resp)
+			return store.Reserve(ctx, "1/alice", "k", "f", time.Hour)
+		}, true, nil},
+		{"release keeps completed keys", func() (*IdempotentResponse, error) {
+			_ = store.Release(ctx, "1/alice", "k")
+			return store.Reserve(ctx, "1/alice", "k", "f", time.Hour)
+		}, true, nil},
+		{"other request with the key", func() (*IdempotentResponse, error) {
+			return store.Reserve(ctx, "1/alice", "k", "g", time.Hour)
+		}, false, ErrIdempotencyKeyReused},
+		{"other scope", func() (*IdempotentResponse, error) {
+			return store.Reserve(ctx, "1/bob", "k", "g", time.Hour)
+		}, false, nil},
+		{"expired key runs again", func() (*IdempotentResponse, error) {
+			if _, err := store.Reserve(ctx, "2/alice", "k", "f", -time.Second); err != nil {
+				return nil, err
+			}
+			_ = store.Complete(ctx, "2/alice", "k", resp)
+			return store.Reserve(ctx, "2/alice", "k", "g", time.Hour)
+		}, false, nil},
+	} {
+		stored, err := step.do()
+		if !errors.Is(err, step.err) || (step.err == nil && err != nil) {
+			t.Fatalf("%s: error %v, want %v", step.name, err, step.err)
+		}
+		if (stored != nil) != step.replayed {
+			t.Fatalf("%s: replayed %v, want %v", step.name, stored != nil, step.replayed)
+		}
+	}
+
+	if _, err := store.Reserve(ctx, "3/alice", "k", "f", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if purged, _ := store.Purge(ctx); purged != 1 {
+		t.Errorf("purged %d keys, want 1", purged)
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	calls := 0
+	status := http.StatusCreated
+	callers := map[string]principal{
+		"alice":     {User: "alice", Tenant: 1},
+		"bob":       {User: "bob", Tenant: 1},
+		"alice@2":   {User: "alice", Tenant: 2},
+		"superuser": {User: "alice", Tenant: 1, AllTenants: true},
+	}
+	identify := func(_ context.Context, r *http.Request) (principal, error) {
+		caller, ok := callers[r.Header.Get("Authorization")]
+		if !ok {
+			return principal{}, ErrPreconditionRequired
+		}
+		return caller, nil
+	}
+	h := idempotency(newMemoryIdempotencyStore(), UnitLogHandler{}, identify)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	send := func(method, caller, body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/user", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		req.Header.Set("Authorization", caller)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, tc := range []struct {
+		name, method, caller, body, key string
+		serverError                     bool
+		status, calls                   int
+		replayed                        bool
+	}{
+		{"first request", "POST", "alice", `{"user_name":"alice"}`, "a", false, http.StatusCreated, 1, false},
+		{"repeat", "POST", "alice", `{"user_name":"alice"}`, "a", false, http.StatusCreated, 1, true},
+		{"key reused", "POST", "alice", `{"user_name":"bob"}`, "a", false, http.StatusUnprocessableEntity, 1, false},
+		{"GET ignores the key", "GET", "alice", "", "a", false, http.StatusCreated, 2, false},
+		{"other user", "POST", "bob", `{"user_name":"alice"}`, "a", false, http.StatusCreated, 3, false},
+		{"other organization", "POST", "alice@2", `{"user_name":"alice"}`, "a", false, http.StatusCreated, 4, false},
+		{"all organizations", "POST", "superuser", `{"user_name":"alice"}`, "a", false, http.StatusCreated, 5, false},
+		{"anonymous", "POST", "", `{"user_name":"alice"}`, "a", false, http.StatusCreated, 6, false},
+		{"anonymous repeat", "POST", "", `{"user_name":"alice"}`, "a", false, http.StatusCreated, 7, false},
+		{"server error", "POST", "alice", `{}`, "b", true, http.StatusInternalServerError, 8, false},
+		{"retry after a server error", "POST", "alice", `{}`, "b", false, http.StatusCreated, 9, false},
+		{"key too long", "POST", "alice", `{}`, strings.Repeat("k", 256), false, http.StatusBadRequest, 9, false},
+		{"body too large", "POST", "alice", strings.Repeat(" ", 2<<20), "c", false, http.StatusRequestEntityTooLarge, 9, false},
+	} {
+		status = http.StatusCreated
+		if tc.serverError {
+			status = http.StatusInternalServerError
+		}
+		rec := send(tc.method, tc.caller, tc.body, tc.key)
+		if rec.Code != tc.status || calls != tc.calls || (rec.Header().Get("Idempotent-Replayed") == "true") != tc.replayed {
+			t.Errorf("%s: status %d, calls %d, replayed %q, want %d, %d, %v", tc.name, rec.Code, calls,
+				rec.Header().Get("Idempotent-Replayed"), tc.status, tc.calls, tc.replayed)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+)
+
+// ImportUsers creates, and with Upsert updates, users from an import file. Every row runs in
+// a savepoint, so a failing row does not disturb the others; see app.ImportOptions for how the
+// transactions are cut. Rows are validated like single POST and PUT /user requests.
+func (u userService) ImportUsers(ctx context.Context, records []app.UserRecord, opts app.ImportOptions,
+	actor string) (app.ImportReport, error) {
+	report := app.ImportReport{Total: len(records), Rows: make([]app.ImportRow, 0, len(records))}
+	if opts.ChunkSize < 0 {
+		return report, fmt.Errorf("ImportUsers: chunk_size %d: %w", opts.ChunkSize, ErrInvalidArgument)
+	}
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return report, fmt.Errorf("ImportUsers. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	chunk := opts.ChunkSize
+	if chunk == 0 {
+		chunk = len(records)
+	}
+	report.Committed = !opts.DryRun
+	for start := 0; start < len(records); start += chunk {
+		end := start + chunk
+		if end > len(records) {
+			end = len(records)
+		}
+		rows, errI := importChunk(ctx, conn, records[start:end], opts, actor)
+		if errI != nil {
+			return report, fmt.Errorf("ImportUsers: %v\n", errI)
+		}
+		report.Rows = append(report.Rows, rows...)
+	}
+
+	for _, row := range report.Rows {
+		switch row.Result {
+		case "created":
+			report.Created++
+		case "updated":
+			report.Updated++
+		case "failed":
+			report.Failed++
+		}
+	}
+	//A single transaction is all or nothing
+	if opts.ChunkSize == 0 && report.Failed > 0 {
+		report.Committed = false
+	}
+	return report, nil
+}
+
+// importChunk imports rows in one transaction. It is rolled back for dry runs
+// and, when the import is not chunked, if any row failed.
+func importChunk(ctx context.Context, conn *pgx.Conn, records []app.UserRecord, opts app.ImportOptions,
+	actor string) ([]app.ImportRow, error) {
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rows := make([]app.ImportRow, 0, len(records))
+	failed := false
+	for _, record := range records {
+		row := app.ImportRow{Line: record.Line, UserName: record.UserName}
+		sp, errS := tx.Begin(ctx)
+		if errS != nil {
+			return nil, errS
+		}
+		row.Result, errS = importRecord(ctx, sp, record, opts.Upsert, actor)
+		if errS != nil {
+			_ = sp.Rollback(ctx)
+			if !isGuardError(errS) {
+				return nil, errS
+			}
+			row.Result, row.Error, failed = "failed", errS.Error(), true
+		} else if errS = sp.Commit(ctx); errS != nil {
+			return nil, errS
+		}
+		rows = append(rows, row)
+	}
+	if opts.DryRun || (failed && opts.ChunkSize == 0) {
+		return rows, nil
+	}
+	return rows, tx.Commit(ctx)
+}
+
+// importRecord creates or updates one user and returns "created" or "updated".
+func importRecord(ctx context.Context, tx pgx.Tx, record app.UserRecord, upsert bool, actor string) (string, error) {
+	user := app.User{
+		Name:        normalizeUserName(record.UserName),
+		Email:       record.Email,
+		DisplayName: record.DisplayName,
+		Status:      record.Status,
+		Locale:      record.Locale,
+		Timezone:    record.Timezone,
+	}
+	if user.Name == "" {
+		return "", fmt.Errorf("user_name is required: %w", ErrInvalidArgument)
+	}
+	if err := validateProfile(&user); err != nil {
+		return "", err
+	}
+	if record.Role == "" {
+		record.Role = "user"
+	}
+
+	ids := map[string]int{}
+	names := append([]string{record.Role}, record.Roles...)
+	for _, name := range names {
+		role := app.Role{Role: name}
+		if err := resolveRole(ctx, tx, &role); err != nil {
+			return "", err
+		}
+		if err := checkGrantable(role); err != nil {
+			return "", err
+		}
+		ids[strings.ToLower(name)] = role.ID
+	}
+	roleID := ids[strings.ToLower(record.Role)]
+
+	//Names differing in case only are the same user, which keeps its stored name
+	var current string
+	err := tx.QueryRow(ctx, `select coalesce((select user_name from users
+				where lower(user_name) = lower($1) and deleted_at is null and org_visible(org_id)), '')`,
+		user.Name).Scan(&current)
+	if err != nil {
+		return "", err
+	}
+	exists := current != ""
+	if exists {
+		user.Name = current
+	}
+
+	result := "created"
+	if exists {
+		if !upsert {
+			return "", fmt.Errorf("user %s: %w", user.Name, ErrAlreadyExists)
+		}
+		if errU := checkStatusUnchanged(ctx, tx, user); errU != nil {
+			return "", errU
+		}
+		admins, errU := protectAccount(ctx, tx, user.Name)
+		if errU != nil {
+			return "", errU
+		}
+		_, errU = tx.Exec(ctx, `update users set role = $2, email = nullif($3, ''), display_name = nullif($4, ''),
+					locale = nullif($5, ''), timezone = nullif($6, ''), role_expires_at = null, fallback_role = null
+				where user_name = $1`,
+			user.Name, roleID, user.Email, user.DisplayName, user.Locale, user.Timezone)
+		if isUniqueViolation(errU) {
+			return "", fmt.Errorf("email %s: %w", user.Email, ErrAlreadyExists)
+		}
+		if errU != nil {
+			return "", errU
+		}
+		if errU = replaceRoles(ctx, tx, user.Name, names, ids); errU != nil {
+			return "", errU
+		}
+		if errU = checkAdministrators(ctx, tx, user.Name, admins); errU != nil {
+			return "", errU
+		}
+		result = "updated"
+	} else {
+		if user.Name, err = checkUserName("user_name", user.Name); err != nil {
+			return "", err
+		}
+		if err = insertUser(ctx, tx, user, roleID, orgOf(ctx)); err != nil {
+			return "", err
+		}
+		if user.Status != "" && user.Status != app.UserStatusActive {
+			_, err = tx.Exec(ctx, `update users set status = $2, status_changed_at = now(), status_changed_by = $3
+					where user_name = $1`, user.Name, user.Status, actor)
+			if err != nil {
+				return "", err
+			}
+		}
+		if err = replaceRoles(ctx, tx, user.Name, names, ids); err != nil {
+			return "", err
+		}
+	}
+
+	err = recordAuditEvent(ctx, tx, actor, "user.imported", user.Name, map[string]interface{}{
+		"result": result,
+		"roles":  names,
+	})
+	return result, err
+}
+
+// ExportUsers returns every user of the organization in the format of ImportUsers.
+func (u userService) ExportUsers(ctx context.Context) ([]app.UserRecord, error) {
+	var records []app.UserRecord
+	err := u.StreamUsers(ctx, app.UserFilter{}, func(user app.User) error {
+		records = append(records, userRecordOf(user))
+		return nil
+	})
+	return records, err
+}
@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+	"time"
+)
+
+// Invitation codes are HS256 JWTs signed with SECRET_KEY that carry only the invitation id.
+// The row in user_invitation stays the source of truth: it decides whether the code is
+// still redeemable, so a code can be revoked and is accepted only once.
+const invitationClaim = "invitation"
+
+func (u userService) CreateInvitation(ctx context.Context, invitation app.Invitation, createdBy string) (app.Invitation, error) {
+	invitation.UserName = normalizeUserName(invitation.UserName)
+	invitation.Email = strings.TrimSpace(invitation.Email)
+	if invitation.UserName == "" && invitation.Email == "" {
+		return app.Invitation{}, fmt.Errorf("CreateInvitation: user_name or email is required: %w", ErrInvalidArgument)
+	}
+	//The invited user redeems under this name, so it has to pass the rules now
+	if invitation.UserName != "" {
+		if _, err := checkUserName("user_name", invitation.UserName); err != nil {
+			return app.Invitation{}, fmt.Errorf("CreateInvitation: %w", err)
+		}
+	}
+	if invitation.ExpiresAt == nil {
+		expiresAt := time.Now().Add(time.Duration(app.GetEnvAsInt("INVITATION_TTL_HOURS", 72)) * time.Hour)
+		invitation.ExpiresAt = &expiresAt
+	}
+	if !invitation.ExpiresAt.After(time.Now()) {
+		return app.Invitation{}, fmt.Errorf("CreateInvitation: expires_at is in the past: %w", ErrInvalidArgument)
+	}
+
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return app.Invitation{}, fmt.Errorf("CreateInvitation. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	role := app.Role{ID: invitation.RoleID, Role: invitation.Role}
+	err = resolveRole(ctx, conn, &role)
+	if errors.Is(err, ErrInvalidArgument) {
+		return app.Invitation{}, fmt.Errorf("CreateInvitation: %w", err)
+	}
+	if err != nil {
+		return app.Invitation{}, fmt.Errorf("CreateInvitation select role: %v\n", err)
+	}
+	if err = checkGrantable(role); err != nil {
+		return app.Invitation{}, fmt.Errorf("CreateInvitation: %w", err)
+	}
+	invitation.RoleID, invitation.Role = role.ID, role.Role
+
+	var createTime time.Time
+	invitation.OrgID = orgOf(ctx)
+	err = conn.QueryRow(ctx, `insert into user_invitation(user_name, email, role, created_by, expires_at, org_id)
+				values(nullif($1, ''), nullif($2, ''), $3, $4, $5, $6) returning id, create_time`,
+		invitation.UserName, invitation.Email, invitation.RoleID, createdBy, *invitation.ExpiresAt, invitation.OrgID).
+		Scan(&invitation.ID, &createTime)
+	if err != nil {
+		return app.Invitation{}, fmt.Errorf("CreateInvitation insert into user_invitation: %v\n", err)
+	}
+	invitation.CreateTime = &createTime
+	invitation.CreatedBy = createdBy
+	invitation.Status = "pending"
+
+	invitation.Code, err = signInvitationCode(invitation.ID, *invitation.ExpiresAt)
+	if err != nil {
+		return app.Invitation{}, fmt.Errorf("CreateInvitation sign code: %v\n", err)
+	}
+	return invitation, nil
+}
+
+func (u userService) GetInvitations(ctx context.Context) ([]app.Invitation, error) {
+	var invitations []app.Invitation
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return invitations, fmt.Errorf("GetInvitations. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	rows, errRows := conn.Query(ctx, `select to_json(t.*)
+					from (select ui.id, ui.user_name, ui.email, ur.role_name, ui.role as role_id,
+								case when ui.redeemed_at is not null then 'redeemed'
+									 when ui.revoked_at is not null then 'revoked'
+									 when ui.expires_at < now() then 'expired'
+									 else 'pending' end as status,
+								ui.created_by, ui.create_time, ui.expires_at,
+								ui.redeemed_at, ui.redeemed_by, ui.revoked_at, ui.org_id
+							from user_invitation ui left join user_role ur on ur.id = ui.role
+							where org_visible(ui.org_id)
+							order by ui.id) t`)
+	if errRows != nil {
+		return invitations, fmt.Errorf("GetInvitations Query: %v\n", errRows)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res string
+		if errScan := rows.Scan(&res); errScan != nil {
+			return invitations, fmt.Errorf("GetInvitations rows.Scan: %v\n", errScan)
+		}
+		var result app.Invitation
+		if errU := json.Unmarshal([]byte(res), &result); errU != nil {
+			return invitations, fmt.Errorf("GetInvitations json.Unmarshal: %v\n", errU)
+		}
+		invitations = append(invitations, result)
+	}
+	return invitations, rows.Err()
+}
+
+func (u userService) RevokeInvitation(ctx context.Context, id int) error {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("RevokeInvitation. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tag, err := conn.Exec(ctx, `update user_invitation set revoked_at = now()
+				where id = $1 and redeemed_at is null and revoked_at is null and org_visible(org_id)`, id)
+	if err != nil {
+		return fmt.Errorf("RevokeInvitation conn.Exec: %v\n", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("RevokeInvitation: pending invitation %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (u userService) RedeemInvitation(ctx context.Context, code string, userAdd app.User) error {
+	var errR error
+	id, err := parseInvitationCode(code)
+	if err != nil {
+		return fmt.Errorf("RedeemInvitation: %v: %w", err, ErrInvitationInvalid)
+	}
+	if userAdd.Name, err = checkUserName("user_name", userAdd.Name); err != nil {
+		return fmt.Errorf("RedeemInvitation: %w", err)
+	}
+	if err = validateProfile(&userAdd); err != nil {
+		return fmt.Errorf("RedeemInvitation: %w", err)
+	}
+
+	//The caller is not signed in yet, the invitation decides the organization of the new user
+	conn, err := connectDB(app.WithAllTenants(ctx))
+	if err != nil {
+		return fmt.Errorf("RedeemInvitation. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("RedeemInvitation conn.BeginTx %v\n", err)
+	}
+	defer func() {
+		if errR != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	var (
+		roleID, orgID     int
+		email             *string
+		usable, forMember bool
+	)
+	errR = tx.QueryRow(ctx, `select role, org_id, email,
+					redeemed_at is null and revoked_at is null and expires_at > now(),
+					user_name is null or lower(user_name) = lower($2)
+				from user_invitation where id = $1 for update`, id, userAdd.Name).
+		Scan(&roleID, &orgID, &email, &usable, &forMember)
+	if errors.Is(errR, pgx.ErrNoRows) {
+		errR = fmt.Errorf("RedeemInvitation: invitation %d: %w", id, ErrInvitationInvalid)
+		return errR
+	}
+	if errR != nil {
+		errR = fmt.Errorf("RedeemInvitation select invitation: %v\n", errR)
+		return errR
+	}
+	if !usable {
+		errR = fmt.Errorf("RedeemInvitation: invitation %d: %w", id, ErrInvitationInvalid)
+		return errR
+	}
+	if !forMember {
+		errR = fmt.Errorf("RedeemInvitation: invitation %d is issued for another user: %w", id, ErrForbidden)
+		return errR
+	}
+
+	//The code was delivered to the invited address, so it becomes the user's email
+	if email != nil {
+		userAdd.Email = *email
+	}
+	errR = insertUser(ctx, tx, userAdd, roleID, orgID)
+	if errors.Is(errR, ErrAlreadyExists) {
+		errR = fmt.Errorf("RedeemInvitation: %w", errR)
+		return errR
+	}
+	if errR != nil {
+		errR = fmt.Errorf("RedeemInvitation insert into users: %v\n", errR)
+		return errR
+	}
+
+	_, errR = tx.Exec(ctx, `update user_invitation set redeemed_at = now(), redeemed_by = $2 where id = $1`,
+		id, userAdd.Name)
+	if errR != nil {
+		errR = fmt.Errorf("RedeemInvitation update user_invitation: %v\n", errR)
+		return errR
+	}
+	return nil
+}
+
+// CleanupInvitations removes invitations that expired without being redeemed.
+// Redeemed invitations are kept as the record of who onboarded whom.
+func (u userService) CleanupInvitations(ctx context.Context) (int64, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("CleanupInvitations. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tag, err := conn.Exec(ctx, `delete from user_invitation
+			where redeemed_at is null and expires_at < now() and org_visible(org_id)`)
+	if err != nil {
+		return 0, fmt.Errorf("CleanupInvitations conn.Exec: %v\n", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+func signInvitationCode(id int, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		invitationClaim: id,
+		"exp":           expiresAt.Unix(),
+	})
+	return token.SignedString([]byte(app.GetEnv("SECRET_KEY", "secretkey")))
+}
+
+func parseInvitationCode(code string) (int, error) {
+	token, err := jwt.Parse(code, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(app.GetEnv("SECRET_KEY", "secretkey")), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return 0, errors.New("invalid invitation code")
+	}
+	id, ok := claims[invitationClaim].(float64)
+	if !ok {
+		return 0, errors.New("not invitation code")
+	}
+	return int(id), nil
+}
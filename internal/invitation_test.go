@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+	"time"
+)
+
+func TestInvitationCode(t *testing.T) {
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+		code, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	secret := []byte("secretkey")
+	valid, err := signInvitationCode(42, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := signInvitationCode(42, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name, code string
+		id         int
+		ok         bool
+	}{
+		{"signed code", valid, 42, true},
+		{"expired", expired, 0, false},
+		{"other secret", sign(jwt.SigningMethodHS256, []byte("other"),
+			jwt.MapClaims{invitationClaim: 42, "exp": time.Now().Add(time.Hour).Unix()}), 0, false},
+		{"unsigned", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType,
+			jwt.MapClaims{invitationClaim: 42}), 0, false},
+		{"session token", sign(jwt.SigningMethodHS256, secret, jwt.MapClaims{"user": "alice", "role": "admin"}), 0, false},
+		{"id is not a number", sign(jwt.SigningMethodHS256, secret, jwt.MapClaims{invitationClaim: "42"}), 0, false},
+		{"tampered", valid[:len(valid)-2] + "xx", 0, false},
+		{"not a token", "invite-me", 0, false},
+		{"empty", "", 0, false},
+	} {
+		id, err := parseInvitationCode(tc.code)
+		if id != tc.id || (err == nil) != tc.ok {
+			t.Errorf("%s: %d, %v, want %d and ok %v", tc.name, id, err, tc.id, tc.ok)
+		}
+	}
+}
+
+// TestRedeemInvalidInvitation redeems codes that are refused before the database is asked.
+func TestRedeemInvalidInvitation(t *testing.T) {
+	expired, err := signInvitationCode(7, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{"", "invite-me", expired} {
+		err := userService{}.RedeemInvitation(context.Background(), code, app.User{Name: "alice"})
+		if !errors.Is(err, ErrInvitationInvalid) {
+			t.Errorf("code %q: %v, want %v", code, err, ErrInvitationInvalid)
+		}
+	}
+}
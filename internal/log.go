@@ -10,10 +10,10 @@ type ErrorHandler interface {
 }
 
 type UnitLogHandler struct {
-	logger logrus.Logger
+	logger *logrus.Logger
 }
 
-func NewUnitLogHandler(logger logrus.Logger) *UnitLogHandler {
+func NewUnitLogHandler(logger *logrus.Logger) *UnitLogHandler {
 	return &UnitLogHandler{
 		logger: logger,
 	}
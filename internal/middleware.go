@@ -45,14 +45,14 @@ func (mw loggingMiddleware) GetUser(ctx context.Context, userName, userRole stri
 	return mw.next.GetUser(ctx, userName, userRole)
 }
 
-func (mw loggingMiddleware) GetUsersRole(ctx context.Context) (users []app.User, err error) {
+func (mw loggingMiddleware) GetUsersRole(ctx context.Context, filter app.UserFilter) (users []app.User, err error) {
 	defer func(begin time.Time) {
 		mw.logger.WithFields(logrus.Fields{
 			"took":  time.Since(begin).Milliseconds(),
 			"error": err,
 		}).Info("method == GetUsersRole")
 	}(time.Now())
-	return mw.next.GetUsersRole(ctx)
+	return mw.next.GetUsersRole(ctx, filter)
 }
 
 func (mw loggingMiddleware) AddUser(ctx context.Context, userAdd app.User) (err error) {
@@ -85,6 +85,388 @@ func (mw loggingMiddleware) DeleteUser(ctx context.Context, userName string) (er
 	return mw.next.DeleteUser(ctx, userName)
 }
 
+func (mw loggingMiddleware) CreateInvitation(ctx context.Context, invitation app.Invitation, createdBy string) (res app.Invitation, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == CreateInvitation")
+	}(time.Now())
+	return mw.next.CreateInvitation(ctx, invitation, createdBy)
+}
+
+func (mw loggingMiddleware) GetInvitations(ctx context.Context) (invitations []app.Invitation, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == GetInvitations")
+	}(time.Now())
+	return mw.next.GetInvitations(ctx)
+}
+
+func (mw loggingMiddleware) RevokeInvitation(ctx context.Context, id int) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == RevokeInvitation")
+	}(time.Now())
+	return mw.next.RevokeInvitation(ctx, id)
+}
+
+func (mw loggingMiddleware) RedeemInvitation(ctx context.Context, code string, userAdd app.User) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == RedeemInvitation")
+	}(time.Now())
+	return mw.next.RedeemInvitation(ctx, code, userAdd)
+}
+
+func (mw loggingMiddleware) CleanupInvitations(ctx context.Context) (deleted int64, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":    time.Since(begin).Milliseconds(),
+			"deleted": deleted,
+			"error":   err,
+		}).Info("method == CleanupInvitations")
+	}(time.Now())
+	return mw.next.CleanupInvitations(ctx)
+}
+
+func (mw loggingMiddleware) RecordLogin(ctx context.Context, userName string) (user app.User, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == RecordLogin")
+	}(time.Now())
+	return mw.next.RecordLogin(ctx, userName)
+}
+
+func (mw loggingMiddleware) SetUserStatus(ctx context.Context, userName string, change app.StatusChange) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == SetUserStatus")
+	}(time.Now())
+	return mw.next.SetUserStatus(ctx, userName, change)
+}
+
+func (mw loggingMiddleware) UnlockExpiredAccounts(ctx context.Context) (unlocked int64, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == UnlockExpiredAccounts")
+	}(time.Now())
+	return mw.next.UnlockExpiredAccounts(ctx)
+}
+
+func (mw loggingMiddleware) CountUsersByStatus(ctx context.Context) (counts map[string]int, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == CountUsersByStatus")
+	}(time.Now())
+	return mw.next.CountUsersByStatus(ctx)
+}
+
+func (mw loggingMiddleware) GetAuditEvents(ctx context.Context, filter app.AuditFilter) (events []app.AuditEvent, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"users": len(filter.UserNames),
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == GetAuditEvents")
+	}(time.Now())
+	return mw.next.GetAuditEvents(ctx, filter)
+}
+
+func (mw loggingMiddleware) RestoreUser(ctx context.Context, userName string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == RestoreUser")
+	}(time.Now())
+	return mw.next.RestoreUser(ctx, userName)
+}
+
+func (mw loggingMiddleware) PurgeDeletedUsers(ctx context.Context) (purged int64, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == PurgeDeletedUsers")
+	}(time.Now())
+	return mw.next.PurgeDeletedUsers(ctx)
+}
+
+func (mw loggingMiddleware) GetRoleExpirations(ctx context.Context, within time.Duration, filter app.UserFilter) (users []app.User, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == GetRoleExpirations")
+	}(time.Now())
+	return mw.next.GetRoleExpirations(ctx, within, filter)
+}
+
+func (mw loggingMiddleware) ExpireRoleGrants(ctx context.Context) (expired int64, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == ExpireRoleGrants")
+	}(time.Now())
+	return mw.next.ExpireRoleGrants(ctx)
+}
+
+func (mw loggingMiddleware) GrantRole(ctx context.Context, userName string, role app.Role, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == GrantRole")
+	}(time.Now())
+	return mw.next.GrantRole(ctx, userName, role, actor)
+}
+
+func (mw loggingMiddleware) RevokeRole(ctx context.Context, userName string, roleID int, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == RevokeRole")
+	}(time.Now())
+	return mw.next.RevokeRole(ctx, userName, roleID, actor)
+}
+
+func (mw loggingMiddleware) GetRolePermissions(ctx context.Context, roleID int) (permissions []string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == GetRolePermissions")
+	}(time.Now())
+	return mw.next.GetRolePermissions(ctx, roleID)
+}
+
+func (mw loggingMiddleware) UpdateRole(ctx context.Context, role app.Role, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == UpdateRole")
+	}(time.Now())
+	return mw.next.UpdateRole(ctx, role, actor)
+}
+
+func (mw loggingMiddleware) GetGroups(ctx context.Context) (groups []app.Group, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == GetGroups")
+	}(time.Now())
+	return mw.next.GetGroups(ctx)
+}
+
+func (mw loggingMiddleware) CreateGroup(ctx context.Context, group app.Group, actor string) (res app.Group, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == CreateGroup")
+	}(time.Now())
+	return mw.next.CreateGroup(ctx, group, actor)
+}
+
+func (mw loggingMiddleware) UpdateGroup(ctx context.Context, group app.Group, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == UpdateGroup")
+	}(time.Now())
+	return mw.next.UpdateGroup(ctx, group, actor)
+}
+
+func (mw loggingMiddleware) DeleteGroup(ctx context.Context, groupID int, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == DeleteGroup")
+	}(time.Now())
+	return mw.next.DeleteGroup(ctx, groupID, actor)
+}
+
+func (mw loggingMiddleware) AddGroupMember(ctx context.Context, groupID int, userName, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == AddGroupMember")
+	}(time.Now())
+	return mw.next.AddGroupMember(ctx, groupID, userName, actor)
+}
+
+func (mw loggingMiddleware) RemoveGroupMember(ctx context.Context, groupID int, userName, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == RemoveGroupMember")
+	}(time.Now())
+	return mw.next.RemoveGroupMember(ctx, groupID, userName, actor)
+}
+
+func (mw loggingMiddleware) GrantGroupRole(ctx context.Context, groupID int, role app.Role, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == GrantGroupRole")
+	}(time.Now())
+	return mw.next.GrantGroupRole(ctx, groupID, role, actor)
+}
+
+func (mw loggingMiddleware) RevokeGroupRole(ctx context.Context, groupID, roleID int, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == RevokeGroupRole")
+	}(time.Now())
+	return mw.next.RevokeGroupRole(ctx, groupID, roleID, actor)
+}
+
+func (mw loggingMiddleware) GetOrganizations(ctx context.Context) (orgs []app.Organization, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == GetOrganizations")
+	}(time.Now())
+	return mw.next.GetOrganizations(ctx)
+}
+
+func (mw loggingMiddleware) CreateOrganization(ctx context.Context, org app.Organization, actor string) (created app.Organization, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == CreateOrganization")
+	}(time.Now())
+	return mw.next.CreateOrganization(ctx, org, actor)
+}
+
+func (mw loggingMiddleware) AddGroupManager(ctx context.Context, groupID int, userName, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == AddGroupManager")
+	}(time.Now())
+	return mw.next.AddGroupManager(ctx, groupID, userName, actor)
+}
+
+func (mw loggingMiddleware) RemoveGroupManager(ctx context.Context, groupID int, userName, actor string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == RemoveGroupManager")
+	}(time.Now())
+	return mw.next.RemoveGroupManager(ctx, groupID, userName, actor)
+}
+
+func (mw loggingMiddleware) PlanRoles(ctx context.Context, desired app.DesiredRoles) (plan app.RolePlan, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == PlanRoles")
+	}(time.Now())
+	return mw.next.PlanRoles(ctx, desired)
+}
+
+func (mw loggingMiddleware) ApplyRoles(ctx context.Context, desired app.DesiredRoles, actor string) (plan app.RolePlan, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == ApplyRoles")
+	}(time.Now())
+	return mw.next.ApplyRoles(ctx, desired, actor)
+}
+
+func (mw loggingMiddleware) ImportUsers(ctx context.Context, records []app.UserRecord, opts app.ImportOptions, actor string) (report app.ImportReport, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == ImportUsers")
+	}(time.Now())
+	return mw.next.ImportUsers(ctx, records, opts, actor)
+}
+
+func (mw loggingMiddleware) ExportUsers(ctx context.Context) (records []app.UserRecord, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == ExportUsers")
+	}(time.Now())
+	return mw.next.ExportUsers(ctx)
+}
+
+func (mw loggingMiddleware) StreamUsers(ctx context.Context, filter app.UserFilter, emit func(app.User) error) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == StreamUsers")
+	}(time.Now())
+	return mw.next.StreamUsers(ctx, filter, emit)
+}
+
+func (mw loggingMiddleware) BatchUsers(ctx context.Context, batch app.UserBatch, actor string) (report app.BatchReport, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == BatchUsers")
+	}(time.Now())
+	return mw.next.BatchUsers(ctx, batch, actor)
+}
+
+func (mw loggingMiddleware) PatchUser(ctx context.Context, userName string, patch app.UserPatch) (user app.User, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == PatchUser")
+	}(time.Now())
+	return mw.next.PatchUser(ctx, userName, patch)
+}
+
+func (mw loggingMiddleware) RenameUser(ctx context.Context, userName, newName, actor string) (user app.User, err error) {
+	defer func(begin time.Time) {
+		mw.logger.WithFields(logrus.Fields{
+			"took":  time.Since(begin).Milliseconds(),
+			"error": err,
+		}).Info("method == RenameUser")
+	}(time.Now())
+	return mw.next.RenameUser(ctx, userName, newName, actor)
+}
+
 // ----------------------------------------------------------------------------------------------------------------------
 type instrumentingMiddleware struct {
 	requestCount   metrics.Counter
@@ -92,72 +474,452 @@ type instrumentingMiddleware struct {
 	next           Service
 }
 
-func InstrumentingMiddleware(requestCount metrics.Counter, requestLatency metrics.Histogram) Middleware {
-	return func(next Service) Service {
-		return instrumentingMiddleware{
-			requestCount:   requestCount,
-			requestLatency: requestLatency,
-			next:           next,
-		}
-	}
+func InstrumentingMiddleware(requestCount metrics.Counter, requestLatency metrics.Histogram) Middleware {
+	return func(next Service) Service {
+		return instrumentingMiddleware{
+			requestCount:   requestCount,
+			requestLatency: requestLatency,
+			next:           next,
+		}
+	}
+}
+
+func (im instrumentingMiddleware) GetRoles(ctx context.Context) (roles []app.Role, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "getRoles", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	roles, err = im.next.GetRoles(ctx)
+	return
+}
+
+func (im instrumentingMiddleware) GetUser(ctx context.Context, userName, userRole string) (user app.User, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "getUser", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	user, err = im.next.GetUser(ctx, userName, userRole)
+	return
+}
+
+func (im instrumentingMiddleware) GetUsersRole(ctx context.Context, filter app.UserFilter) (users []app.User, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "getUserRole", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	users, err = im.next.GetUsersRole(ctx, filter)
+	return
+}
+
+func (im instrumentingMiddleware) AddUser(ctx context.Context, userAdd app.User) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "addUser", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.AddUser(ctx, userAdd)
+	return
+}
+
+func (im instrumentingMiddleware) UpdateUser(ctx context.Context, user app.User) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "updateUser", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.UpdateUser(ctx, user)
+	return
+}
+
+func (im instrumentingMiddleware) DeleteUser(ctx context.Context, userName string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "deleteUser", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.DeleteUser(ctx, userName)
+	return
 }
 
-func (im instrumentingMiddleware) GetRoles(ctx context.Context) (roles []app.Role, err error) {
+func (im instrumentingMiddleware) CreateInvitation(ctx context.Context, invitation app.Invitation, createdBy string) (res app.Invitation, err error) {
 	defer func(begin time.Time) {
-		lvs := []string{"method", "getRoles", "error", fmt.Sprint(err != nil)}
+		lvs := []string{"method", "createInvitation", "error", fmt.Sprint(err != nil)}
 		im.requestCount.With(lvs...).Add(1)
 		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	roles, err = im.next.GetRoles(ctx)
+	res, err = im.next.CreateInvitation(ctx, invitation, createdBy)
 	return
 }
 
-func (im instrumentingMiddleware) GetUser(ctx context.Context, userName, userRole string) (user app.User, err error) {
+func (im instrumentingMiddleware) GetInvitations(ctx context.Context) (invitations []app.Invitation, err error) {
 	defer func(begin time.Time) {
-		lvs := []string{"method", "getUser", "error", fmt.Sprint(err != nil)}
+		lvs := []string{"method", "getInvitations", "error", fmt.Sprint(err != nil)}
 		im.requestCount.With(lvs...).Add(1)
 		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	user, err = im.next.GetUser(ctx, userName, userRole)
+	invitations, err = im.next.GetInvitations(ctx)
 	return
 }
 
-func (im instrumentingMiddleware) GetUsersRole(ctx context.Context) (users []app.User, err error) {
+func (im instrumentingMiddleware) RevokeInvitation(ctx context.Context, id int) (err error) {
 	defer func(begin time.Time) {
-		lvs := []string{"method", "getUserRole", "error", fmt.Sprint(err != nil)}
+		lvs := []string{"method", "revokeInvitation", "error", fmt.Sprint(err != nil)}
 		im.requestCount.With(lvs...).Add(1)
 		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	users, err = im.next.GetUsersRole(ctx)
+	err = im.next.RevokeInvitation(ctx, id)
 	return
 }
 
-func (im instrumentingMiddleware) AddUser(ctx context.Context, userAdd app.User) (err error) {
+func (im instrumentingMiddleware) RedeemInvitation(ctx context.Context, code string, userAdd app.User) (err error) {
 	defer func(begin time.Time) {
-		lvs := []string{"method", "addUser", "error", fmt.Sprint(err != nil)}
+		lvs := []string{"method", "redeemInvitation", "error", fmt.Sprint(err != nil)}
 		im.requestCount.With(lvs...).Add(1)
 		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	err = im.next.AddUser(ctx, userAdd)
+	err = im.next.RedeemInvitation(ctx, code, userAdd)
 	return
 }
 
-func (im instrumentingMiddleware) UpdateUser(ctx context.Context, user app.User) (err error) {
+func (im instrumentingMiddleware) CleanupInvitations(ctx context.Context) (deleted int64, err error) {
 	defer func(begin time.Time) {
-		lvs := []string{"method", "updateUser", "error", fmt.Sprint(err != nil)}
+		lvs := []string{"method", "cleanupInvitations", "error", fmt.Sprint(err != nil)}
 		im.requestCount.With(lvs...).Add(1)
 		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	err = im.next.UpdateUser(ctx, user)
+	deleted, err = im.next.CleanupInvitations(ctx)
 	return
 }
 
-func (im instrumentingMiddleware) DeleteUser(ctx context.Context, userName string) (err error) {
+func (im instrumentingMiddleware) RecordLogin(ctx context.Context, userName string) (user app.User, err error) {
 	defer func(begin time.Time) {
-		lvs := []string{"method", "deleteUser", "error", fmt.Sprint(err != nil)}
+		lvs := []string{"method", "recordLogin", "error", fmt.Sprint(err != nil)}
 		im.requestCount.With(lvs...).Add(1)
 		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	err = im.next.DeleteUser(ctx, userName)
+	user, err = im.next.RecordLogin(ctx, userName)
+	return
+}
+
+func (im instrumentingMiddleware) SetUserStatus(ctx context.Context, userName string, change app.StatusChange) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "setUserStatus", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.SetUserStatus(ctx, userName, change)
+	return
+}
+
+func (im instrumentingMiddleware) UnlockExpiredAccounts(ctx context.Context) (unlocked int64, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "unlockExpiredAccounts", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	unlocked, err = im.next.UnlockExpiredAccounts(ctx)
+	return
+}
+
+func (im instrumentingMiddleware) CountUsersByStatus(ctx context.Context) (counts map[string]int, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "countUsersByStatus", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	counts, err = im.next.CountUsersByStatus(ctx)
+	return
+}
+
+func (im instrumentingMiddleware) GetAuditEvents(ctx context.Context, filter app.AuditFilter) (events []app.AuditEvent, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "getAuditEvents", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	events, err = im.next.GetAuditEvents(ctx, filter)
+	return
+}
+
+func (im instrumentingMiddleware) RestoreUser(ctx context.Context, userName string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "restoreUser", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.RestoreUser(ctx, userName)
+	return
+}
+
+func (im instrumentingMiddleware) PurgeDeletedUsers(ctx context.Context) (purged int64, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "purgeDeletedUsers", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	purged, err = im.next.PurgeDeletedUsers(ctx)
+	return
+}
+
+func (im instrumentingMiddleware) GetRoleExpirations(ctx context.Context, within time.Duration, filter app.UserFilter) (users []app.User, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "getRoleExpirations", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	users, err = im.next.GetRoleExpirations(ctx, within, filter)
+	return
+}
+
+func (im instrumentingMiddleware) ExpireRoleGrants(ctx context.Context) (expired int64, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "expireRoleGrants", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	expired, err = im.next.ExpireRoleGrants(ctx)
+	return
+}
+
+func (im instrumentingMiddleware) GrantRole(ctx context.Context, userName string, role app.Role, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "grantRole", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.GrantRole(ctx, userName, role, actor)
+	return
+}
+
+func (im instrumentingMiddleware) RevokeRole(ctx context.Context, userName string, roleID int, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "revokeRole", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.RevokeRole(ctx, userName, roleID, actor)
+	return
+}
+
+func (im instrumentingMiddleware) GetRolePermissions(ctx context.Context, roleID int) (permissions []string, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "getRolePermissions", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	permissions, err = im.next.GetRolePermissions(ctx, roleID)
+	return
+}
+
+func (im instrumentingMiddleware) UpdateRole(ctx context.Context, role app.Role, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "updateRole", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.UpdateRole(ctx, role, actor)
+	return
+}
+
+func (im instrumentingMiddleware) GetGroups(ctx context.Context) (groups []app.Group, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "getGroups", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	groups, err = im.next.GetGroups(ctx)
+	return
+}
+
+func (im instrumentingMiddleware) CreateGroup(ctx context.Context, group app.Group, actor string) (res app.Group, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "createGroup", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	res, err = im.next.CreateGroup(ctx, group, actor)
+	return
+}
+
+func (im instrumentingMiddleware) UpdateGroup(ctx context.Context, group app.Group, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "updateGroup", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.UpdateGroup(ctx, group, actor)
+	return
+}
+
+func (im instrumentingMiddleware) DeleteGroup(ctx context.Context, groupID int, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "deleteGroup", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.DeleteGroup(ctx, groupID, actor)
+	return
+}
+
+func (im instrumentingMiddleware) AddGroupMember(ctx context.Context, groupID int, userName, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "addGroupMember", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.AddGroupMember(ctx, groupID, userName, actor)
+	return
+}
+
+func (im instrumentingMiddleware) RemoveGroupMember(ctx context.Context, groupID int, userName, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "removeGroupMember", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.RemoveGroupMember(ctx, groupID, userName, actor)
+	return
+}
+
+func (im instrumentingMiddleware) GrantGroupRole(ctx context.Context, groupID int, role app.Role, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "grantGroupRole", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.GrantGroupRole(ctx, groupID, role, actor)
+	return
+}
+
+func (im instrumentingMiddleware) RevokeGroupRole(ctx context.Context, groupID, roleID int, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "revokeGroupRole", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.RevokeGroupRole(ctx, groupID, roleID, actor)
+	return
+}
+
+func (im instrumentingMiddleware) GetOrganizations(ctx context.Context) (orgs []app.Organization, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "getOrganizations", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	orgs, err = im.next.GetOrganizations(ctx)
+	return
+}
+
+func (im instrumentingMiddleware) CreateOrganization(ctx context.Context, org app.Organization, actor string) (created app.Organization, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "createOrganization", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	created, err = im.next.CreateOrganization(ctx, org, actor)
+	return
+}
+
+func (im instrumentingMiddleware) AddGroupManager(ctx context.Context, groupID int, userName, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "addGroupManager", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.AddGroupManager(ctx, groupID, userName, actor)
+	return
+}
+
+func (im instrumentingMiddleware) RemoveGroupManager(ctx context.Context, groupID int, userName, actor string) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "removeGroupManager", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.RemoveGroupManager(ctx, groupID, userName, actor)
+	return
+}
+
+func (im instrumentingMiddleware) PlanRoles(ctx context.Context, desired app.DesiredRoles) (plan app.RolePlan, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "planRoles", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	plan, err = im.next.PlanRoles(ctx, desired)
+	return
+}
+
+func (im instrumentingMiddleware) ApplyRoles(ctx context.Context, desired app.DesiredRoles, actor string) (plan app.RolePlan, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "applyRoles", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	plan, err = im.next.ApplyRoles(ctx, desired, actor)
+	return
+}
+
+func (im instrumentingMiddleware) ImportUsers(ctx context.Context, records []app.UserRecord, opts app.ImportOptions, actor string) (report app.ImportReport, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "importUsers", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	report, err = im.next.ImportUsers(ctx, records, opts, actor)
+	return
+}
+
+func (im instrumentingMiddleware) ExportUsers(ctx context.Context) (records []app.UserRecord, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "exportUsers", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	records, err = im.next.ExportUsers(ctx)
+	return
+}
+
+func (im instrumentingMiddleware) StreamUsers(ctx context.Context, filter app.UserFilter, emit func(app.User) error) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "streamUsers", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	err = im.next.StreamUsers(ctx, filter, emit)
+	return
+}
+
+func (im instrumentingMiddleware) BatchUsers(ctx context.Context, batch app.UserBatch, actor string) (report app.BatchReport, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "batchUsers", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	report, err = im.next.BatchUsers(ctx, batch, actor)
+	return
+}
+
+func (im instrumentingMiddleware) PatchUser(ctx context.Context, userName string, patch app.UserPatch) (user app.User, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "patchUser", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	user, err = im.next.PatchUser(ctx, userName, patch)
+	return
+}
+
+func (im instrumentingMiddleware) RenameUser(ctx context.Context, userName, newName, actor string) (user app.User, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "renameUser", "error", fmt.Sprint(err != nil)}
+		im.requestCount.With(lvs...).Add(1)
+		im.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	user, err = im.next.RenameUser(ctx, userName, newName, actor)
 	return
 }
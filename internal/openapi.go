@@ -0,0 +1,362 @@
+package internal
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// openAPIDocument is the OpenAPI 3.1 description of every route of MakeHTTPHandler,
+// served at /openapi.json. Routes and operations are kept in step by TestOpenAPIMatchesRouter.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+func serveOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPIDocument)
+}
+
+// openAPISpec is the part of the document that openAPIValidation checks against.
+type openAPISpec struct {
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Responses map[string]*openAPIResponse `json:"responses"`
+		Schemas   map[string]*jsonSchema      `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	RequestBody *struct {
+		Required bool                    `json:"required"`
+		Content  map[string]openAPIMedia `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIResponse struct {
+	Ref     string                  `json:"$ref"`
+	Content map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIMedia struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+// jsonSchema holds the keywords of JSON Schema the document uses. Others, like format, are not checked.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	MinItems             *int                   `json:"minItems"`
+	Minimum              *float64               `json:"minimum"`
+}
+
+// schemaTypes is the type keyword, a single type or a list of them.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+func loadOpenAPISpec() (*openAPISpec, error) {
+	var spec openAPISpec
+	if err := json.Unmarshal(openAPIDocument, &spec); err != nil {
+		return nil, fmt.Errorf("openapi.json: %v", err)
+	}
+	return &spec, nil
+}
+
+// operation returns the operation of the route the request matched, nil if the document lacks it.
+func (s *openAPISpec) operation(r *http.Request) (string, *openAPIOperation) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", nil
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return "", nil
+	}
+	path = s.documentedPath(path)
+	return path, s.Paths[path][strings.ToLower(r.Method)]
+}
+
+// documentedPath returns the path of the document that describes a route template.
+// Unversioned routes are described by their /v1 successor.
+func (s *openAPISpec) documentedPath(template string) string {
+	if _, ok := s.Paths[template]; !ok {
+		if _, ok = s.Paths["/v1"+template]; ok {
+			return "/v1" + template
+		}
+	}
+	return template
+}
+
+// response returns the response declared for the status, or the default one.
+func (s *openAPISpec) response(op *openAPIOperation, status int) *openAPIResponse {
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp = op.Responses["default"]
+	}
+	if resp != nil && resp.Ref != "" {
+		resp = s.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+	}
+	return resp
+}
+
+// openAPIValidation checks requests and responses against the document, enabled by OPENAPI_VALIDATE
+// for development. Requests with a body the document does not allow are rejected with 422 before
+// they are decoded. Responses are sent already when they are checked, so mismatches are logged,
+// as are routes missing from the document.
+func openAPIValidation(spec *openAPISpec, logger UnitLogHandler) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				h.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			path, op := spec.operation(r)
+			if op == nil {
+				logger.Handle(ctx, fmt.Errorf("openapi: %s %s is not in the document", r.Method, path))
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBytes(r)))
+			if err != nil {
+				encodeError(ctx, decodeError(err), w)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			if err = spec.checkRequest(op, r.Header.Get("Content-Type"), body); err != nil {
+				encodeError(ctx, err, w)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(rec, r)
+			if err = spec.checkResponse(op, rec.status, w.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+				logger.Handle(ctx, fmt.Errorf("openapi: %s %s: response %d: %w", r.Method, path, rec.status, err))
+			}
+		})
+	}
+}
+
+// checkRequest checks the body against the schema of its media type.
+func (s *openAPISpec) checkRequest(op *openAPIOperation, contentType string, body []byte) error {
+	if op.RequestBody == nil || len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody != nil && op.RequestBody.Required {
+			return errEmptyBody
+		}
+		return nil
+	}
+	mediaType := baseMediaType(contentType)
+	media, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("Content-Type %q is not one of %s: %w",
+			contentType, strings.Join(mediaTypes(op.RequestBody.Content), ", "), ErrInvalidArgument)
+	}
+	if media.Schema == nil || !isJSONMediaType(mediaType) {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("body is not valid JSON: %w", ErrInvalidArgument)
+	}
+	var invalid ValidationError
+	s.validate(media.Schema, value, "", &invalid)
+	return invalid.Err()
+}
+
+// checkResponse checks that the status is declared and a JSON body matches its schema.
+func (s *openAPISpec) checkResponse(op *openAPIOperation, status int, contentType string, body []byte) error {
+	resp := s.response(op, status)
+	if resp == nil {
+		return fmt.Errorf("status is not declared")
+	}
+	if len(resp.Content) == 0 {
+		return nil
+	}
+	mediaType := baseMediaType(contentType)
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("Content-Type %q is not one of %s", contentType, strings.Join(mediaTypes(resp.Content), ", "))
+	}
+	if media.Schema == nil || !isJSONMediaType(mediaType) {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("body is not valid JSON: %v", err)
+	}
+	var invalid ValidationError
+	s.validate(media.Schema, value, "", &invalid)
+	return invalid.Err()
+}
+
+// validate reports where value does not match the schema, by field like validateStruct.
+func (s *openAPISpec) validate(schema *jsonSchema, value interface{}, field string, invalid *ValidationError) {
+	if schema.Ref != "" {
+		ref, ok := s.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			invalid.Add(fieldName(field), "refers to the unknown schema "+schema.Ref)
+			return
+		}
+		schema = ref
+	}
+	if len(schema.Type) > 0 && !hasSchemaType(schema.Type, value) {
+		invalid.Add(fieldName(field), "must be "+strings.Join(schema.Type, " or "))
+		return
+	}
+	if len(schema.Enum) > 0 && !enumContains(schema.Enum, value) {
+		allowed := make([]string, 0, len(schema.Enum))
+		for _, v := range schema.Enum {
+			allowed = append(allowed, fmt.Sprint(v))
+		}
+		invalid.Add(fieldName(field), "must be one of "+strings.Join(allowed, ", "))
+	}
+
+	switch v := value.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if schema.MinLength != nil && n < *schema.MinLength {
+			invalid.Add(fieldName(field), fmt.Sprintf("must be at least %d characters", *schema.MinLength))
+		}
+		if schema.MaxLength != nil && n > *schema.MaxLength {
+			invalid.Add(fieldName(field), fmt.Sprintf("must be at most %d characters", *schema.MaxLength))
+		}
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			invalid.Add(fieldName(field), fmt.Sprintf("must be at least %v", *schema.Minimum))
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			invalid.Add(fieldName(field), fmt.Sprintf("must be at least %d entries", *schema.MinItems))
+		}
+		if schema.Items != nil {
+			for i, item := range v {
+				s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i), invalid)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				invalid.Add(joinField(field, name), "is required")
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				s.validate(property, v[name], joinField(field, name), invalid)
+			} else if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				invalid.Add(joinField(field, name), "is not allowed")
+			}
+		}
+	}
+}
+
+func hasSchemaType(types schemaTypes, value interface{}) bool {
+	for _, t := range types {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func enumContains(enum []interface{}, value interface{}) bool {
+	for _, v := range enum {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinField(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func fieldName(field string) string {
+	if field == "" {
+		return "body"
+	}
+	return field
+}
+
+func baseMediaType(contentType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func mediaTypes(content map[string]openAPIMedia) []string {
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// mustLoadOpenAPISpec panics on a broken document, which TestOpenAPIMatchesRouter catches first.
+func mustLoadOpenAPISpec() *openAPISpec {
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
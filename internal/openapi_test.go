@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPIMatchesRouter fails when a route of MakeHTTPHandler is missing from openapi.json
+// or the document describes an operation the router does not serve. Unversioned routes count
+// as their /v1 successor.
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, ok := MakeHTTPHandler(nil, UnitLogHandler{}, newMemoryIdempotencyStore()).(*mux.Router)
+	if !ok {
+		t.Fatal("MakeHTTPHandler does not return a *mux.Router")
+	}
+
+	routed := map[string]bool{}
+	err = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		//Routes holding a subrouter serve nothing themselves
+		if route.GetHandler() == nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			if method != http.MethodOptions {
+				routed[method+" "+spec.documentedPath(path)] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for _, op := range sortedKeys(routed) {
+		if !documented[op] {
+			t.Errorf("%s is routed but missing from openapi.json", op)
+		}
+	}
+	for _, op := range sortedKeys(documented) {
+		if !routed[op] {
+			t.Errorf("%s is in openapi.json but not routed", op)
+		}
+	}
+}
+
+// TestOpenAPIReferences fails on $ref that point to no schema or response of the document.
+func TestOpenAPIReferences(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal(openAPIDocument, &doc); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var walk func(node interface{}, at string)
+	walk = func(node interface{}, at string) {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				name := ref[strings.LastIndex(ref, "/")+1:]
+				_, schema := spec.Components.Schemas[name]
+				_, response := spec.Components.Responses[name]
+				if !(strings.HasPrefix(ref, "#/components/schemas/") && schema) &&
+					!(strings.HasPrefix(ref, "#/components/responses/") && response) {
+					t.Errorf("%s: $ref %s does not resolve", at, ref)
+				}
+			}
+			for key, child := range v {
+				walk(child, at+"/"+key)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child, at)
+			}
+		}
+	}
+	walk(doc, "#")
+}
+
+func TestOpenAPIValidation(t *testing.T) {
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	r.Use(openAPIValidation(spec, UnitLogHandler{}))
+	r.Methods("POST").Path("/user/{user}/rename").HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"user":{"user_name":"alice"}}`))
+	})
+
+	for _, tc := range []struct {
+		body, contentType string
+		status            int
+		fields            []string
+	}{
+		{`{"user_name":"alice"}`, "application/json", http.StatusOK, nil},
+		{`{"user_name":""}`, "application/json", http.StatusUnprocessableEntity, []string{"user_name"}},
+		{`{"user_name":1,"extra":true}`, "application/json", http.StatusUnprocessableEntity, []string{"extra", "user_name"}},
+		{`{}`, "application/json", http.StatusUnprocessableEntity, []string{"user_name"}},
+		{`user_name=alice`, "application/x-www-form-urlencoded", http.StatusBadRequest, nil},
+	} {
+		req := httptest.NewRequest("POST", "/user/bob/rename", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.body, rec.Code, tc.status)
+			continue
+		}
+		var resp struct {
+			Fields []FieldError `json:"fields"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		var fields []string
+		for _, f := range resp.Fields {
+			fields = append(fields, f.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(tc.fields, ",") {
+			t.Errorf("%s: fields %v, want %v", tc.body, fields, tc.fields)
+		}
+	}
+
+	op := spec.Paths["/v1/user/{user}/rename"]["post"]
+	if err = spec.checkResponse(op, http.StatusOK, "application/json", []byte(`{"user":{"role_id":"3"}}`)); err == nil {
+		t.Error("response without user_name and with a string role_id passed")
+	}
+	if err = spec.checkResponse(op, http.StatusNotFound, "application/json", []byte(`{"error":"not found"}`)); err != nil {
+		t.Errorf("error response: %v", err)
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
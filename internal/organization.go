@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+)
+
+func (u userService) GetOrganizations(ctx context.Context) ([]app.Organization, error) {
+	var orgs []app.Organization
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return orgs, fmt.Errorf("GetOrganizations. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, `select to_json(t.*)
+					from (select id, name, create_time from organization
+						where org_visible(id)
+						order by id) t`)
+	if err != nil {
+		return orgs, fmt.Errorf("GetOrganizations Query: %v\n", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res string
+		if err = rows.Scan(&res); err != nil {
+			return orgs, fmt.Errorf("GetOrganizations rows.Scan: %v\n", err)
+		}
+		var result app.Organization
+		if err = json.Unmarshal([]byte(res), &result); err != nil {
+			return orgs, fmt.Errorf("GetOrganizations json.Unmarshal: %v\n", err)
+		}
+		orgs = append(orgs, result)
+	}
+	return orgs, rows.Err()
+}
+
+func (u userService) CreateOrganization(ctx context.Context, org app.Organization, actor string) (app.Organization, error) {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return app.Organization{}, fmt.Errorf("CreateOrganization: name is required: %w", ErrInvalidArgument)
+	}
+
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return app.Organization{}, fmt.Errorf("CreateOrganization. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	err = conn.QueryRow(ctx, `insert into organization(name) values($1) returning id, create_time`, org.Name).
+		Scan(&org.ID, &org.CreateTime)
+	if isUniqueViolation(err) {
+		return app.Organization{}, fmt.Errorf("CreateOrganization: organization %s: %w", org.Name, ErrAlreadyExists)
+	}
+	if err != nil {
+		return app.Organization{}, fmt.Errorf("CreateOrganization insert into organization: %v\n", err)
+	}
+	if err = recordAuditEvent(app.WithTenant(ctx, org.ID), conn, actor, "organization.created", "",
+		map[string]interface{}{"org_id": org.ID, "name": org.Name}); err != nil {
+		return app.Organization{}, fmt.Errorf("CreateOrganization: %v\n", err)
+	}
+	return org, nil
+}
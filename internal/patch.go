@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"reflect"
+	"strconv"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+	"time"
+)
+
+// userPatchDocument holds the fields of a user that PATCH /user/{username} changes. Patches
+// apply to its JSON form, so members outside of it are rejected instead of ignored.
+type userPatchDocument struct {
+	RoleID         int        `json:"role_id"`
+	Email          string     `json:"email"`
+	DisplayName    string     `json:"display_name"`
+	Status         string     `json:"status"`
+	Locale         string     `json:"locale"`
+	Timezone       string     `json:"timezone"`
+	RoleExpiresAt  *time.Time `json:"role_expires_at"`
+	FallbackRoleID int        `json:"fallback_role_id"`
+}
+
+// PatchUser changes the fields of the user that the patch sets and leaves the others as they are.
+// The changed fields are validated like PUT /user, and the result is returned.
+func (u userService) PatchUser(ctx context.Context, userName string, patch app.UserPatch) (app.User, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return app.User{}, fmt.Errorf("PatchUser. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		var doc userPatchDocument
+		errP := tx.QueryRow(ctx, `select role, coalesce(email, ''), coalesce(display_name, ''), status,
+					coalesce(locale, ''), coalesce(timezone, ''), role_expires_at, coalesce(fallback_role, 0)
+				from users where user_name = $1 and deleted_at is null and org_visible(org_id)
+				for update`, userName).Scan(&doc.RoleID, &doc.Email, &doc.DisplayName, &doc.Status,
+			&doc.Locale, &doc.Timezone, &doc.RoleExpiresAt, &doc.FallbackRoleID)
+		if errors.Is(errP, pgx.ErrNoRows) {
+			return fmt.Errorf("user %s: %w", userName, ErrNotFound)
+		}
+		if errP != nil {
+			return errP
+		}
+		patched, errP := applyUserPatch(doc, patch)
+		if errP != nil {
+			return errP
+		}
+		if patched.RoleID == 0 {
+			return fmt.Errorf("role_id cannot be removed: %w", ErrInvalidArgument)
+		}
+		return patchUser(ctx, tx, userName, doc, patched)
+	})
+	if isGuardError(err) {
+		return app.User{}, fmt.Errorf("PatchUser: %w", err)
+	}
+	if err != nil {
+		return app.User{}, fmt.Errorf("PatchUser: %v\n", err)
+	}
+	return u.GetUser(ctx, userName, "")
+}
+
+// patchUser writes the members that differ between the stored and the patched document.
+// Only they are validated, so that a patch is not failed by members it leaves alone.
+func patchUser(ctx context.Context, tx pgx.Tx, userName string, doc, patched userPatchDocument) error {
+	admins, err := protectAccount(ctx, tx, userName)
+	if err != nil {
+		return err
+	}
+	args := []interface{}{userName}
+	var columns []string
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		columns = append(columns, fmt.Sprintf(column, "$"+strconv.Itoa(len(args))))
+	}
+
+	var profile app.User
+	if patched.Email != doc.Email {
+		profile.Email = patched.Email
+	}
+	if patched.DisplayName != doc.DisplayName {
+		profile.DisplayName = patched.DisplayName
+	}
+	//Status is left unchanged when it is removed
+	if patched.Status != doc.Status {
+		profile.Status = patched.Status
+	}
+	if err = validateProfile(&profile); err != nil {
+		return err
+	}
+	if patched.Email != doc.Email {
+		set("email = nullif(%s, '')", profile.Email)
+	}
+	if patched.DisplayName != doc.DisplayName {
+		set("display_name = nullif(%s, '')", profile.DisplayName)
+	}
+	if profile.Status != "" {
+		set("status = %s", profile.Status)
+	}
+	if patched.Locale != doc.Locale {
+		set("locale = nullif(%s, '')", patched.Locale)
+	}
+	if patched.Timezone != doc.Timezone {
+		set("timezone = nullif(%s, '')", patched.Timezone)
+	}
+
+	if patched.RoleID != doc.RoleID {
+		if err = checkPatchedRole(ctx, tx, patched.RoleID); err != nil {
+			return err
+		}
+		set("role = %s", patched.RoleID)
+	}
+	//A role with expires_at falls back to fallback_role_id, by default the 'user' role
+	expires, was := patched.RoleExpiresAt, doc.RoleExpiresAt
+	if (expires == nil) != (was == nil) || (expires != nil && !expires.Equal(*was)) {
+		if expires != nil && !expires.After(time.Now()) {
+			return fmt.Errorf("role_expires_at is in the past: %w", ErrInvalidArgument)
+		}
+		set("role_expires_at = %s", expires)
+	}
+	fallback := patched.FallbackRoleID
+	if expires == nil {
+		fallback = 0
+	} else if fallback == 0 {
+		fallback = 3
+	}
+	if fallback != doc.FallbackRoleID {
+		if fallback != 0 {
+			if err = checkPatchedRole(ctx, tx, fallback); err != nil {
+				return err
+			}
+		}
+		set("fallback_role = nullif(%s, 0)", fallback)
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+	_, err = tx.Exec(ctx, `update users set `+strings.Join(columns, ", ")+` where user_name = $1`, args...)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("email %s: %w", profile.Email, ErrAlreadyExists)
+	}
+	if err != nil {
+		return err
+	}
+	return checkAdministrators(ctx, tx, userName, admins)
+}
+
+func checkPatchedRole(ctx context.Context, tx pgx.Tx, id int) error {
+	role := app.Role{ID: id}
+	if err := resolveRole(ctx, tx, &role); err != nil {
+		return err
+	}
+	return checkGrantable(role)
+}
+
+// applyUserPatch applies the merge patch or the JSON Patch to the document.
+func applyUserPatch(doc userPatchDocument, patch app.UserPatch) (userPatchDocument, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return doc, err
+	}
+	var members map[string]interface{}
+	if err = json.Unmarshal(raw, &members); err != nil {
+		return doc, err
+	}
+
+	if patch.MergePatch != nil {
+		err = applyMergePatch(members, patch.MergePatch)
+	} else {
+		err = applyJSONPatch(members, patch.JSONPatch)
+	}
+	if err != nil {
+		return doc, err
+	}
+
+	if raw, err = json.Marshal(members); err != nil {
+		return doc, err
+	}
+	var patched userPatchDocument
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&patched); err != nil {
+		return doc, fmt.Errorf("patched user: %v: %w", err, ErrInvalidArgument)
+	}
+	return patched, nil
+}
+
+// applyMergePatch follows RFC 7396. The document has no nested objects, so members are
+// replaced as a whole, and null removes them.
+func applyMergePatch(members map[string]interface{}, patch json.RawMessage) error {
+	var changes map[string]interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil || changes == nil {
+		return fmt.Errorf("merge patch is not a JSON object: %w", ErrInvalidArgument)
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(members, name)
+		} else {
+			members[name] = value
+		}
+	}
+	return nil
+}
+
+// applyJSONPatch follows RFC 6902 for the operations add, remove, replace and test on members
+// of the document. move and copy are not supported, as they would set roles that were not
+// authorized from the values of the patch.
+func applyJSONPatch(members map[string]interface{}, ops []app.JSONPatchOperation) error {
+	for i, op := range ops {
+		name, err := patchMember(op.Path)
+		if err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+		var value interface{}
+		if op.Op != "remove" {
+			if err = json.Unmarshal(op.Value, &value); err != nil {
+				return fmt.Errorf("operation %d: value is missing: %w", i, ErrInvalidArgument)
+			}
+		}
+		current, exists := members[name]
+		switch op.Op {
+		case "add":
+			members[name] = value
+		case "replace", "remove", "test":
+			if !exists {
+				return fmt.Errorf("operation %d: %s does not exist: %w", i, op.Path, ErrInvalidArgument)
+			}
+			switch op.Op {
+			case "replace":
+				members[name] = value
+			case "remove":
+				delete(members, name)
+			case "test":
+				if !reflect.DeepEqual(current, value) {
+					return fmt.Errorf("operation %d: test of %s failed: %w", i, op.Path, ErrInvalidArgument)
+				}
+			}
+		default:
+			return fmt.Errorf("operation %d: op %q is not one of add, remove, replace, test: %w",
+				i, op.Op, ErrInvalidArgument)
+		}
+	}
+	return nil
+}
+
+// patchMember returns the member a JSON Pointer names. Only members of the document itself can be patched.
+func patchMember(path string) (string, error) {
+	if !strings.HasPrefix(path, "/") || strings.Count(path, "/") != 1 {
+		return "", fmt.Errorf("path %q is not a member of the user: %w", path, ErrInvalidArgument)
+	}
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(path[1:]), nil
+}
+
+// patchValues returns the values a patch sets, by member, for authorizing it before it is applied.
+func patchValues(patch app.UserPatch) (map[string]json.RawMessage, error) {
+	values := map[string]json.RawMessage{}
+	if patch.MergePatch != nil {
+		if err := json.Unmarshal(patch.MergePatch, &values); err != nil {
+			return nil, fmt.Errorf("merge patch is not a JSON object: %w", ErrInvalidArgument)
+		}
+		return values, nil
+	}
+	for i, op := range patch.JSONPatch {
+		if op.Op != "add" && op.Op != "replace" {
+			continue
+		}
+		name, err := patchMember(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+		values[name] = op.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+	"time"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	for _, tc := range []struct {
+		name, patch string
+		want        map[string]interface{}
+		err         error
+	}{
+		{"set a member", `{"email":"b@example.com"}`, map[string]interface{}{"email": "b@example.com", "locale": "de"}, nil},
+		{"add a member", `{"timezone":"UTC"}`, map[string]interface{}{"email": "a@example.com", "locale": "de", "timezone": "UTC"}, nil},
+		{"null removes", `{"locale":null}`, map[string]interface{}{"email": "a@example.com"}, nil},
+		{"empty patch", `{}`, map[string]interface{}{"email": "a@example.com", "locale": "de"}, nil},
+		{"array", `[{"email":"b@example.com"}]`, nil, ErrInvalidArgument},
+		{"null", `null`, nil, ErrInvalidArgument},
+		{"string", `"email"`, nil, ErrInvalidArgument},
+	} {
+		members := map[string]interface{}{"email": "a@example.com", "locale": "de"}
+		err := applyMergePatch(members, json.RawMessage(tc.patch))
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if tc.err == nil && !reflect.DeepEqual(members, tc.want) {
+			t.Errorf("%s: members %v, want %v", tc.name, members, tc.want)
+		}
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	op := func(op, path, value string) app.JSONPatchOperation {
+		return app.JSONPatchOperation{Op: op, Path: path, Value: json.RawMessage(value)}
+	}
+	for _, tc := range []struct {
+		name string
+		ops  []app.JSONPatchOperation
+		want map[string]interface{}
+		err  error
+	}{
+		{"replace", []app.JSONPatchOperation{op("replace", "/email", `"b@example.com"`)},
+			map[string]interface{}{"email": "b@example.com", "locale": "de"}, nil},
+		{"add", []app.JSONPatchOperation{op("add", "/timezone", `"UTC"`)},
+			map[string]interface{}{"email": "a@example.com", "locale": "de", "timezone": "UTC"}, nil},
+		{"remove", []app.JSONPatchOperation{op("remove", "/locale", "")},
+			map[string]interface{}{"email": "a@example.com"}, nil},
+		{"test then replace", []app.JSONPatchOperation{op("test", "/locale", `"de"`), op("replace", "/locale", `"fr"`)},
+			map[string]interface{}{"email": "a@example.com", "locale": "fr"}, nil},
+		{"escaped path", []app.JSONPatchOperation{op("add", "/a~1b~0c", `1`)},
+			map[string]interface{}{"email": "a@example.com", "locale": "de", "a/b~c": 1.0}, nil},
+		{"failed test", []app.JSONPatchOperation{op("test", "/locale", `"fr"`)}, nil, ErrInvalidArgument},
+		{"replace missing member", []app.JSONPatchOperation{op("replace", "/timezone", `"UTC"`)}, nil, ErrInvalidArgument},
+		{"remove missing member", []app.JSONPatchOperation{op("remove", "/timezone", "")}, nil, ErrInvalidArgument},
+		{"value missing", []app.JSONPatchOperation{op("add", "/timezone", "")}, nil, ErrInvalidArgument},
+		{"nested path", []app.JSONPatchOperation{op("add", "/roles/0", `"admin"`)}, nil, ErrInvalidArgument},
+		{"relative path", []app.JSONPatchOperation{op("add", "email", `"b@example.com"`)}, nil, ErrInvalidArgument},
+		{"move", []app.JSONPatchOperation{op("move", "/email", `"/locale"`)}, nil, ErrInvalidArgument},
+	} {
+		members := map[string]interface{}{"email": "a@example.com", "locale": "de"}
+		err := applyJSONPatch(members, tc.ops)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if tc.err == nil && !reflect.DeepEqual(members, tc.want) {
+			t.Errorf("%s: members %v, want %v", tc.name, members, tc.want)
+		}
+	}
+}
+
+func TestApplyUserPatch(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	doc := userPatchDocument{RoleID: 2, Email: "a@example.com", Locale: "de", RoleExpiresAt: &expires, FallbackRoleID: 3}
+	for _, tc := range []struct {
+		name  string
+		patch app.UserPatch
+		want  userPatchDocument
+		err   error
+	}{
+		{"merge patch", app.UserPatch{MergePatch: json.RawMessage(`{"role_id":4,"role_expires_at":null,"email":null}`)},
+			userPatchDocument{RoleID: 4, Locale: "de", FallbackRoleID: 3}, nil},
+		{"JSON Patch", app.UserPatch{JSONPatch: []app.JSONPatchOperation{
+			{Op: "replace", Path: "/display_name", Value: json.RawMessage(`"Alice"`)}}},
+			userPatchDocument{RoleID: 2, Email: "a@example.com", DisplayName: "Alice", Locale: "de",
+				RoleExpiresAt: &expires, FallbackRoleID: 3}, nil},
+		{"unknown member", app.UserPatch{MergePatch: json.RawMessage(`{"password":"x"}`)}, doc, ErrInvalidArgument},
+		{"wrong type", app.UserPatch{MergePatch: json.RawMessage(`{"role_id":"4"}`)}, doc, ErrInvalidArgument},
+	} {
+		patched, err := applyUserPatch(doc, tc.patch)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if !reflect.DeepEqual(patched, tc.want) {
+			t.Errorf("%s: patched %+v, want %+v", tc.name, patched, tc.want)
+		}
+	}
+}
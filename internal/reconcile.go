@@ -0,0 +1,294 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"sort"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+)
+
+// PlanRoles compares the desired role assignments with the database without changing anything.
+func (u userService) PlanRoles(ctx context.Context, desired app.DesiredRoles) (app.RolePlan, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return app.RolePlan{}, fmt.Errorf("PlanRoles. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	var plan app.RolePlan
+	err = pgx.BeginTxFunc(ctx, conn, pgx.TxOptions{AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		var errP error
+		plan, _, errP = planRoles(ctx, tx, desired, false)
+		return errP
+	})
+	if isGuardError(err) {
+		return app.RolePlan{}, fmt.Errorf("PlanRoles: %w", err)
+	}
+	if err != nil {
+		return app.RolePlan{}, fmt.Errorf("PlanRoles: %v\n", err)
+	}
+	return plan, nil
+}
+
+// ApplyRoles plans the desired role assignments again under lock and executes the plan in the
+// same transaction, so either all of it is applied or nothing. New users are created with their
+// roles, changed users get exactly the desired roles and unlisted users are deleted.
+// New users are validated like POST /user, and the administrators of the organization are
+// guarded like in single changes. The caller is authorized by the transport.
+func (u userService) ApplyRoles(ctx context.Context, desired app.DesiredRoles, actor string) (app.RolePlan, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return app.RolePlan{}, fmt.Errorf("ApplyRoles. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	var plan app.RolePlan
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		var (
+			ids  map[string]int
+			errA error
+		)
+		plan, ids, errA = planRoles(ctx, tx, desired, true)
+		if errA != nil {
+			return errA
+		}
+
+		admins := map[string]int{}
+		protect := func(userName string) error {
+			n, errP := protectAccount(ctx, tx, userName)
+			admins[userName] = n
+			return errP
+		}
+
+		for _, c := range plan.Create {
+			user := app.User{Name: c.UserName}
+			if errA = validateProfile(&user); errA != nil {
+				return errA
+			}
+			if errA = insertUser(ctx, tx, user, ids[strings.ToLower(c.Roles[0])], orgOf(ctx)); errA != nil {
+				return errA
+			}
+			if errA = replaceRoles(ctx, tx, c.UserName, c.Roles, ids); errA != nil {
+				return errA
+			}
+			if errA = recordAuditEvent(ctx, tx, actor, "user.reconciled", c.UserName,
+				map[string]interface{}{"roles": c.Roles}); errA != nil {
+				return errA
+			}
+		}
+		for _, c := range plan.Change {
+			if errA = protect(c.UserName); errA != nil {
+				return errA
+			}
+			//Declared roles are permanent, a pending expiry would undo them
+			_, errA = tx.Exec(ctx, `update users set role = $2, role_expires_at = null, fallback_role = null
+					where user_name = $1`, c.UserName, ids[strings.ToLower(c.To[0])])
+			if errA != nil {
+				return errA
+			}
+			if errA = replaceRoles(ctx, tx, c.UserName, c.To, ids); errA != nil {
+				return errA
+			}
+			if errA = recordAuditEvent(ctx, tx, actor, "role.reconciled", c.UserName,
+				map[string]interface{}{"from": c.From, "to": c.To}); errA != nil {
+				return errA
+			}
+		}
+		for _, name := range plan.Delete {
+			if errA = protect(name); errA != nil {
+				return errA
+			}
+			if _, errA = tx.Exec(ctx, `update users set deleted_at = now() where user_name = $1`, name); errA != nil {
+				return errA
+			}
+			if errA = recordAuditEvent(ctx, tx, actor, "user.reconciled", name,
+				map[string]interface{}{"deleted": true}); errA != nil {
+				return errA
+			}
+		}
+
+		for name, before := range admins {
+			if errA = checkAdministrators(ctx, tx, name, before); errA != nil {
+				return errA
+			}
+		}
+		return nil
+	})
+	if isGuardError(err) {
+		return app.RolePlan{}, fmt.Errorf("ApplyRoles: %w", err)
+	}
+	if err != nil {
+		return app.RolePlan{}, fmt.Errorf("ApplyRoles: %v\n", err)
+	}
+	return plan, nil
+}
+
+// planRoles computes the plan and the ids of the desired roles by lower-case name.
+// With lock set the users of the organization stay locked until the transaction ends.
+func planRoles(ctx context.Context, tx pgx.Tx, desired app.DesiredRoles, lock bool) (app.RolePlan, map[string]int, error) {
+	plan := app.RolePlan{Create: []app.RoleAssignment{}, Change: []app.RoleChange{}, Delete: []string{}}
+	ids := map[string]int{}
+	listed := map[string]bool{}
+	users := make([]app.RoleAssignment, 0, len(desired.Users))
+	for _, a := range desired.Users {
+		a.UserName = normalizeUserName(a.UserName)
+		if a.UserName == "" || len(a.Roles) == 0 {
+			return plan, nil, fmt.Errorf("every user needs user_name and at least one role: %w", ErrInvalidArgument)
+		}
+		if listed[strings.ToLower(a.UserName)] {
+			return plan, nil, fmt.Errorf("user %s is listed twice: %w", a.UserName, ErrInvalidArgument)
+		}
+		listed[strings.ToLower(a.UserName)] = true
+		users = append(users, a)
+		for _, name := range a.Roles {
+			if _, ok := ids[strings.ToLower(name)]; ok {
+				continue
+			}
+			role := app.Role{Role: name}
+			if err := resolveRole(ctx, tx, &role); err != nil {
+				return plan, nil, err
+			}
+			if err := checkGrantable(role); err != nil {
+				return plan, nil, err
+			}
+			ids[strings.ToLower(name)] = role.ID
+		}
+	}
+
+	query := `select users.user_name,
+				array(select r.role_name from user_roles x join user_role r on r.id = x.role
+					where x.user_name = users.user_name order by x.role <> users.role, r.id),
+				exists(select 1 from user_roles x join user_role r on r.id = x.role
+					where x.user_name = users.user_name and lower(r.role_name) = $1)
+			from users where users.deleted_at is null and org_visible(users.org_id)
+			order by users.user_name`
+	if lock {
+		query += ` for update of users`
+	}
+	rows, err := tx.Query(ctx, query, roleSuperadmin)
+	if err != nil {
+		return plan, nil, err
+	}
+	var current []storedRoles
+	for rows.Next() {
+		var user storedRoles
+		if err = rows.Scan(&user.UserName, &user.Roles, &user.Superadmin); err != nil {
+			rows.Close()
+			return plan, nil, err
+		}
+		current = append(current, user)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return plan, nil, err
+	}
+
+	plan, err = diffRoles(users, current, desired.IgnoreUnlisted)
+	return plan, ids, err
+}
+
+// storedRoles are the roles a user holds, its primary role first.
+type storedRoles struct {
+	UserName   string
+	Roles      []string
+	Superadmin bool
+}
+
+// diffRoles plans the changes that give the current users the desired roles. Users are matched
+// without regard to case and keep their stored names. Superadmins are left out of the plan,
+// listed or not, as the API cannot change them.
+func diffRoles(users []app.RoleAssignment, current []storedRoles, ignoreUnlisted bool) (app.RolePlan, error) {
+	plan := app.RolePlan{Create: []app.RoleAssignment{}, Change: []app.RoleChange{}, Delete: []string{}}
+	stored := map[string]storedRoles{}
+	superadmins := map[string]bool{}
+	for _, user := range current {
+		if user.Superadmin {
+			superadmins[strings.ToLower(user.UserName)] = true
+			continue
+		}
+		stored[strings.ToLower(user.UserName)] = user
+	}
+
+	listed := map[string]bool{}
+	var invalid ValidationError
+	for i, a := range users {
+		listed[strings.ToLower(a.UserName)] = true
+		if superadmins[strings.ToLower(a.UserName)] {
+			continue
+		}
+		user, ok := stored[strings.ToLower(a.UserName)]
+		switch {
+		case !ok:
+			name, err := checkUserName(fmt.Sprintf("users[%d].user_name", i), a.UserName)
+			var fields *ValidationError
+			if errors.As(err, &fields) {
+				invalid.Fields = append(invalid.Fields, fields.Fields...)
+			} else if err != nil {
+				return plan, err
+			}
+			a.UserName = name
+			plan.Create = append(plan.Create, a)
+		case !sameRoles(user.Roles, a.Roles):
+			plan.Change = append(plan.Change, app.RoleChange{UserName: user.UserName, From: user.Roles, To: a.Roles})
+		}
+	}
+	if err := invalid.Err(); err != nil {
+		return plan, err
+	}
+	if !ignoreUnlisted {
+		for _, user := range current {
+			if !user.Superadmin && !listed[strings.ToLower(user.UserName)] {
+				plan.Delete = append(plan.Delete, user.UserName)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// sameRoles compares the primary role and the set of roles, ignoring case.
+func sameRoles(current, desired []string) bool {
+	if len(current) == 0 || !strings.EqualFold(current[0], desired[0]) {
+		return false
+	}
+	normalize := func(roles []string) []string {
+		set := map[string]bool{}
+		for _, r := range roles {
+			set[strings.ToLower(r)] = true
+		}
+		out := make([]string, 0, len(set))
+		for r := range set {
+			out = append(out, r)
+		}
+		sort.Strings(out)
+		return out
+	}
+	a, b := normalize(current), normalize(desired)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceRoles leaves the user with exactly the given roles. The primary role is kept
+// in user_roles by the users_primary_role trigger.
+func replaceRoles(ctx context.Context, tx pgx.Tx, userName string, roles []string, ids map[string]int) error {
+	roleIDs := make([]int, 0, len(roles))
+	for _, r := range roles {
+		roleIDs = append(roleIDs, ids[strings.ToLower(r)])
+	}
+	_, err := tx.Exec(ctx, `delete from user_roles where user_name = $1 and role <> all($2::int[])`, userName, roleIDs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `insert into user_roles(user_name, role) select $1, unnest($2::int[]) on conflict do nothing`,
+		userName, roleIDs)
+	return err
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"reflect"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+)
+
+func TestSameRoles(t *testing.T) {
+	for _, tc := range []struct {
+		current, desired []string
+		same             bool
+	}{
+		{[]string{"admin", "viewer"}, []string{"Admin", "VIEWER"}, true},
+		{[]string{"admin", "viewer"}, []string{"admin", "viewer", "viewer"}, true},
+		{[]string{"admin", "viewer"}, []string{"viewer", "admin"}, false},
+		{[]string{"admin", "viewer"}, []string{"admin"}, false},
+		{[]string{"admin"}, []string{"admin", "viewer"}, false},
+		{nil, []string{"admin"}, false},
+	} {
+		if got := sameRoles(tc.current, tc.desired); got != tc.same {
+			t.Errorf("sameRoles(%v, %v) = %v, want %v", tc.current, tc.desired, got, tc.same)
+		}
+	}
+}
+
+func TestDiffRoles(t *testing.T) {
+	current := []storedRoles{
+		{UserName: "Alice", Roles: []string{"admin"}},
+		{UserName: "bob", Roles: []string{"viewer", "editor"}},
+		{UserName: "carol", Roles: []string{"viewer"}},
+		{UserName: "root.admin", Roles: []string{"superadmin"}, Superadmin: true},
+	}
+	for _, tc := range []struct {
+		name           string
+		users          []app.RoleAssignment
+		ignoreUnlisted bool
+		want           app.RolePlan
+	}{
+		{
+			name: "unchanged, matched without case",
+			users: []app.RoleAssignment{{UserName: "alice", Roles: []string{"Admin"}},
+				{UserName: "BOB", Roles: []string{"viewer", "editor"}}, {UserName: "carol", Roles: []string{"viewer"}}},
+			want: app.RolePlan{Create: []app.RoleAssignment{}, Change: []app.RoleChange{}, Delete: []string{}},
+		},
+		{
+			name:  "create, change and delete",
+			users: []app.RoleAssignment{{UserName: "alice", Roles: []string{"viewer"}}, {UserName: "dave", Roles: []string{"editor"}}},
+			want: app.RolePlan{
+				Create: []app.RoleAssignment{{UserName: "dave", Roles: []string{"editor"}}},
+				Change: []app.RoleChange{{UserName: "Alice", From: []string{"admin"}, To: []string{"viewer"}}},
+				Delete: []string{"bob", "carol"},
+			},
+		},
+		{
+			name:           "unlisted users kept",
+			users:          []app.RoleAssignment{{UserName: "carol", Roles: []string{"viewer", "editor"}}},
+			ignoreUnlisted: true,
+			want: app.RolePlan{Create: []app.RoleAssignment{},
+				Change: []app.RoleChange{{UserName: "carol", From: []string{"viewer"}, To: []string{"viewer", "editor"}}},
+				Delete: []string{}},
+		},
+		{
+			name: "superadmins left out",
+			users: []app.RoleAssignment{{UserName: "ROOT.ADMIN", Roles: []string{"viewer"}},
+				{UserName: "alice", Roles: []string{"admin"}}, {UserName: "bob", Roles: []string{"viewer", "editor"}},
+				{UserName: "carol", Roles: []string{"viewer"}}},
+			want: app.RolePlan{Create: []app.RoleAssignment{}, Change: []app.RoleChange{}, Delete: []string{}},
+		},
+	} {
+		plan, err := diffRoles(tc.users, current, tc.ignoreUnlisted)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(plan, tc.want) {
+			t.Errorf("%s: plan %+v, want %+v", tc.name, plan, tc.want)
+		}
+	}
+
+	_, err := diffRoles([]app.RoleAssignment{{UserName: "x", Roles: []string{"viewer"}},
+		{UserName: "alice", Roles: []string{"admin"}}, {UserName: "root", Roles: []string{"viewer"}}}, current, true)
+	want := &ValidationError{Fields: []FieldError{
+		{Field: "users[0].user_name", Reason: "must be at least 3 characters"},
+		{Field: "users[2].user_name", Reason: "is reserved"},
+	}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("invalid new users: %v, want %v", err, want)
+	}
+}
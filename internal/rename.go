@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"testgenerate_backend_user/internal/app"
+)
+
+// userAliasDays is how long the former name of a renamed user resolves to the user.
+func userAliasDays() int {
+	return app.GetEnvAsInt("USER_ALIAS_DAYS", 30)
+}
+
+// RenameUser changes the name of a user. Roles, groups and audit events follow the user,
+// and the old name stays an alias of the user for USER_ALIAS_DAYS. Renaming a user back
+// to one of its aliases drops that alias.
+func (u userService) RenameUser(ctx context.Context, userName, newName, actor string) (app.User, error) {
+	newName, err := checkUserName("user_name", newName)
+	if err != nil {
+		return app.User{}, fmt.Errorf("RenameUser: %w", err)
+	}
+	if newName == userName {
+		return app.User{}, fmt.Errorf("RenameUser: %s is the current name: %w", newName, ErrInvalidArgument)
+	}
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return app.User{}, fmt.Errorf("RenameUser. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, errR := protectAccount(ctx, tx, userName); errR != nil {
+			return errR
+		}
+		var (
+			id    string
+			taken bool
+		)
+		//A change of case only is a rename as well
+		errR := tx.QueryRow(ctx, `select u.id, exists(select 1 from users where lower(user_name) = lower($2) and id <> u.id)
+					or exists(select 1 from user_alias a
+						where lower(a.alias) = lower($2) and a.user_id <> u.id and a.expires_at > now())
+				from users u where u.user_name = $1 and u.deleted_at is null and org_visible(u.org_id)
+				for update`, userName, newName).Scan(&id, &taken)
+		if errors.Is(errR, pgx.ErrNoRows) {
+			return fmt.Errorf("user %s: %w", userName, ErrNotFound)
+		}
+		if errR != nil {
+			return errR
+		}
+		if taken {
+			return fmt.Errorf("user %s: %w", newName, ErrAlreadyExists)
+		}
+
+		//Names are unique across organizations, so the name may be taken by a user the query did not see
+		_, errR = tx.Exec(ctx, `update users set user_name = $2 where id = $1`, id, newName)
+		if isUniqueViolation(errR) {
+			return fmt.Errorf("user %s: %w", newName, ErrAlreadyExists)
+		}
+		if errR != nil {
+			return errR
+		}
+		if _, errR = tx.Exec(ctx, `delete from user_alias where lower(alias) = lower($1)`, newName); errR != nil {
+			return errR
+		}
+		_, errR = tx.Exec(ctx, `insert into user_alias(alias, user_id, expires_at)
+				values($1, $2, now() + make_interval(days => $3))
+				on conflict (alias) do update set user_id = excluded.user_id, create_time = now(),
+					expires_at = excluded.expires_at`, userName, id, userAliasDays())
+		if errR != nil {
+			return errR
+		}
+		return recordAuditEvent(ctx, tx, actor, "user.renamed", newName, map[string]interface{}{
+			"from": userName,
+			"to":   newName,
+		})
+	})
+	if isGuardError(err) {
+		return app.User{}, fmt.Errorf("RenameUser: %w", err)
+	}
+	if err != nil {
+		return app.User{}, fmt.Errorf("RenameUser: %v\n", err)
+	}
+	return u.GetUser(ctx, newName, "")
+}
+
+// resolveUserName returns the current name of the user, following the alias of a renamed user.
+// Unknown names are returned as they are, the service reports them.
+func resolveUserName(ctx context.Context, userName string) (string, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return "", fmt.Errorf("resolveUserName. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	current, err := resolveName(ctx, conn, userName)
+	if err != nil {
+		return "", fmt.Errorf("resolveUserName QueryRow: %v\n", err)
+	}
+	return current, nil
+}
+
+// resolveName normalizes and resolves a user name within the transaction of a change,
+// so that users added earlier in the same transaction are found.
+func resolveName(ctx context.Context, db queryRower, userName string) (string, error) {
+	var current string
+	err := db.QueryRow(ctx, `select resolve_user_name($1)`, normalizeUserName(userName)).Scan(&current)
+	return current, err
+}
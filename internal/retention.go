@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"testgenerate_backend_user/internal/app"
+)
+
+// userRetentionDays is how long a deleted user can be restored before it is purged.
+func userRetentionDays() int {
+	return app.GetEnvAsInt("USER_RETENTION_DAYS", 30)
+}
+
+func (u userService) RestoreUser(ctx context.Context, userName string) error {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("RestoreUser. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tag, err := conn.Exec(ctx, `update users set deleted_at = null
+			where user_name = $1 and deleted_at > now() - make_interval(days => $2) and org_visible(org_id)`,
+		userName, userRetentionDays())
+	if err != nil {
+		return fmt.Errorf("RestoreUser conn.Exec: %v\n", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("RestoreUser: deleted user %s within retention: %w", userName, ErrNotFound)
+	}
+	return nil
+}
+
+// PurgeDeletedUsers removes users past retention and forgets the expired aliases of renamed users.
+func (u userService) PurgeDeletedUsers(ctx context.Context) (int64, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("PurgeDeletedUsers. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tag, err := conn.Exec(ctx, `delete from users where deleted_at < now() - make_interval(days => $1) and org_visible(org_id)`,
+		userRetentionDays())
+	if err != nil {
+		return 0, fmt.Errorf("PurgeDeletedUsers conn.Exec: %v\n", err)
+	}
+	if _, err = conn.Exec(ctx, `delete from user_alias where expires_at <= now()`); err != nil {
+		return 0, fmt.Errorf("PurgeDeletedUsers delete from user_alias: %v\n", err)
+	}
+	return tag.RowsAffected(), nil
+}
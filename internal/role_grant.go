@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"testgenerate_backend_user/internal/app"
+	"time"
+)
+
+func (u userService) GetRoleExpirations(ctx context.Context, within time.Duration, filter app.UserFilter) ([]app.User, error) {
+	var users []app.User
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return users, fmt.Errorf("GetRoleExpirations. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, `select to_json(t.*) from (`+userSelect+`
+				where org_visible(users.org_id) and users.deleted_at is null and users.role_expires_at <= now() + make_interval(secs => $1)
+					and ($2 = '' or users.user_name in (`+managedUsers+`))
+				order by users.role_expires_at) t`, within.Seconds(), filter.ManagedBy)
+	if err != nil {
+		return users, fmt.Errorf("GetRoleExpirations Query: %v\n", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res string
+		if err = rows.Scan(&res); err != nil {
+			return users, fmt.Errorf("GetRoleExpirations rows.Scan: %v\n", err)
+		}
+		var result app.User
+		if err = json.Unmarshal([]byte(res), &result); err != nil {
+			return users, fmt.Errorf("GetRoleExpirations json.Unmarshal: %v\n", err)
+		}
+		users = append(users, result)
+	}
+	return users, rows.Err()
+}
+
+// ExpireRoleGrants persists the downgrade of roles whose role_expires_at has passed.
+// Authorization already uses the fallback role from that moment, see checkAccount.
+func (u userService) ExpireRoleGrants(ctx context.Context) (int64, error) {
+	var errE error
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ExpireRoleGrants. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("ExpireRoleGrants conn.BeginTx %v\n", err)
+	}
+	defer func() {
+		if errE != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	rows, errE := tx.Query(ctx, `with expired as (
+				select user_name, role from users
+				where role_expires_at <= now() and deleted_at is null and org_visible(org_id)
+				for update)
+			update users set role = coalesce(users.fallback_role, users.role), role_expires_at = null, fallback_role = null
+			from expired where users.user_name = expired.user_name
+			returning users.user_name, expired.role, users.role`)
+	if errE != nil {
+		errE = fmt.Errorf("ExpireRoleGrants update users: %v\n", errE)
+		return 0, errE
+	}
+	type downgrade struct {
+		userName     string
+		fromID, toID int
+	}
+	var downgrades []downgrade
+	for rows.Next() {
+		var d downgrade
+		if errE = rows.Scan(&d.userName, &d.fromID, &d.toID); errE != nil {
+			rows.Close()
+			errE = fmt.Errorf("ExpireRoleGrants rows.Scan: %v\n", errE)
+			return 0, errE
+		}
+		downgrades = append(downgrades, d)
+	}
+	rows.Close()
+	if errE = rows.Err(); errE != nil {
+		errE = fmt.Errorf("ExpireRoleGrants rows: %v\n", errE)
+		return 0, errE
+	}
+
+	for _, d := range downgrades {
+		errE = recordAuditEvent(ctx, tx, "system", "role.expired", d.userName, map[string]interface{}{
+			"from_role_id": d.fromID,
+			"to_role_id":   d.toID,
+		})
+		if errE != nil {
+			return 0, errE
+		}
+	}
+	return int64(len(downgrades)), nil
+}
@@ -0,0 +1,375 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"sort"
+	"strings"
+	"sync"
+	"testgenerate_backend_user/internal/app"
+	"time"
+)
+
+const (
+	PermUsersRead         = "users.read"
+	PermUsersWrite        = "users.write"
+	PermRolesRead         = "roles.read"
+	PermRolesWrite        = "roles.write"
+	PermInvitationsManage = "invitations.manage"
+	PermGroupsManage      = "groups.manage"
+)
+
+// knownPermissions are the permissions roles can carry.
+var knownPermissions = []string{PermUsersRead, PermUsersWrite, PermRolesRead, PermRolesWrite,
+	PermInvitationsManage, PermGroupsManage}
+
+// checkPermissionNames rejects permissions that are not in knownPermissions.
+func checkPermissionNames(permissions []string) error {
+	var invalid ValidationError
+	for i, p := range permissions {
+		if !contains(knownPermissions, p) {
+			invalid.Add(fmt.Sprintf("permissions[%d]", i), "must be one of "+strings.Join(knownPermissions, ", "))
+		}
+	}
+	return invalid.Err()
+}
+
+// roleGraphCache keeps the roles with their parents and the permissions resolved through
+// the hierarchy. It is dropped on every role change in this instance and reloaded after
+// ROLE_CACHE_SECONDS at the latest, which bounds staleness when another instance changed roles.
+// It spans all organizations: ids only holds the global roles, orgs the organization of the others.
+type roleGraphCache struct {
+	mu       sync.RWMutex
+	loadedAt time.Time
+	ids      map[string]int
+	orgs     map[int]int
+	resolved map[int][]string
+}
+
+var roleGraph = &roleGraphCache{}
+
+func (c *roleGraphCache) invalidate() {
+	c.mu.Lock()
+	c.resolved = nil
+	c.mu.Unlock()
+}
+
+// permissions returns the union of the resolved permissions of the roles given by id,
+// or by name for global roles.
+func (c *roleGraphCache) permissions(ctx context.Context, roles []app.Role) (map[string]bool, error) {
+	if err := c.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	perms := map[string]bool{}
+	for _, role := range roles {
+		id := role.ID
+		if id == 0 {
+			id = c.ids[strings.ToLower(role.Role)]
+		}
+		for _, p := range c.resolved[id] {
+			perms[p] = true
+		}
+	}
+	return perms, nil
+}
+
+// rolePermissions returns the resolved permissions of a role that is global or belongs to the organization of ctx.
+func (c *roleGraphCache) rolePermissions(ctx context.Context, roleID int) ([]string, bool, error) {
+	if err := c.ensureLoaded(ctx); err != nil {
+		return nil, false, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	perms, ok := c.resolved[roleID]
+	if org, scoped := c.orgs[roleID]; ok && scoped {
+		tenant, all := app.TenantFrom(ctx)
+		ok = all || org == tenant
+	}
+	return perms, ok, nil
+}
+
+func (c *roleGraphCache) ensureLoaded(ctx context.Context) error {
+	ttl := time.Duration(app.GetEnvAsInt("ROLE_CACHE_SECONDS", 60)) * time.Second
+	c.mu.RLock()
+	fresh := c.resolved != nil && time.Since(c.loadedAt) < ttl
+	c.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	ids, orgs, resolved, err := loadRoleGraph(app.WithAllTenants(ctx))
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.ids, c.orgs, c.resolved, c.loadedAt = ids, orgs, resolved, time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+func loadRoleGraph(ctx context.Context) (map[string]int, map[int]int, map[int][]string, error) {
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("loadRoleGraph. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	ids := map[string]int{}
+	orgs := map[int]int{}
+	all := map[int]bool{}
+	parents := map[int][]int{}
+	direct := map[int][]string{}
+
+	rows, err := conn.Query(ctx, `select id, role_name, org_id from user_role`)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("loadRoleGraph select user_role: %v\n", err)
+	}
+	for rows.Next() {
+		var (
+			id   int
+			name string
+			org  *int
+		)
+		if err = rows.Scan(&id, &name, &org); err != nil {
+			rows.Close()
+			return nil, nil, nil, fmt.Errorf("loadRoleGraph rows.Scan: %v\n", err)
+		}
+		all[id] = true
+		if org != nil {
+			orgs[id] = *org
+		} else {
+			ids[strings.ToLower(name)] = id
+		}
+	}
+	rows.Close()
+
+	rows, err = conn.Query(ctx, `select role, parent from role_parent`)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("loadRoleGraph select role_parent: %v\n", err)
+	}
+	for rows.Next() {
+		var role, parent int
+		if err = rows.Scan(&role, &parent); err != nil {
+			rows.Close()
+			return nil, nil, nil, fmt.Errorf("loadRoleGraph rows.Scan: %v\n", err)
+		}
+		parents[role] = append(parents[role], parent)
+	}
+	rows.Close()
+
+	rows, err = conn.Query(ctx, `select role, permission from role_permission`)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("loadRoleGraph select role_permission: %v\n", err)
+	}
+	for rows.Next() {
+		var (
+			role       int
+			permission string
+		)
+		if err = rows.Scan(&role, &permission); err != nil {
+			rows.Close()
+			return nil, nil, nil, fmt.Errorf("loadRoleGraph rows.Scan: %v\n", err)
+		}
+		direct[role] = append(direct[role], permission)
+	}
+	rows.Close()
+
+	resolved := resolvePermissions(all, parents, direct)
+	return ids, orgs, resolved, nil
+}
+
+// resolvePermissions returns the sorted permissions of each role of all, its direct ones and
+// those of its ancestors. Roles are visited once, so cycles in parents end the walk.
+func resolvePermissions(all map[int]bool, parents map[int][]int, direct map[int][]string) map[int][]string {
+	resolved := map[int][]string{}
+	for id := range all {
+		set := map[string]bool{}
+		visited := map[int]bool{}
+		var walk func(int)
+		walk = func(r int) {
+			if visited[r] {
+				return
+			}
+			visited[r] = true
+			for _, p := range direct[r] {
+				set[p] = true
+			}
+			for _, p := range parents[r] {
+				walk(p)
+			}
+		}
+		walk(id)
+		perms := make([]string, 0, len(set))
+		for p := range set {
+			perms = append(perms, p)
+		}
+		sort.Strings(perms)
+		resolved[id] = perms
+	}
+	return resolved
+}
+
+// formsCycle reports whether giving role the parents newParents makes it its own ancestor,
+// with the other roles keeping their parents in graph.
+func formsCycle(role int, newParents []int, graph map[int][]int) bool {
+	visited := map[int]bool{}
+	queue := append([]int(nil), newParents...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == role {
+			return true
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		queue = append(queue, graph[id]...)
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+func (u userService) GetRolePermissions(ctx context.Context, roleID int) ([]string, error) {
+	perms, ok, err := roleGraph.rolePermissions(ctx, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("GetRolePermissions: %v\n", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("GetRolePermissions: role %d: %w", roleID, ErrNotFound)
+	}
+	return perms, nil
+}
+
+// UpdateRole replaces the name, the parents and the direct permissions of a role.
+// A parent must not inherit from the role itself, directly or through other roles.
+func (u userService) UpdateRole(ctx context.Context, role app.Role, actor string) error {
+	var errU error
+	if err := checkPermissionNames(role.Permissions); err != nil {
+		return fmt.Errorf("UpdateRole: %w", err)
+	}
+	for _, p := range role.Parents {
+		if p == role.ID {
+			return fmt.Errorf("UpdateRole: role %d cannot be its own parent: %w", role.ID, ErrInvalidArgument)
+		}
+	}
+
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateRole. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("UpdateRole conn.BeginTx %v\n", err)
+	}
+	defer func() {
+		if errU != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+			roleGraph.invalidate()
+		}
+	}()
+
+	//Concurrent updates could otherwise close a cycle between them
+	if _, errU = tx.Exec(ctx, `lock table role_parent in share row exclusive mode`); errU != nil {
+		errU = fmt.Errorf("UpdateRole lock role_parent: %v\n", errU)
+		return errU
+	}
+
+	//Global roles are changed only across all organizations
+	tag, errU := tx.Exec(ctx, `update user_role set role_name = coalesce(nullif($2, ''), role_name)
+			where id = $1 and org_visible(org_id)`,
+		role.ID, role.Role)
+	if isUniqueViolation(errU) {
+		errU = fmt.Errorf("UpdateRole: role %s: %w", role.Role, ErrAlreadyExists)
+		return errU
+	}
+	if errU != nil {
+		errU = fmt.Errorf("UpdateRole update user_role: %v\n", errU)
+		return errU
+	}
+	if tag.RowsAffected() == 0 {
+		errU = fmt.Errorf("UpdateRole: role %d: %w", role.ID, ErrNotFound)
+		return errU
+	}
+
+	//A role inherits from global roles and from roles of its own organization only
+	var foreign bool
+	errU = tx.QueryRow(ctx, `select exists(select 1 from unnest($2::int[]) p(id)
+				left join user_role r on r.id = p.id
+				where r.id is null or (r.org_id is not null
+					and r.org_id is distinct from (select org_id from user_role where id = $1)))`,
+		role.ID, role.Parents).Scan(&foreign)
+	if errU != nil {
+		errU = fmt.Errorf("UpdateRole select parents: %v\n", errU)
+		return errU
+	}
+	if foreign {
+		errU = fmt.Errorf("UpdateRole: unknown parent role: %w", ErrInvalidArgument)
+		return errU
+	}
+
+	graph := map[int][]int{}
+	rows, errU := tx.Query(ctx, `select role, parent from role_parent`)
+	if errU != nil {
+		errU = fmt.Errorf("UpdateRole select role_parent: %v\n", errU)
+		return errU
+	}
+	for rows.Next() {
+		var child, parent int
+		if errU = rows.Scan(&child, &parent); errU != nil {
+			rows.Close()
+			errU = fmt.Errorf("UpdateRole rows.Scan: %v\n", errU)
+			return errU
+		}
+		graph[child] = append(graph[child], parent)
+	}
+	rows.Close()
+	if errU = rows.Err(); errU != nil {
+		errU = fmt.Errorf("UpdateRole select role_parent: %v\n", errU)
+		return errU
+	}
+	if formsCycle(role.ID, role.Parents, graph) {
+		errU = fmt.Errorf("UpdateRole: parents of role %d would form a cycle: %w", role.ID, ErrInvalidArgument)
+		return errU
+	}
+
+	if _, errU = tx.Exec(ctx, `delete from role_parent where role = $1`, role.ID); errU != nil {
+		errU = fmt.Errorf("UpdateRole delete from role_parent: %v\n", errU)
+		return errU
+	}
+	_, errU = tx.Exec(ctx, `insert into role_parent(role, parent) select $1, unnest($2::int[]) on conflict do nothing`,
+		role.ID, role.Parents)
+	if isForeignKeyViolation(errU) {
+		errU = fmt.Errorf("UpdateRole: unknown parent role: %w", ErrInvalidArgument)
+		return errU
+	}
+	if errU != nil {
+		errU = fmt.Errorf("UpdateRole insert into role_parent: %v\n", errU)
+		return errU
+	}
+
+	if _, errU = tx.Exec(ctx, `delete from role_permission where role = $1`, role.ID); errU != nil {
+		errU = fmt.Errorf("UpdateRole delete from role_permission: %v\n", errU)
+		return errU
+	}
+	_, errU = tx.Exec(ctx, `insert into role_permission(role, permission) select $1, unnest($2::text[]) on conflict do nothing`,
+		role.ID, role.Permissions)
+	if errU != nil {
+		errU = fmt.Errorf("UpdateRole insert into role_permission: %v\n", errU)
+		return errU
+	}
+
+	errU = recordAuditEvent(ctx, tx, actor, "role.updated", "", map[string]interface{}{
+		"role_id":     role.ID,
+		"parents":     role.Parents,
+		"permissions": role.Permissions,
+	})
+	return errU
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFormsCycle(t *testing.T) {
+	// 1 <- 2 <- 3, 4 without parents
+	graph := map[int][]int{2: {1}, 3: {2}}
+	for _, tc := range []struct {
+		name    string
+		role    int
+		parents []int
+		cycle   bool
+	}{
+		{"no parents", 1, nil, false},
+		{"own parent", 1, []int{1}, true},
+		{"parent of an ancestor", 1, []int{3}, true},
+		{"parent of the direct child", 1, []int{2}, true},
+		{"unrelated parent", 1, []int{4}, false},
+		{"ancestor as parent", 3, []int{1}, false},
+		{"one of several parents", 2, []int{4, 3}, true},
+		{"replaced parents", 2, []int{4}, false},
+		{"unknown parent", 4, []int{9}, false},
+	} {
+		if got := formsCycle(tc.role, tc.parents, graph); got != tc.cycle {
+			t.Errorf("%s: formsCycle(%d, %v) = %v, want %v", tc.name, tc.role, tc.parents, got, tc.cycle)
+		}
+	}
+}
+
+func TestResolvePermissions(t *testing.T) {
+	all := map[int]bool{1: true, 2: true, 3: true, 4: true, 5: true, 6: true}
+	// 3 inherits from 2 and 4, 2 from 1, 5 and 6 are parents of each other
+	parents := map[int][]int{2: {1}, 3: {2, 4}, 5: {6}, 6: {5}}
+	direct := map[int][]string{
+		1: {PermUsersRead},
+		2: {PermUsersWrite, PermUsersRead},
+		4: {PermRolesRead},
+		5: {PermGroupsManage},
+		6: {PermInvitationsManage},
+	}
+	want := map[int][]string{
+		1: {PermUsersRead},
+		2: {PermUsersRead, PermUsersWrite},
+		3: {PermRolesRead, PermUsersRead, PermUsersWrite},
+		4: {PermRolesRead},
+		5: {PermGroupsManage, PermInvitationsManage},
+		6: {PermGroupsManage, PermInvitationsManage},
+	}
+	if got := resolvePermissions(all, parents, direct); !reflect.DeepEqual(got, want) {
+		t.Errorf("resolvePermissions = %v, want %v", got, want)
+	}
+}
+
+func TestCheckPermissionNames(t *testing.T) {
+	if err := checkPermissionNames([]string{PermUsersRead, PermGroupsManage}); err != nil {
+		t.Errorf("known permissions: %v", err)
+	}
+	err := checkPermissionNames([]string{PermUsersRead, "user.write", "Users.Read"})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("error %v, want a ValidationError", err)
+	}
+	var fields []string
+	for _, f := range invalid.Fields {
+		fields = append(fields, f.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"permissions[1]", "permissions[2]"}) {
+		t.Errorf("fields %v", fields)
+	}
+}
+
+// useRoleGraph replaces the cached role graph for the duration of a test.
+func useRoleGraph(t *testing.T, resolved map[int][]string) {
+	saved := roleGraph
+	roleGraph = &roleGraphCache{loadedAt: time.Now(), ids: map[string]int{}, orgs: map[int]int{}, resolved: resolved}
+	t.Cleanup(func() { roleGraph = saved })
+}
@@ -3,21 +3,66 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-kit/kit/metrics"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
+	"net/mail"
+	"strconv"
+	"strings"
 	"testgenerate_backend_user/internal/app"
 	"time"
 )
 
 type Service interface {
 	GetRoles(ctx context.Context) ([]app.Role, error)
+	GetRolePermissions(ctx context.Context, roleID int) ([]string, error)
+	UpdateRole(ctx context.Context, role app.Role, actor string) error
 	GetUser(ctx context.Context, userName, userRole string) (app.User, error)
-	GetUsersRole(ctx context.Context) ([]app.User, error)
+	GetUsersRole(ctx context.Context, filter app.UserFilter) ([]app.User, error)
+	StreamUsers(ctx context.Context, filter app.UserFilter, emit func(app.User) error) error
 	AddUser(ctx context.Context, userAdd app.User) error
 	UpdateUser(ctx context.Context, user app.User) error
+	PatchUser(ctx context.Context, userName string, patch app.UserPatch) (app.User, error)
 	DeleteUser(ctx context.Context, userName string) error
+	RestoreUser(ctx context.Context, userName string) error
+	RenameUser(ctx context.Context, userName, newName, actor string) (app.User, error)
+	GetGroups(ctx context.Context) ([]app.Group, error)
+	CreateGroup(ctx context.Context, group app.Group, actor string) (app.Group, error)
+	UpdateGroup(ctx context.Context, group app.Group, actor string) error
+	DeleteGroup(ctx context.Context, groupID int, actor string) error
+	AddGroupMember(ctx context.Context, groupID int, userName, actor string) error
+	RemoveGroupMember(ctx context.Context, groupID int, userName, actor string) error
+	AddGroupManager(ctx context.Context, groupID int, userName, actor string) error
+	RemoveGroupManager(ctx context.Context, groupID int, userName, actor string) error
+	GrantGroupRole(ctx context.Context, groupID int, role app.Role, actor string) error
+	RevokeGroupRole(ctx context.Context, groupID, roleID int, actor string) error
+	GrantRole(ctx context.Context, userName string, role app.Role, actor string) error
+	RevokeRole(ctx context.Context, userName string, roleID int, actor string) error
+	ImportUsers(ctx context.Context, records []app.UserRecord, opts app.ImportOptions, actor string) (app.ImportReport, error)
+	ExportUsers(ctx context.Context) ([]app.UserRecord, error)
+	BatchUsers(ctx context.Context, batch app.UserBatch, actor string) (app.BatchReport, error)
+	PlanRoles(ctx context.Context, desired app.DesiredRoles) (app.RolePlan, error)
+	ApplyRoles(ctx context.Context, desired app.DesiredRoles, actor string) (app.RolePlan, error)
+	GetRoleExpirations(ctx context.Context, within time.Duration, filter app.UserFilter) ([]app.User, error)
+	ExpireRoleGrants(ctx context.Context) (int64, error)
+	PurgeDeletedUsers(ctx context.Context) (int64, error)
+	RecordLogin(ctx context.Context, userName string) (app.User, error)
+	SetUserStatus(ctx context.Context, userName string, change app.StatusChange) error
+	UnlockExpiredAccounts(ctx context.Context) (int64, error)
+	CountUsersByStatus(ctx context.Context) (map[string]int, error)
+	GetAuditEvents(ctx context.Context, filter app.AuditFilter) ([]app.AuditEvent, error)
+
+	CreateInvitation(ctx context.Context, invitation app.Invitation, createdBy string) (app.Invitation, error)
+	GetInvitations(ctx context.Context) ([]app.Invitation, error)
+	RevokeInvitation(ctx context.Context, id int) error
+	RedeemInvitation(ctx context.Context, code string, userAdd app.User) error
+	CleanupInvitations(ctx context.Context) (int64, error)
+
+	GetOrganizations(ctx context.Context) ([]app.Organization, error)
+	CreateOrganization(ctx context.Context, org app.Organization, actor string) (app.Organization, error)
 }
 
 type userService struct {
@@ -40,15 +85,81 @@ func NewService(logger *logrus.Logger, requestCount metrics.Counter, requestLate
 	return svc
 }
 
-// ----------------------------------------------------------------------------------------------------------------------
-func (u userService) GetRoles(ctx context.Context) ([]app.Role, error) {
-	var roles []app.Role
+const userSelect = `select users.user_name, ur.role_name, ur.id as role_id,
+		(select json_agg(json_build_object('id', r.id, 'role_name', r.role_name) order by x.role <> users.role, r.id)
+			from user_roles x join user_role r on r.id = x.role
+			where x.user_name = users.user_name) as roles,
+		(select json_agg(json_build_object('id', r.id, 'role_name', r.role_name,
+					'group_id', ug.id, 'group_name', ug.name) order by r.id, ug.id)
+			from user_effective_group eg join group_role gr on gr.group_id = eg.group_id
+				join user_role r on r.id = gr.role join user_group ug on ug.id = eg.group_id
+			where eg.user_name = users.user_name) as inherited_roles,
+		users.create_time::date,
+		users.email, users.display_name, users.status, users.last_login_at, users.locale, users.timezone,
+		users.status_reason, users.locked_until, users.deleted_at,
+		users.role_expires_at, fr.id as fallback_role_id, fr.role_name as fallback_role_name, users.org_id,
+		users.id, (select json_agg(a.alias order by a.create_time) from user_alias a
+			where a.user_id = users.id and a.expires_at > now()) as aliases
+	from users left join user_role ur on ur.id = users.role
+		left join user_role fr on fr.id = users.fallback_role`
+
+// managedUsers selects the members of the groups managed by the team lead $2, including subgroups.
+const managedUsers = `select eg.user_name from user_effective_group eg
+		join group_manager gm on gm.group_id = eg.group_id where gm.user_name = $2`
+
+// connectDB opens a connection bound to the organization of ctx: app.tenant_id is read by
+// org_visible() in queries and by the row-level security policies, see migrations/009.
+func connectDB(ctx context.Context) (*pgx.Conn, error) {
 	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s"+
 		" password=%s dbname=%s sslmode=disable",
 		app.GetEnv("DB_HOST", "localhost"), app.GetEnvAsInt("DB_PORT", 5432),
 		app.GetEnv("DB_USER", "postgres"), app.GetEnv("DB_PASSWORD", "pgpassword"),
 		app.GetEnv("DB_NAME", "generate"))
 	conn, err := pgx.Connect(ctx, psqlInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := "all"
+	if id, all := app.TenantFrom(ctx); !all {
+		tenant = strconv.Itoa(id)
+	}
+	if _, err = conn.Exec(ctx, `select set_config('app.tenant_id', $1, false)`, tenant); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// CheckDatabaseRole fails when the service connects with a role that bypasses row-level security,
+// a superuser or a role with BYPASSRLS. The policies of migration 009 would not separate
+// the organizations for it.
+func CheckDatabaseRole(ctx context.Context) error {
+	conn, err := connectDB(app.WithAllTenants(ctx))
+	if err != nil {
+		return fmt.Errorf("CheckDatabaseRole. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	var (
+		name   string
+		bypass bool
+	)
+	err = conn.QueryRow(ctx, `select rolname, rolsuper or rolbypassrls from pg_roles where rolname = current_user`).
+		Scan(&name, &bypass)
+	if err != nil {
+		return fmt.Errorf("CheckDatabaseRole QueryRow: %v\n", err)
+	}
+	if bypass {
+		return fmt.Errorf("database role %s bypasses row-level security, set DB_USER to a role without SUPERUSER and BYPASSRLS", name)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+func (u userService) GetRoles(ctx context.Context) ([]app.Role, error) {
+	var roles []app.Role
+	conn, err := connectDB(ctx)
 	if err != nil {
 		erRet := fmt.Errorf("GetRoles. Unable to connect to database: %v\n", err)
 		return roles, erRet
@@ -56,7 +167,12 @@ func (u userService) GetRoles(ctx context.Context) ([]app.Role, error) {
 	defer conn.Close(ctx)
 
 	rows, errRows := conn.Query(ctx, `select to_json(t.*)
-					from (select id, role_name from user_role) t`)
+					from (select id, role_name, org_id,
+							(select array_agg(parent order by parent) from role_parent where role = user_role.id) as parents,
+							(select array_agg(permission order by permission) from role_permission
+								where role = user_role.id) as permissions
+						from user_role
+						where org_id is null or org_visible(org_id)) t`)
 	if errRows != nil {
 		erResp := fmt.Errorf("GetRoles QueryRow: %v\n", errRows)
 		return roles, erResp
@@ -81,76 +197,88 @@ func (u userService) GetRoles(ctx context.Context) ([]app.Role, error) {
 }
 func (u userService) GetUser(ctx context.Context, user, role string) (app.User, error) {
 	var userRole app.User
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s"+
-		" password=%s dbname=%s sslmode=disable",
-		app.GetEnv("DB_HOST", "localhost"), app.GetEnvAsInt("DB_PORT", 5432),
-		app.GetEnv("DB_USER", "postgres"), app.GetEnv("DB_PASSWORD", "pgpassword"),
-		app.GetEnv("DB_NAME", "generate"))
-	conn, err := pgx.Connect(ctx, psqlInfo)
+	conn, err := connectDB(ctx)
 	if err != nil {
 		erRet := fmt.Errorf("GetUser. Unable to connect to database: %v\n", err)
 		return userRole, erRet
 	}
 	defer conn.Close(ctx)
 
-	err = conn.QueryRow(context.Background(),
-		`select users.user_name, ur.role_name, users.create_time::date 
-				from users left join user_role ur on ur.id = users.role 
-                where users.user_name = $1`, user).Scan(&userRole.Name, &userRole.Role, userRole.CreateTime)
+	var res string
+	err = conn.QueryRow(ctx, `select to_json(t.*) from (`+userSelect+`
+				where org_visible(users.org_id) and users.deleted_at is null and users.user_name = $1) t`, user).Scan(&res)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return userRole, fmt.Errorf("GetUser: user %s: %w", user, ErrNotFound)
+	}
 	if err != nil {
 		erRet := fmt.Errorf("GetUser. QueryRow: %v\n", err)
 		return userRole, erRet
 	}
+	if err = json.Unmarshal([]byte(res), &userRole); err != nil {
+		return userRole, fmt.Errorf("GetUser json.Unmarshal: %v\n", err)
+	}
 
 	return userRole, nil
 }
-func (u userService) GetUsersRole(ctx context.Context) ([]app.User, error) {
+func (u userService) GetUsersRole(ctx context.Context, filter app.UserFilter) ([]app.User, error) {
 	var users []app.User
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s"+
-		" password=%s dbname=%s sslmode=disable",
-		app.GetEnv("DB_HOST", "localhost"), app.GetEnvAsInt("DB_PORT", 5432),
-		app.GetEnv("DB_USER", "postgres"), app.GetEnv("DB_PASSWORD", "pgpassword"),
-		app.GetEnv("DB_NAME", "generate"))
-	conn, err := pgx.Connect(ctx, psqlInfo)
+	err := u.StreamUsers(ctx, filter, func(user app.User) error {
+		users = append(users, user)
+		return nil
+	})
+	return users, err
+}
+
+// StreamUsers passes the users to emit one by one while they are read from the database cursor,
+// so large organizations are listed without holding all of them in memory. An error of emit
+// stops the listing and is returned as it is.
+func (u userService) StreamUsers(ctx context.Context, filter app.UserFilter, emit func(app.User) error) error {
+	conn, err := connectDB(ctx)
 	if err != nil {
-		erRet := fmt.Errorf("GetUsersRole. Unable to connect to database: %v\n", err)
-		return users, erRet
+		return fmt.Errorf("StreamUsers. Unable to connect to database: %v\n", err)
 	}
 	defer conn.Close(ctx)
 
-	rows, errRows := conn.Query(ctx, `select to_json(t.*)
-					from (select users.user_name, ur.role_name,ur.id as role_id, users.create_time::date
-							from users left join user_role ur on ur.id = users.role) t`)
-	if errRows != nil {
-		erResp := fmt.Errorf("GetUsersRole QueryRow: %v\n", errRows)
-		return users, erResp
+	rows, err := conn.Query(ctx, `select to_json(t.*) from (`+userSelect+`
+				where org_visible(users.org_id) and ($1 or users.deleted_at is null)
+					and ($2 = '' or users.user_name in (`+managedUsers+`))
+				order by users.user_name) t`,
+		filter.IncludeDeleted, filter.ManagedBy)
+	if err != nil {
+		return fmt.Errorf("StreamUsers Query: %v\n", err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var res string
-		errScan := rows.Scan(&res)
-		if errScan != nil {
-			erRet := fmt.Errorf("GetUsersRole rows.Scan: %v\n", errScan)
-			return users, erRet
+		var res []byte
+		if err = rows.Scan(&res); err != nil {
+			return fmt.Errorf("StreamUsers rows.Scan: %v\n", err)
 		}
-		var result app.User
-		errU := json.Unmarshal([]byte(res), &result)
-		if errU != nil {
-			erRet := fmt.Errorf("GetUsersRole json.Unmarshal: %v\n", errU)
-			return users, erRet
+		var user app.User
+		if err = json.Unmarshal(res, &user); err != nil {
+			return fmt.Errorf("StreamUsers json.Unmarshal: %v\n", err)
 		}
-		users = append(users, result)
+		if err = emit(user); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("StreamUsers rows: %v\n", err)
 	}
-	return users, nil
+	return nil
 }
 func (u userService) AddUser(ctx context.Context, userAdd app.User) error {
 	var errA error
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s"+
-		" password=%s dbname=%s sslmode=disable",
-		app.GetEnv("DB_HOST", "localhost"), app.GetEnvAsInt("DB_PORT", 5432),
-		app.GetEnv("DB_USER", "postgres"), app.GetEnv("DB_PASSWORD", "pgpassword"),
-		app.GetEnv("DB_NAME", "generate"))
-	conn, err := pgx.Connect(ctx, psqlInfo)
+	userAdd.Status = app.UserStatusActive
+	name, err := checkUserName("user_name", userAdd.Name)
+	if err != nil {
+		return fmt.Errorf("AddUser: %w", err)
+	}
+	userAdd.Name = name
+	if err = validateProfile(&userAdd); err != nil {
+		return fmt.Errorf("AddUser: %w", err)
+	}
+	conn, err := connectDB(ctx)
 	if err != nil {
 		erRet := fmt.Errorf("AddUser. Unable to connect to database: %v\n", err)
 		return erRet
@@ -173,8 +301,11 @@ func (u userService) AddUser(ctx context.Context, userAdd app.User) error {
 	//All users add with role == 'user'
 	//Next Administrator may change this role
 	//SuperAdmins insert trough database
-	_, err = tx.Exec(ctx, `insert into users(user_name, role, create_time) values($1, $2)`,
-		userAdd.Name, 3, time.Now())
+	err = insertUser(ctx, tx, userAdd, 3, orgOf(ctx))
+	if errors.Is(err, ErrAlreadyExists) {
+		errA = fmt.Errorf("AddUser: %w", err)
+		return errA
+	}
 	if err != nil {
 		errA = fmt.Errorf("AddUser insert into user: %v\n", err)
 		return errA
@@ -182,44 +313,245 @@ func (u userService) AddUser(ctx context.Context, userAdd app.User) error {
 	return nil
 }
 func (u userService) UpdateUser(ctx context.Context, user app.User) error {
-	var errU error
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s"+
-		" password=%s dbname=%s sslmode=disable",
-		app.GetEnv("DB_HOST", "localhost"), app.GetEnvAsInt("DB_PORT", 5432),
-		app.GetEnv("DB_USER", "postgres"), app.GetEnv("DB_PASSWORD", "pgpassword"),
-		app.GetEnv("DB_NAME", "generate"))
-	conn, err := pgx.Connect(ctx, psqlInfo)
+	conn, err := connectDB(ctx)
 	if err != nil {
-		errU = fmt.Errorf("UpdateUser. Unable to connect to database: %v\n", err)
-		return errU
+		return fmt.Errorf("UpdateUser. Unable to connect to database: %v\n", err)
 	}
 	defer conn.Close(ctx)
 
-	_, errU = conn.Exec(ctx, `update users set role = $2, create_time = $3 where user_name = $1`,
-		user.Name, user.RoleID, time.Now())
-	if errU != nil {
-		return fmt.Errorf("UpdateUser conn.Exec: %v\n", errU)
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		var errU error
+		if user.Name, errU = resolveName(ctx, tx, user.Name); errU != nil {
+			return errU
+		}
+		return updateUser(ctx, tx, user)
+	})
+	if isGuardError(err) {
+		return fmt.Errorf("UpdateUser: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("UpdateUser conn.Exec: %v\n", err)
 	}
 	return nil
 }
 func (u userService) DeleteUser(ctx context.Context, user string) error {
-	var errD error
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s"+
-		" password=%s dbname=%s sslmode=disable",
-		app.GetEnv("DB_HOST", "localhost"), app.GetEnvAsInt("DB_PORT", 5432),
-		app.GetEnv("DB_USER", "postgres"), app.GetEnv("DB_PASSWORD", "pgpassword"),
-		app.GetEnv("DB_NAME", "generate"))
-	conn, err := pgx.Connect(ctx, psqlInfo)
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("DeleteUser. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		return deleteUser(ctx, tx, user)
+	})
+	if isGuardError(err) {
+		return fmt.Errorf("DeleteUser: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("DeleteUser conn.Exec: %v\n", err)
+	}
+	return nil
+}
+
+// updateUser changes role and profile of a user in the transaction of UpdateUser or BatchUsers.
+func updateUser(ctx context.Context, tx pgx.Tx, user app.User) error {
+	err := validateProfile(&user)
+	if err != nil {
+		return err
+	}
+	if user.FallbackRoleID, err = fallbackRole(user, time.Now()); err != nil {
+		return err
+	}
+
+	if err = checkStatusUnchanged(ctx, tx, user); err != nil {
+		return err
+	}
+	admins, err := protectAccount(ctx, tx, user.Name)
+	if err != nil {
+		return err
+	}
+	role := app.Role{ID: user.RoleID}
+	if err = resolveRole(ctx, tx, &role); err != nil {
+		return err
+	}
+	if err = checkGrantable(role); err != nil {
+		return err
+	}
+	if user.FallbackRoleID != 0 {
+		fallback := app.Role{ID: user.FallbackRoleID}
+		if err = resolveRole(ctx, tx, &fallback); err != nil {
+			return err
+		}
+		if err = checkGrantable(fallback); err != nil {
+			return err
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `update users set role = $2, create_time = $3, email = nullif($4, ''),
+				display_name = nullif($5, ''), locale = nullif($6, ''), timezone = nullif($7, ''),
+				role_expires_at = $8, fallback_role = nullif($9, 0)
+			where user_name = $1 and deleted_at is null and org_visible(org_id)`,
+		user.Name, user.RoleID, time.Now(), user.Email, user.DisplayName, user.Locale, user.Timezone,
+		user.RoleExpiresAt, user.FallbackRoleID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("email %s: %w", user.Email, ErrAlreadyExists)
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user %s: %w", user.Name, ErrNotFound)
+	}
+	return checkAdministrators(ctx, tx, user.Name, admins)
+}
+
+// checkStatusUnchanged rejects updates that change the status of a user. The status endpoints
+// change it, as they record the reason, who changed it, the end of a lock and the audit event.
+// The current status may be sent along, so users read with GET can be written back.
+func checkStatusUnchanged(ctx context.Context, tx pgx.Tx, user app.User) error {
+	if user.Status == "" {
+		return nil
+	}
+	var status string
+	err := tx.QueryRow(ctx, `select status from users where user_name = $1 and deleted_at is null and org_visible(org_id)`,
+		user.Name).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("user %s: %w", user.Name, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if status != user.Status {
+		var invalid ValidationError
+		invalid.Add("status", "is changed with POST /user/{username}/enable, disable or lock")
+		return &invalid
+	}
+	return nil
+}
+
+// deleteUser marks a user as deleted in the transaction of DeleteUser or BatchUsers.
+func deleteUser(ctx context.Context, tx pgx.Tx, user string) error {
+	admins, err := protectAccount(ctx, tx, user)
+	if err != nil {
+		return err
+	}
+	//Users are only marked as deleted, PurgeDeletedUsers removes them after the retention period
+	tag, err := tx.Exec(ctx, `update users set deleted_at = now()
+			where user_name = $1 and deleted_at is null and org_visible(org_id)`, user)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user %s: %w", user, ErrNotFound)
+	}
+	return checkAdministrators(ctx, tx, user, admins)
+}
+
+func (u userService) RecordLogin(ctx context.Context, userName string) (app.User, error) {
+	conn, err := connectDB(ctx)
 	if err != nil {
-		errD = fmt.Errorf("DeleteUser. Unable to connect to database: %v\n", err)
-		return errD
+		return app.User{}, fmt.Errorf("RecordLogin. Unable to connect to database: %v\n", err)
 	}
 	defer conn.Close(ctx)
 
-	_, errD = conn.Exec(ctx, `delete from users where user_name = $1`, user)
-	if errD != nil {
-		return fmt.Errorf("DeleteUser conn.Exec: %v\n", errD)
+	tag, err := conn.Exec(ctx, `update users set last_login_at = now()
+			where user_name = $1 and deleted_at is null and org_visible(org_id)`, userName)
+	if err != nil {
+		return app.User{}, fmt.Errorf("RecordLogin conn.Exec: %v\n", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return app.User{}, fmt.Errorf("RecordLogin: user %s: %w", userName, ErrNotFound)
 	}
+	return u.GetUser(ctx, userName, "")
+}
 
+// ----------------------------------------------------------------------------------------------------------------------
+func validateProfile(user *app.User) error {
+	user.Email = strings.TrimSpace(user.Email)
+	user.DisplayName = strings.TrimSpace(user.DisplayName)
+	if user.Email != "" {
+		addr, err := mail.ParseAddress(user.Email)
+		if err != nil || addr.Address != user.Email {
+			return fmt.Errorf("email %q is not valid: %w", user.Email, ErrInvalidArgument)
+		}
+	}
+	switch user.Status {
+	case "", app.UserStatusActive, app.UserStatusDisabled, app.UserStatusLocked:
+	default:
+		return fmt.Errorf("status %q is not one of active, disabled, locked: %w", user.Status, ErrInvalidArgument)
+	}
 	return nil
 }
+
+// fallbackRole returns the role a grant with role_expires_at falls back to, fallback_role_id or by
+// default the 'user' role. Grants without expiry have no fallback role, 0.
+func fallbackRole(user app.User, now time.Time) (int, error) {
+	switch {
+	case user.RoleExpiresAt == nil:
+		return 0, nil
+	case !user.RoleExpiresAt.After(now):
+		return 0, fmt.Errorf("role_expires_at is in the past: %w", ErrInvalidArgument)
+	case user.FallbackRoleID == 0:
+		return 3, nil
+	}
+	return user.FallbackRoleID, nil
+}
+
+func insertUser(ctx context.Context, tx pgx.Tx, user app.User, roleID, orgID int) error {
+	//The old names of renamed users stay reserved while they resolve to them
+	var reserved bool
+	err := tx.QueryRow(ctx, `select exists(select 1 from user_alias where lower(alias) = lower($1) and expires_at > now())`,
+		user.Name).Scan(&reserved)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return fmt.Errorf("user %s is the former name of a renamed user: %w", user.Name, ErrAlreadyExists)
+	}
+	_, err = tx.Exec(ctx, `insert into users(user_name, role, create_time, email, display_name, status, locale, timezone, org_id)
+				values($1, $2, $3, nullif($4, ''), nullif($5, ''), $6, nullif($7, ''), nullif($8, ''), $9)`,
+		user.Name, roleID, time.Now(), user.Email, user.DisplayName, app.UserStatusActive, user.Locale, user.Timezone, orgID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("user %s or email %s: %w", user.Name, user.Email, ErrAlreadyExists)
+	}
+	return err
+}
+
+// orgOf returns the organization that rows created with ctx belong to.
+// Requests across all organizations create them in the default organization.
+func orgOf(ctx context.Context) int {
+	id, all := app.TenantFrom(ctx)
+	if all {
+		return app.GetEnvAsInt("DEFAULT_TENANT_ID", 1)
+	}
+	return id
+}
+
+// queryRower is implemented by both *pgx.Conn and pgx.Tx.
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// resolveRole fills in the id and the name of a role given by either of them.
+// A role of the organization takes precedence over a global role with the same name.
+func resolveRole(ctx context.Context, db queryRower, role *app.Role) error {
+	err := db.QueryRow(ctx, `select id, role_name from user_role
+				where (org_id is null or org_visible(org_id))
+					and (($1 <> 0 and id = $1) or ($1 = 0 and lower(role_name) = lower($2)))
+				order by org_id nulls last limit 1`,
+		role.ID, role.Role).Scan(&role.ID, &role.Role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("unknown role %d %q: %w", role.ID, role.Role, ErrInvalidArgument)
+	}
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
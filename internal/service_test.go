@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"errors"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+	"time"
+)
+
+func TestValidateProfile(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		user app.User
+		want app.User
+		err  error
+	}{
+		{"empty", app.User{}, app.User{}, nil},
+		{"email", app.User{Email: "alice@example.com"}, app.User{Email: "alice@example.com"}, nil},
+		{"trimmed", app.User{Email: " alice@example.com\t", DisplayName: "  Alice  "},
+			app.User{Email: "alice@example.com", DisplayName: "Alice"}, nil},
+		{"email with a name", app.User{Email: "Alice <alice@example.com>"}, app.User{}, ErrInvalidArgument},
+		{"email without a domain", app.User{Email: "alice"}, app.User{}, ErrInvalidArgument},
+		{"two emails", app.User{Email: "alice@example.com, bob@example.com"}, app.User{}, ErrInvalidArgument},
+		{"active", app.User{Status: app.UserStatusActive}, app.User{Status: app.UserStatusActive}, nil},
+		{"disabled", app.User{Status: app.UserStatusDisabled}, app.User{Status: app.UserStatusDisabled}, nil},
+		{"locked", app.User{Status: app.UserStatusLocked}, app.User{Status: app.UserStatusLocked}, nil},
+		{"unknown status", app.User{Status: "deleted"}, app.User{}, ErrInvalidArgument},
+		{"status is case sensitive", app.User{Status: "Active"}, app.User{}, ErrInvalidArgument},
+	} {
+		user := tc.user
+		err := validateProfile(&user)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if err == nil && (user.Email != tc.want.Email || user.DisplayName != tc.want.DisplayName || user.Status != tc.want.Status) {
+			t.Errorf("%s: %+v, want %+v", tc.name, user, tc.want)
+		}
+	}
+}
+
+func TestFallbackRole(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		expires := now.Add(d)
+		return &expires
+	}
+	for _, tc := range []struct {
+		name     string
+		user     app.User
+		fallback int
+		err      error
+	}{
+		{"no expiry", app.User{RoleID: 1}, 0, nil},
+		{"no expiry drops the fallback", app.User{RoleID: 1, FallbackRoleID: 2}, 0, nil},
+		{"user role by default", app.User{RoleID: 1, RoleExpiresAt: at(time.Hour)}, 3, nil},
+		{"chosen fallback", app.User{RoleID: 1, RoleExpiresAt: at(7 * 24 * time.Hour), FallbackRoleID: 2}, 2, nil},
+		{"expires now", app.User{RoleID: 1, RoleExpiresAt: at(0)}, 0, ErrInvalidArgument},
+		{"expired", app.User{RoleID: 1, RoleExpiresAt: at(-time.Minute), FallbackRoleID: 2}, 0, ErrInvalidArgument},
+	} {
+		fallback, err := fallbackRole(tc.user, now)
+		if fallback != tc.fallback || !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: %d, %v, want %d, %v", tc.name, fallback, err, tc.fallback, tc.err)
+		}
+	}
+}
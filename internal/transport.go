@@ -10,17 +10,27 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"net/http"
+	"strconv"
 	"strings"
 	"testgenerate_backend_user/internal/app"
+	"time"
 )
 
 var (
-	ErrBadRouting           = errors.New("inconsistent mapping between route and handler (programmer error)")
-	ErrNotFound             = errors.New("not found")
-	ErrAlreadyExists        = errors.New("this row is already exists")
-	ErrInconsistentIDs      = errors.New("inconsistent IDs")
-	ErrForbidden            = errors.New("role is not administrator")
-	ErrPreconditionRequired = errors.New("header get authorization")
+	ErrBadRouting            = errors.New("inconsistent mapping between route and handler (programmer error)")
+	ErrNotFound              = errors.New("not found")
+	ErrAlreadyExists         = errors.New("this row is already exists")
+	ErrInconsistentIDs       = errors.New("inconsistent IDs")
+	ErrForbidden             = errors.New("role is not administrator")
+	ErrPreconditionRequired  = errors.New("header get authorization")
+	ErrInvalidArgument       = errors.New("invalid argument")
+	ErrInvitationInvalid     = errors.New("invitation is expired, revoked or already used")
+	ErrAccountDisabled       = errors.New("account is disabled or locked")
+	ErrLastAdministrator     = errors.New("the last administrators cannot be removed")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was used for a different request")
+	ErrValidation            = errors.New("validation failed")
+	ErrRequestTooLarge       = errors.New("request body is too large")
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is still in progress")
 )
 
 func accessControl(h http.Handler) http.Handler {
@@ -30,8 +40,8 @@ func accessControl(h http.Handler) http.Handler {
 		} else {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 		}
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Origin, Accept, Content-Type, Content-Length, Accept-Encoding")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Origin, Accept, Content-Type, Content-Length, Accept-Encoding, X-Tenant-ID, Idempotency-Key")
 
 		if r.Method == "OPTIONS" {
 			return
@@ -41,143 +51,1066 @@ func accessControl(h http.Handler) http.Handler {
 	})
 }
 
-func MakeHTTPHandler(s Service, logger UnitLogHandler) http.Handler {
+// unversionedDeprecatedAt is when /v1 replaced the unversioned routes.
+var unversionedDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// unversionedSunset is when the unversioned routes go away, UNVERSIONED_SUNSET as YYYY-MM-DD.
+func unversionedSunset() time.Time {
+	if sunset, err := time.Parse("2006-01-02", app.GetEnv("UNVERSIONED_SUNSET", "")); err == nil {
+		return sunset
+	}
+	return time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+}
+
+// deprecated marks the responses of routes that are replaced by the same route under successor
+// with the Deprecation (RFC 9745) and Sunset (RFC 8594) headers and a link to the successor.
+func deprecated(successor string, deprecatedAt, sunset time.Time) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(deprecatedAt.Unix(), 10))
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			w.Header().Set("Link", "<"+successor+r.URL.EscapedPath()+`>; rel="successor-version"`)
+			w.Header().Set("Access-Control-Expose-Headers", "Deprecation, Sunset, Link")
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func MakeHTTPHandler(s Service, logger UnitLogHandler, idempotencyKeys IdempotencyStore) http.Handler {
 	r := mux.NewRouter()
+	r.Use(idempotency(idempotencyKeys, logger, authenticateRequest))
+	if app.GetEnvAsBool("OPENAPI_VALIDATE", false) {
+		r.Use(openAPIValidation(mustLoadOpenAPISpec(), logger))
+	}
 	e := MakeServerEndpoints(s)
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorHandler(logger),
 		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(authenticate),
 	}
 
+	makeRoutes(r.PathPrefix("/v1").Subrouter(), e, encodeResponse, options)
+	//Unversioned routes are the first version, kept for existing clients until unversionedSunset
+	unversioned := r.NewRoute().Subrouter()
+	unversioned.Use(deprecated("/v1", unversionedDeprecatedAt, unversionedSunset()))
+	makeRoutes(unversioned, e, encodeResponse, options)
+
+	r.Methods("GET").Path("/metrics").Handler(promhttp.Handler())
+
+	r.Methods("OPTIONS", "GET").Path("/openapi.json").Handler(accessControl(http.HandlerFunc(serveOpenAPI)))
+
+	r.Methods("OPTIONS", "POST").Path("/graphql").Handler(accessControl(makeGraphQLHandler(e, logger)))
+
+	return r
+}
+
+// makeRoutes registers the routes of an API version. Versions share the endpoints, so a new version
+// differs only in the paths it registers and in encode, which shapes the envelopes of its responses.
+func makeRoutes(r *mux.Router, e Endpoints, encode httptransport.EncodeResponseFunc,
+	options []httptransport.ServerOption) {
 	r.Methods("OPTIONS", "GET").Path("/roles").Handler(accessControl(httptransport.NewServer(
 		e.getRolesEndpoint,
 		decodeRolesRequest,
-		encodeResponse,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "GET").Path("/roles/{id}/permissions").Handler(accessControl(httptransport.NewServer(
+		e.GetRolePermissionsEndpoint,
+		decodeRolePermissionsRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "PUT").Path("/roles/{id}").Handler(accessControl(httptransport.NewServer(
+		e.PutRoleEndpoint,
+		decodePutRoleRequest,
+		encode,
 		options...,
 	)))
 
 	r.Methods("OPTIONS", "GET").Path("/user").Handler(accessControl(httptransport.NewServer(
 		e.GetUserEndpoint,
 		decodeUserRequest,
-		encodeResponse,
+		encode,
 		options...,
 	)))
 
 	r.Methods("OPTIONS", "GET").Path("/usersrole").Handler(accessControl(httptransport.NewServer(
 		e.GetUsersRoleEndpoint,
 		decodeUsersRoleRequest,
-		encodeResponse,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/users/import").Handler(accessControl(httptransport.NewServer(
+		e.ImportUsersEndpoint,
+		decodeImportUsersRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "GET").Path("/users/export").Handler(accessControl(httptransport.NewServer(
+		e.ExportUsersEndpoint,
+		decodeExportUsersRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/users/batch").Handler(accessControl(httptransport.NewServer(
+		e.BatchUsersEndpoint,
+		decodeBatchUsersRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/usersrole/plan").Handler(accessControl(httptransport.NewServer(
+		e.PlanRolesEndpoint,
+		makeDecodeRolePlanRequest(false, PermUsersRead, PermRolesRead),
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/usersrole/apply").Handler(accessControl(httptransport.NewServer(
+		e.ApplyRolesEndpoint,
+		makeDecodeRolePlanRequest(true, PermUsersWrite, PermRolesWrite),
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "GET").Path("/usersrole/expiring").Handler(accessControl(httptransport.NewServer(
+		e.GetRoleExpirationsEndpoint,
+		decodeRoleExpirationsRequest,
+		encode,
 		options...,
 	)))
 
 	r.Methods("OPTIONS", "POST").Path("/user").Handler(accessControl(httptransport.NewServer(
 		e.PostUserEndpoint,
 		decodePostUserRequest,
-		encodeResponse,
+		encode,
 		options...,
 	)))
 
 	r.Methods("OPTIONS", "PUT").Path("/user").Handler(accessControl(httptransport.NewServer(
 		e.PutUserEndpoint,
 		decodePutRequest,
-		encodeResponse,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "PATCH").Path("/user/{user}").Handler(accessControl(httptransport.NewServer(
+		e.PatchUserEndpoint,
+		decodePatchUserRequest,
+		encode,
 		options...,
 	)))
 
 	r.Methods("OPTIONS", "DELETE").Path("/user/{user}").Handler(accessControl(httptransport.NewServer(
 		e.DeleteUserEndpoint,
 		decodeDeleteRequest,
-		encodeResponse,
+		encode,
 		options...,
 	)))
 
-	r.Methods("GET").Path("/metrics").Handler(promhttp.Handler())
+	r.Methods("OPTIONS", "POST").Path("/user/login").Handler(accessControl(httptransport.NewServer(
+		e.PostLoginEndpoint,
+		decodeLoginRequest,
+		encode,
+		options...,
+	)))
 
-	return r
+	for action, status := range map[string]string{
+		"disable": app.UserStatusDisabled,
+		"enable":  app.UserStatusActive,
+		"lock":    app.UserStatusLocked,
+		"unlock":  app.UserStatusActive,
+	} {
+		r.Methods("OPTIONS", "POST").Path("/user/{user}/" + action).Handler(accessControl(httptransport.NewServer(
+			e.PostUserStatusEndpoint,
+			makeDecodeUserStatusRequest(status),
+			encode,
+			options...,
+		)))
+	}
+
+	r.Methods("OPTIONS", "POST").Path("/user/{user}/restore").Handler(accessControl(httptransport.NewServer(
+		e.PostRestoreEndpoint,
+		decodeRestoreRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/user/{user}/rename").Handler(accessControl(httptransport.NewServer(
+		e.PostRenameEndpoint,
+		decodeRenameRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/user/{user}/roles").Handler(accessControl(httptransport.NewServer(
+		e.PostUserRoleEndpoint,
+		decodePostUserRoleRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "DELETE").Path("/user/{user}/roles/{role}").Handler(accessControl(httptransport.NewServer(
+		e.DeleteUserRoleEndpoint,
+		decodeDeleteUserRoleRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "GET").Path("/groups").Handler(accessControl(httptransport.NewServer(
+		e.GetGroupsEndpoint,
+		decodeGroupsRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/groups").Handler(accessControl(httptransport.NewServer(
+		e.PostGroupEndpoint,
+		decodePostGroupRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "PUT").Path("/groups/{id}").Handler(accessControl(httptransport.NewServer(
+		e.PutGroupEndpoint,
+		decodePutGroupRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "DELETE").Path("/groups/{id}").Handler(accessControl(httptransport.NewServer(
+		e.DeleteGroupEndpoint,
+		decodeDeleteGroupRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/groups/{id}/members").Handler(accessControl(httptransport.NewServer(
+		e.PostGroupMemberEndpoint,
+		decodePostGroupMemberRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "DELETE").Path("/groups/{id}/members/{user}").Handler(accessControl(httptransport.NewServer(
+		e.DeleteGroupMemberEndpoint,
+		decodeDeleteGroupMemberRequest,
+		encode,
+		options...,
+	)))
+
+	//Managers are added and removed like members
+	r.Methods("OPTIONS", "POST").Path("/groups/{id}/managers").Handler(accessControl(httptransport.NewServer(
+		e.PostGroupManagerEndpoint,
+		decodePostGroupMemberRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "DELETE").Path("/groups/{id}/managers/{user}").Handler(accessControl(httptransport.NewServer(
+		e.DeleteGroupManagerEndpoint,
+		decodeDeleteGroupMemberRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/groups/{id}/roles").Handler(accessControl(httptransport.NewServer(
+		e.PostGroupRoleEndpoint,
+		decodePostGroupRoleRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "DELETE").Path("/groups/{id}/roles/{role}").Handler(accessControl(httptransport.NewServer(
+		e.DeleteGroupRoleEndpoint,
+		decodeDeleteGroupRoleRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/invitations").Handler(accessControl(httptransport.NewServer(
+		e.PostInvitationEndpoint,
+		decodePostInvitationRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "GET").Path("/invitations").Handler(accessControl(httptransport.NewServer(
+		e.GetInvitationsEndpoint,
+		decodeInvitationsRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "DELETE").Path("/invitations/{id}").Handler(accessControl(httptransport.NewServer(
+		e.DeleteInvitationEndpoint,
+		decodeDeleteInvitationRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "GET").Path("/organizations").Handler(accessControl(httptransport.NewServer(
+		e.GetOrganizationsEndpoint,
+		decodeOrganizationsRequest,
+		encode,
+		options...,
+	)))
+
+	r.Methods("OPTIONS", "POST").Path("/organizations").Handler(accessControl(httptransport.NewServer(
+		e.PostOrganizationEndpoint,
+		decodePostOrganizationRequest,
+		encode,
+		options...,
+	)))
 }
 
 // ----------------------------------------------------------------------------------------------------------------------
-func decodeRolesRequest(_ context.Context, r *http.Request) (request interface{}, err error) {
-	_, role, errToken := getPermissionParams(r, err)
+func decodeRolesRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
 	if errToken != nil {
 		return nil, errToken
 	}
-	if strings.ToLower(role) != "administrator" {
+	if !caller.Can(PermRolesRead) {
 		return nil, ErrForbidden
 	}
 	return getRolesRequest{}, nil
 }
 
-func decodeUserRequest(_ context.Context, r *http.Request) (request interface{}, err error) {
-	user, role, errToken := getPermissionParams(r, err)
+func decodeRolePermissionsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
 	if errToken != nil {
 		return nil, errToken
 	}
-	if strings.ToLower(role) != "administrator" {
+	if !caller.Can(PermRolesRead) {
 		return nil, ErrForbidden
 	}
-	return getUserRequest{user, role}, nil
+
+	roleID, e := strconv.Atoi(mux.Vars(r)["id"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	return getRolePermissionsRequest{roleID}, nil
 }
 
-func decodeUsersRoleRequest(_ context.Context, r *http.Request) (request interface{}, err error) {
-	_, role, errToken := getPermissionParams(r, err)
+func decodePutRoleRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
 	if errToken != nil {
 		return nil, errToken
 	}
-	if strings.ToLower(role) != "administrator" {
+	if !caller.Can(PermRolesWrite) {
 		return nil, ErrForbidden
 	}
-	return getUsersRoleRequest{}, nil
+
+	roleID, e := strconv.Atoi(mux.Vars(r)["id"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	var role app.Role
+	if e = decodeJSON(r, &role); e != nil {
+		return nil, e
+	}
+	if role.ID != 0 && role.ID != roleID {
+		return nil, ErrInconsistentIDs
+	}
+	role.ID = roleID
+	if e = authorizeRole(ctx, caller, role); e != nil {
+		return nil, e
+	}
+	return putRoleRequest{role, caller.User}, nil
+}
+
+func decodeUserRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermUsersRead) {
+		return nil, ErrForbidden
+	}
+	return getUserRequest{caller.User, caller.Role()}, nil
+}
+
+func decodeUsersRoleRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	scope, e := scopeOf(caller, PermUsersRead)
+	if e != nil {
+		return nil, e
+	}
+
+	var filter app.UserFilter
+	if v := r.URL.Query().Get("include_deleted"); v != "" {
+		includeDeleted, e := strconv.ParseBool(v)
+		if e != nil {
+			return nil, ErrInvalidArgument
+		}
+		filter.IncludeDeleted = includeDeleted
+	}
+	return getUsersRoleRequest{scope.filter(filter), streamFormat(r)}, nil
+}
+
+// decodeImportUsersRequest reads a CSV or NDJSON file chosen by Content-Type.
+// Options come from ?dry_run=true, ?mode=upsert|insert and ?chunk_size=N.
+// Files larger than IMPORT_MAX_BYTES are answered with 413.
+func decodeImportUsersRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermUsersWrite) {
+		return nil, ErrForbidden
+	}
+
+	format, ok := formatFromMediaType(r.Header.Get("Content-Type"))
+	if !ok {
+		return nil, fmt.Errorf("Content-Type %q is not text/csv or application/x-ndjson: %w",
+			r.Header.Get("Content-Type"), ErrInvalidArgument)
+	}
+	var opts app.ImportOptions
+	query := r.URL.Query()
+	if v := query.Get("dry_run"); v != "" {
+		if opts.DryRun, err = strconv.ParseBool(v); err != nil {
+			return nil, ErrInvalidArgument
+		}
+	}
+	switch query.Get("mode") {
+	case "", "insert":
+	case "upsert":
+		opts.Upsert = true
+	default:
+		return nil, ErrInvalidArgument
+	}
+	if v := query.Get("chunk_size"); v != "" {
+		if opts.ChunkSize, err = strconv.Atoi(v); err != nil || opts.ChunkSize < 0 {
+			return nil, ErrInvalidArgument
+		}
+	}
+
+	records, err := ReadUserRecords(http.MaxBytesReader(nil, r.Body, maxImportBytes()), format)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, decodeError(tooLarge)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = authorizeImport(ctx, caller, records, opts.Upsert); err != nil {
+		return nil, err
+	}
+	return importUsersRequest{records, opts, caller.User}, nil
+}
+
+// decodeExportUsersRequest picks the file format from Accept, NDJSON by default.
+func decodeExportUsersRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermUsersRead) {
+		return nil, ErrForbidden
+	}
+
+	format := FormatNDJSON
+	if f, ok := formatFromMediaType(r.Header.Get("Accept")); ok {
+		format = f
+	}
+	return exportUsersRequest{format}, nil
+}
+
+// decodeBatchUsersRequest authorizes every operation like its single request. Adding users
+// needs users.write, team leads update and delete the members of their teams.
+// ?force=true confirms changes to the caller's own account for the whole batch.
+func decodeBatchUsersRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	scope, e := scopeOf(caller, PermUsersWrite)
+	if e != nil {
+		return nil, e
+	}
+
+	var batch app.UserBatch
+	if e = decodeJSON(r, &batch); e != nil {
+		return nil, e
+	}
+	//Reject oversized batches before authorizing each of their operations
+	if e = checkBatch(batch); e != nil {
+		return nil, e
+	}
+	force := forceRequested(r)
+	for i, op := range batch.Operations {
+		if op.Op != app.BatchAdd {
+			if op.User.Name, e = resolveUserName(ctx, normalizeUserName(op.User.Name)); e != nil {
+				return nil, e
+			}
+			batch.Operations[i].User.Name = op.User.Name
+		}
+		switch op.Op {
+		case app.BatchAdd:
+			if !scope.All {
+				e = ErrForbidden
+			}
+		case app.BatchUpdate:
+			if op.User.RoleID != caller.RoleID {
+				e = checkSelfChange(caller, op.User.Name, force)
+			}
+			if e == nil {
+				e = authorizeUserChange(ctx, caller, scope, op.User.Name,
+					app.Role{ID: op.User.RoleID}, app.Role{ID: op.User.FallbackRoleID})
+			}
+		case app.BatchDelete:
+			if e = checkSelfChange(caller, op.User.Name, force); e == nil {
+				e = authorizeUserChange(ctx, caller, scope, op.User.Name)
+			}
+		}
+		if e != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, e)
+		}
+	}
+	return batchUsersRequest{batch, caller.User}, nil
+}
+
+// makeDecodeRolePlanRequest decodes a desired role document. Reconciliation spans
+// the whole organization, so it is not delegated to team leads. A document to apply is
+// authorized like the single changes of its plan; ?force=true confirms changes to the
+// caller's own account.
+func makeDecodeRolePlanRequest(apply bool, permissions ...string) httptransport.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (request interface{}, err error) {
+		caller, errToken := getPermissionParams(ctx)
+		if errToken != nil {
+			return nil, errToken
+		}
+		for _, p := range permissions {
+			if !caller.Can(p) {
+				return nil, ErrForbidden
+			}
+		}
+
+		var desired app.DesiredRoles
+		if e := decodeJSON(r, &desired); e != nil {
+			return nil, e
+		}
+		if apply {
+			if e := authorizeRolePlan(ctx, caller, desired, forceRequested(r)); e != nil {
+				return nil, e
+			}
+		}
+		return rolePlanRequest{desired, caller.User}, nil
+	}
+}
+
+func decodeRoleExpirationsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	scope, e := scopeOf(caller, PermUsersRead)
+	if e != nil {
+		return nil, e
+	}
+
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		d, e := strconv.Atoi(v)
+		if e != nil || d < 0 {
+			return nil, ErrInvalidArgument
+		}
+		days = d
+	}
+	return getRoleExpirationsRequest{time.Duration(days) * 24 * time.Hour, scope.filter(app.UserFilter{})}, nil
 }
 
 func decodePostUserRequest(_ context.Context, r *http.Request) (request interface{}, err error) {
-	/*_, role, errToken := getPermissionParams(r, err)
+	/*caller, errToken := getPermissionParams(ctx)
 	if errToken != nil {
 		return nil, errToken
 	}
-	if strings.ToLower(role) != "administrator" {
+	if !caller.Can(PermUsersWrite) {
 		return nil, ErrForbidden
 	}*/
 
-	var addUser app.User
-	if e := json.NewDecoder(r.Body).Decode(&addUser); e != nil {
+	var addUser struct {
+		app.User
+		InviteCode string `json:"invite_code" validate:"max=4096"`
+	}
+	if e := decodeJSON(r, &addUser); e != nil {
 		return nil, e
 	}
-	return postUserRequest{addUser}, nil
+	return postUserRequest{addUser.User, addUser.InviteCode}, nil
 }
 
-func decodePutRequest(_ context.Context, r *http.Request) (request interface{}, err error) {
-	_, role, errToken := getPermissionParams(r, err)
+func decodePutRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
 	if errToken != nil {
 		return nil, errToken
 	}
-	if strings.ToLower(role) != "administrator" {
-		return nil, ErrForbidden
+	scope, e := scopeOf(caller, PermUsersWrite)
+	if e != nil {
+		return nil, e
 	}
 
 	var updateUser app.User
-	if e := json.NewDecoder(r.Body).Decode(&updateUser); e != nil {
+	if e = decodeJSON(r, &updateUser); e != nil {
+		return nil, e
+	}
+	if updateUser.Name, e = resolveUserName(ctx, normalizeUserName(updateUser.Name)); e != nil {
+		return nil, e
+	}
+	//Changing the own primary role is a demotion in all likelihood. Disabling or locking oneself
+	//is not possible here, status changes are left to the status endpoints, see checkStatusUnchanged
+	if updateUser.RoleID != caller.RoleID {
+		if e = checkSelfChange(caller, updateUser.Name, forceRequested(r)); e != nil {
+			return nil, e
+		}
+	}
+	e = authorizeUserChange(ctx, caller, scope, updateUser.Name,
+		app.Role{ID: updateUser.RoleID}, app.Role{ID: updateUser.FallbackRoleID})
+	if e != nil {
 		return nil, e
 	}
 
 	return putUserRequest{updateUser}, nil
 }
 
-func decodeDeleteRequest(_ context.Context, r *http.Request) (request interface{}, err error) {
-	_, role, errToken := getPermissionParams(r, err)
+// decodePatchUserRequest reads a JSON Merge Patch, or a JSON Patch sent as application/json-patch+json.
+// Roles the patch sets are authorized like the roles of PUT /user.
+func decodePatchUserRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
 	if errToken != nil {
 		return nil, errToken
 	}
-	if strings.ToLower(role) != "administrator" {
+	scope, e := scopeOf(caller, PermUsersWrite)
+	if e != nil {
+		return nil, e
+	}
+	user, e := userVar(ctx, r)
+	if e != nil {
+		return nil, e
+	}
+
+	var patch app.UserPatch
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0]))
+	switch mediaType {
+	case "application/merge-patch+json", "application/json":
+		if patch.MergePatch, e = readBody(r); e != nil {
+			return nil, e
+		}
+	case "application/json-patch+json":
+		if e = decodeJSON(r, &patch.JSONPatch); e != nil {
+			return nil, e
+		}
+	default:
+		return nil, fmt.Errorf("Content-Type %q is not application/merge-patch+json or application/json-patch+json: %w",
+			r.Header.Get("Content-Type"), ErrInvalidArgument)
+	}
+
+	values, e := patchValues(patch)
+	if e != nil {
+		return nil, e
+	}
+	//Values of the wrong type are rejected when the patch is applied
+	var role, fallback app.Role
+	_ = json.Unmarshal(values["role_id"], &role.ID)
+	_ = json.Unmarshal(values["fallback_role_id"], &fallback.ID)
+	//Changing the own primary role or status is a demotion in all likelihood
+	_, statusChanged := values["status"]
+	if statusChanged || (role.ID != 0 && role.ID != caller.RoleID) {
+		if e = checkSelfChange(caller, user, forceRequested(r)); e != nil {
+			return nil, e
+		}
+	}
+	if e = authorizeUserChange(ctx, caller, scope, user, role, fallback); e != nil {
+		return nil, e
+	}
+	return patchUserRequest{user, patch}, nil
+}
+
+func decodeDeleteRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	scope, e := scopeOf(caller, PermUsersWrite)
+	if e != nil {
+		return nil, e
+	}
+
+	user, e := userVar(ctx, r)
+	if e != nil {
+		return nil, e
+	}
+	if e = checkSelfChange(caller, user, forceRequested(r)); e != nil {
+		return nil, e
+	}
+	if e = authorizeUserChange(ctx, caller, scope, user); e != nil {
+		return nil, e
+	}
+	return deleteUserRequest{user}, nil
+}
+
+// userVar returns the user named in the URL. Former names of renamed users resolve to the user.
+func userVar(ctx context.Context, r *http.Request) (string, error) {
+	user, ok := mux.Vars(r)["user"]
+	if !ok {
+		return "", ErrBadRouting
+	}
+	return resolveUserName(ctx, normalizeUserName(user))
+}
+
+// forceRequested reports whether the request confirms a change to the caller's own account.
+func forceRequested(r *http.Request) bool {
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	return force
+}
+
+func decodeLoginRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	return postLoginRequest{caller.User}, nil
+}
+
+func makeDecodeUserStatusRequest(status string) httptransport.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (request interface{}, err error) {
+		caller, errToken := getPermissionParams(ctx)
+		if errToken != nil {
+			return nil, errToken
+		}
+		scope, e := scopeOf(caller, PermUsersWrite)
+		if e != nil {
+			return nil, e
+		}
+
+		user, e := userVar(ctx, r)
+		if e != nil {
+			return nil, e
+		}
+		if status != app.UserStatusActive {
+			if e = checkSelfChange(caller, user, forceRequested(r)); e != nil {
+				return nil, e
+			}
+		}
+		if e = authorizeUserChange(ctx, caller, scope, user); e != nil {
+			return nil, e
+		}
+		//Body with reason and until is optional
+		var change app.StatusChange
+		if e = decodeJSON(r, &change); e != nil && !errors.Is(e, errEmptyBody) {
+			return nil, e
+		}
+		change.Status = status
+		change.ChangedBy = caller.User
+		return postUserStatusRequest{user, change}, nil
+	}
+}
+
+func decodeRestoreRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermUsersWrite) {
+		return nil, ErrForbidden
+	}
+
+	user, e := userVar(ctx, r)
+	if e != nil {
+		return nil, e
+	}
+	return postRestoreRequest{user}, nil
+}
+
+// decodeRenameRequest reads the new name from {"user_name": "..."}.
+func decodeRenameRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	scope, e := scopeOf(caller, PermUsersWrite)
+	if e != nil {
+		return nil, e
+	}
+
+	user, e := userVar(ctx, r)
+	if e != nil {
+		return nil, e
+	}
+	var rename struct {
+		UserName string `json:"user_name" validate:"required"`
+	}
+	if e = decodeJSON(r, &rename); e != nil {
+		return nil, e
+	}
+	if e = authorizeUserChange(ctx, caller, scope, user); e != nil {
+		return nil, e
+	}
+	return postRenameRequest{user, rename.UserName, caller.User}, nil
+}
+
+func decodePostUserRoleRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	scope, e := scopeOf(caller, PermUsersWrite)
+	if e != nil {
+		return nil, e
+	}
+
+	user, e := userVar(ctx, r)
+	if e != nil {
+		return nil, e
+	}
+	var role app.Role
+	if e = decodeJSON(r, &role); e != nil {
+		return nil, e
+	}
+	if e = authorizeUserChange(ctx, caller, scope, user, role); e != nil {
+		return nil, e
+	}
+	return postUserRoleRequest{user, role, caller.User}, nil
+}
+
+func decodeDeleteUserRoleRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	scope, e := scopeOf(caller, PermUsersWrite)
+	if e != nil {
+		return nil, e
+	}
+
+	vars := mux.Vars(r)
+	user, e := userVar(ctx, r)
+	if e != nil {
+		return nil, e
+	}
+	roleID, e := strconv.Atoi(vars["role"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	if e = checkSelfChange(caller, user, forceRequested(r)); e != nil {
+		return nil, e
+	}
+	if e = authorizeUserChange(ctx, caller, scope, user); e != nil {
+		return nil, e
+	}
+	return deleteUserRoleRequest{user, roleID, caller.User}, nil
+}
+
+func decodeGroupsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermUsersRead) {
+		return nil, ErrForbidden
+	}
+	return getGroupsRequest{}, nil
+}
+
+func decodePostGroupRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermGroupsManage) {
+		return nil, ErrForbidden
+	}
+
+	var group app.Group
+	if e := decodeJSON(r, &group); e != nil {
+		return nil, e
+	}
+	return postGroupRequest{group, caller.User}, nil
+}
+
+func decodePutGroupRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermGroupsManage) {
+		return nil, ErrForbidden
+	}
+
+	groupID, e := strconv.Atoi(mux.Vars(r)["id"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	var group app.Group
+	if e = decodeJSON(r, &group); e != nil {
+		return nil, e
+	}
+	if group.ID != 0 && group.ID != groupID {
+		return nil, ErrInconsistentIDs
+	}
+	group.ID = groupID
+	return putGroupRequest{group, caller.User}, nil
+}
+
+func decodeDeleteGroupRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermGroupsManage) {
+		return nil, ErrForbidden
+	}
+
+	groupID, e := strconv.Atoi(mux.Vars(r)["id"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	return deleteGroupRequest{groupID, caller.User}, nil
+}
+
+func decodePostGroupMemberRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermGroupsManage) {
+		return nil, ErrForbidden
+	}
+
+	groupID, e := strconv.Atoi(mux.Vars(r)["id"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	var member struct {
+		Name string `json:"user_name" validate:"required"`
+	}
+	if e = decodeJSON(r, &member); e != nil {
+		return nil, e
+	}
+	return groupMemberRequest{groupID, member.Name, caller.User}, nil
+}
+
+func decodeDeleteGroupMemberRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermGroupsManage) {
+		return nil, ErrForbidden
+	}
+
+	vars := mux.Vars(r)
+	groupID, e := strconv.Atoi(vars["id"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	user, e := userVar(ctx, r)
+	if e != nil {
+		return nil, e
+	}
+	return groupMemberRequest{groupID, user, caller.User}, nil
+}
+
+func decodePostGroupRoleRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermGroupsManage) {
+		return nil, ErrForbidden
+	}
+
+	groupID, e := strconv.Atoi(mux.Vars(r)["id"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	var role app.Role
+	if e = decodeJSON(r, &role); e != nil {
+		return nil, e
+	}
+	if e = authorizeGrant(ctx, caller, role); e != nil {
+		return nil, e
+	}
+	return groupRoleRequest{groupID, role, caller.User}, nil
+}
+
+func decodeDeleteGroupRoleRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermGroupsManage) {
 		return nil, ErrForbidden
 	}
 
 	vars := mux.Vars(r)
-	user, ok := vars["user"]
+	groupID, e := strconv.Atoi(vars["id"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	roleID, e := strconv.Atoi(vars["role"])
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	return groupRoleRequest{groupID, app.Role{ID: roleID}, caller.User}, nil
+}
+
+func decodePostInvitationRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermInvitationsManage) {
+		return nil, ErrForbidden
+	}
+
+	var invitation app.Invitation
+	if e := decodeJSON(r, &invitation); e != nil {
+		return nil, e
+	}
+	if e := authorizeGrant(ctx, caller, app.Role{ID: invitation.RoleID, Role: invitation.Role}); e != nil {
+		return nil, e
+	}
+	return postInvitationRequest{invitation, caller.User}, nil
+}
+
+func decodeInvitationsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermInvitationsManage) {
+		return nil, ErrForbidden
+	}
+	return getInvitationsRequest{}, nil
+}
+
+func decodeDeleteInvitationRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.Can(PermInvitationsManage) {
+		return nil, ErrForbidden
+	}
+
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
 	if !ok {
 		return nil, ErrBadRouting
 	}
-	return deleteUserRequest{user}, nil
+	invitationID, e := strconv.Atoi(id)
+	if e != nil {
+		return nil, ErrInvalidArgument
+	}
+	return deleteInvitationRequest{invitationID}, nil
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -185,7 +1118,38 @@ type errorer interface {
 	error() error
 }
 
+// Organizations are managed by the superadmins of the platform only.
+func decodeOrganizationsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.HasRole(roleSuperadmin) {
+		return nil, ErrForbidden
+	}
+	return getOrganizationsRequest{}, nil
+}
+
+func decodePostOrganizationRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	caller, errToken := getPermissionParams(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if !caller.HasRole(roleSuperadmin) {
+		return nil, ErrForbidden
+	}
+
+	var org app.Organization
+	if e := decodeJSON(r, &org); e != nil {
+		return nil, e
+	}
+	return postOrganizationRequest{org, caller.User}, nil
+}
+
 func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if stream, ok := response.(streamUsersResponse); ok {
+		return encodeStream(ctx, w, stream)
+	}
 	if e, ok := response.(errorer); ok && e.error() != nil {
 		encodeError(ctx, e.error(), w)
 		return nil
@@ -194,24 +1158,107 @@ func encodeResponse(ctx context.Context, w http.ResponseWriter, response interfa
 	return json.NewEncoder(w).Encode(response)
 }
 
+// streamFlushRows is how many rows of a streamed listing are written between two flushes.
+const streamFlushRows = 100
+
+// encodeStream writes the rows of a listing while they are read. Errors before the first row
+// are answered like any other error; later ones end NDJSON with an {"error": ...} line
+// and cut CSV short, as the status is sent already.
+func encodeStream(ctx context.Context, w http.ResponseWriter, resp streamUsersResponse) error {
+	if resp.Format == FormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	rw, err := newUserRecordWriter(w, resp.Format)
+	if err != nil {
+		return err
+	}
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	rows := 0
+	err = resp.Stream(func(user app.User) error {
+		var errW error
+		if resp.Format == FormatNDJSON && !resp.Records {
+			errW = enc.Encode(user)
+		} else {
+			errW = rw.Write(userRecordOf(user))
+		}
+		if errW != nil {
+			return errW
+		}
+		rows++
+		if rows%streamFlushRows == 0 {
+			if errW = rw.Flush(); errW != nil {
+				return errW
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	switch {
+	case err != nil && rows == 0:
+		encodeError(ctx, err, w)
+		return nil
+	case err != nil && resp.Format == FormatNDJSON:
+		_ = enc.Encode(map[string]interface{}{"error": err.Error()})
+		return err
+	case err != nil:
+		return err
+	}
+	return rw.Flush()
+}
+
+// streamFormat negotiates the format of a listing from Accept. It returns "" for the JSON envelope.
+func streamFormat(r *http.Request) string {
+	for _, mediaType := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(mediaType, ";")[0]))
+		switch mediaType {
+		case "text/csv":
+			return FormatCSV
+		case "application/x-ndjson":
+			return FormatNDJSON
+		case "application/json", "*/*":
+			return ""
+		}
+	}
+	return ""
+}
+
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	if err == nil {
 		panic("encodeError with nil error")
 	}
+	body := map[string]interface{}{
+		"error": err.Error(),
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		body["fields"] = invalid.Fields
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(codeFrom(err))
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": err.Error(),
-	})
+	json.NewEncoder(w).Encode(body)
 }
 func codeFrom(err error) int {
 	switch {
 	case errors.Is(err, ErrNotFound):
 		return http.StatusNotFound
-	case errors.Is(err, ErrAlreadyExists), errors.Is(err, ErrInconsistentIDs):
+	case errors.Is(err, ErrAlreadyExists), errors.Is(err, ErrInconsistentIDs), errors.Is(err, ErrInvalidArgument):
 		return http.StatusBadRequest
-	case errors.Is(err, ErrForbidden):
+	case errors.Is(err, ErrInvitationInvalid):
+		return http.StatusGone
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrAccountDisabled):
 		return http.StatusForbidden
+	case errors.Is(err, ErrLastAdministrator), errors.Is(err, ErrIdempotencyInProgress):
+		return http.StatusConflict
+	case errors.Is(err, ErrIdempotencyKeyReused), errors.Is(err, ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrRequestTooLarge):
+		return http.StatusRequestEntityTooLarge
 	case errors.Is(err, ErrPreconditionRequired):
 		return http.StatusPreconditionRequired
 	default:
@@ -220,42 +1267,162 @@ func codeFrom(err error) int {
 }
 
 // ----------------------------------------------------------------------------------------------------------------------
-func getPermissionParams(r *http.Request, err error) (string, string, error) {
+// principal is the authenticated caller of a request.
+// Roles holds every role of the caller, the primary one first, Permissions
+// the permissions of all these roles including the inherited ones.
+// Tenant is the organization the caller works in, AllTenants is set for
+// superadmins that did not pick one with X-Tenant-ID. LeadsTeam is set for
+// managers of groups, see userScope. RoleID is the id of the primary role.
+type principal struct {
+	User        string
+	RoleID      int
+	Roles       []string
+	Permissions map[string]bool
+	Tenant      int
+	AllTenants  bool
+	LeadsTeam   bool
+}
+
+// roleSuperadmin is the global role of the platform operators, the only one that works across organizations.
+const roleSuperadmin = "superadmin"
+
+type principalKey struct{}
+
+type authentication struct {
+	caller principal
+	err    error
+}
+
+func (p principal) Can(permission string) bool {
+	return p.Permissions[permission]
+}
+
+func (p principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p principal) Role() string {
+	if len(p.Roles) == 0 {
+		return ""
+	}
+	return p.Roles[0]
+}
+
+// authenticate resolves the caller before the request is decoded and binds the context
+// to the caller's organization. Decoders get the caller with getPermissionParams, so a
+// missing or invalid token only fails requests that need one.
+func authenticate(ctx context.Context, r *http.Request) context.Context {
+	caller, err := authenticateRequest(ctx, r)
+	ctx = context.WithValue(ctx, principalKey{}, authentication{caller: caller, err: err})
+	switch {
+	case err == nil && caller.AllTenants:
+		return app.WithAllTenants(ctx)
+	case err == nil:
+		return app.WithTenant(ctx, caller.Tenant)
+	}
+	//Self-registration has no token, the organization is chosen by the header
+	if id, errID := strconv.Atoi(r.Header.Get("X-Tenant-ID")); errID == nil {
+		return app.WithTenant(ctx, id)
+	}
+	return ctx
+}
+
+func getPermissionParams(ctx context.Context) (principal, error) {
+	auth, ok := ctx.Value(principalKey{}).(authentication)
+	if !ok {
+		return principal{}, ErrPreconditionRequired
+	}
+	return auth.caller, auth.err
+}
+
+// authenticateRequest takes the organization of the caller from the caller's account, the "tenant"
+// claim of the token has to match it. Only superadmins may switch to another organization with
+// X-Tenant-ID; without it they work across all organizations. Roles and permissions come from
+// the database only, the "role" claim of the token is not trusted.
+func authenticateRequest(ctx context.Context, r *http.Request) (principal, error) {
+	return authenticateAccount(ctx, r, checkAccount)
+}
+
+// authenticateAccount authenticates the token of r against the accounts that check looks up.
+func authenticateAccount(ctx context.Context, r *http.Request,
+	check func(ctx context.Context, userName string) (account, error)) (principal, error) {
 	tb := strings.Split(r.Header.Get("Authorization"), " ")
 	if len(tb) != 2 {
-		return "", "", ErrPreconditionRequired
+		return principal{}, ErrPreconditionRequired
+	}
+	user, _, tenant, err := extractTokenMetadata(tb[1])
+	if err != nil {
+		return principal{}, ErrPreconditionRequired
 	}
-	user, role, err := extractTokenMetadata(tb[1])
+	ctx = app.WithAllTenants(ctx)
+	acc, err := check(ctx, user)
 	if err != nil {
-		return "", "", ErrPreconditionRequired
+		return principal{}, err
+	}
+	permissions, err := roleGraph.permissions(ctx, acc.Roles)
+	if err != nil {
+		return principal{}, err
+	}
+	caller := principal{User: acc.UserName, Permissions: permissions, LeadsTeam: acc.LeadsTeam, Tenant: acc.OrgID}
+	for _, r := range acc.Roles {
+		caller.Roles = append(caller.Roles, r.Role)
+	}
+	if len(acc.Roles) != 0 {
+		caller.RoleID = acc.Roles[0].ID
 	}
-	return user, role, nil
+	if tenant != 0 && tenant != acc.OrgID {
+		return principal{}, fmt.Errorf("token of %s is issued for organization %d: %w", user, tenant, ErrForbidden)
+	}
+
+	header := r.Header.Get("X-Tenant-ID")
+	if header == "" {
+		caller.AllTenants = caller.HasRole(roleSuperadmin)
+		return caller, nil
+	}
+	id, err := strconv.Atoi(header)
+	if err != nil {
+		return principal{}, fmt.Errorf("X-Tenant-ID %q: %w", header, ErrInvalidArgument)
+	}
+	if id != caller.Tenant && !caller.HasRole(roleSuperadmin) {
+		return principal{}, fmt.Errorf("organization %d: %w", id, ErrForbidden)
+	}
+	caller.Tenant = id
+	return caller, nil
 }
 
-func extractTokenMetadata(headerToken string) (string, string, error) {
-	var user, role string
+// extractTokenMetadata returns the user, the role and the optional "tenant" claim of a token.
+func extractTokenMetadata(headerToken string) (string, string, int, error) {
+	var (
+		user, role string
+		tenant     int
+	)
 	token, err := jwt.Parse(headerToken, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			fmt.Printf("extractTokenMetadata unexpected signing method: %v", token.Header["alg"])
-			return nil, errors.New("unexpected signing method")
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(app.GetEnv("SECRET_KEY", "secretkey")), nil
 	})
 	if err != nil {
-		return "", "", err
+		return "", "", 0, err
 	}
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if ok && token.Valid {
 		user, ok = claims["username"].(string)
 		if !ok {
-			fmt.Printf("extractTokenMetadata not username")
-			return "", "", errors.New("not username")
+			return "", "", 0, errors.New("not username")
 		}
 		role, ok = claims["role"].(string)
 		if !ok {
-			fmt.Printf("extractTokenMetadata not role")
-			return "", "", errors.New("not role")
+			return "", "", 0, errors.New("not role")
+		}
+		if id, ok := claims["tenant"].(float64); ok {
+			tenant = int(id)
 		}
 	}
-	return user, role, nil
+	return user, role, tenant, nil
 }
@@ -0,0 +1,839 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+	"time"
+)
+
+// errServerFailed wraps the answers of a server that failed, which clients retry.
+var errServerFailed = errors.New("server failed")
+
+// ErrNotSupported is returned by the methods of Service that run in the server only.
+var ErrNotSupported = errors.New("not supported by the client")
+
+// clientConfig is set by the ClientOptions of MakeClientEndpoints.
+type clientConfig struct {
+	token      string
+	tenant     int
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	httpClient *http.Client
+}
+
+type ClientOption func(*clientConfig)
+
+// ClientToken sends the token as the bearer token of calls that carry none in their context, see WithClientToken.
+func ClientToken(token string) ClientOption {
+	return func(c *clientConfig) { c.token = token }
+}
+
+// ClientTenant sends X-Tenant-ID, for superadmins working in one organization.
+func ClientTenant(id int) ClientOption {
+	return func(c *clientConfig) { c.tenant = id }
+}
+
+// ClientTimeout limits a call including its retries, 10 seconds by default.
+func ClientTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) { c.timeout = timeout }
+}
+
+// ClientRetries sets how often a call is repeated after failures of the network or the server,
+// 2 times by default. The first retry waits backoff, each further one twice as long as the one before.
+func ClientRetries(retries int, backoff time.Duration) ClientOption {
+	return func(c *clientConfig) { c.retries, c.backoff = retries, backoff }
+}
+
+// ClientHTTPClient sets the client that sends the requests, http.DefaultClient by default.
+func ClientHTTPClient(client *http.Client) ClientOption {
+	return func(c *clientConfig) { c.httpClient = client }
+}
+
+type clientTokenKey struct{}
+
+// WithClientToken sends the token with the calls of ctx instead of the one of ClientToken,
+// so a service can call on behalf of its own caller.
+func WithClientToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, clientTokenKey{}, token)
+}
+
+type clientIdempotencyKey struct{}
+
+// MakeClientEndpoints returns endpoints that call the /v1 routes of the server at baseURL, so the
+// methods of Endpoints run remotely. Audit events, which have no route, are queried from /graphql. Errors the server answers with are decoded back into the
+// errors of this package, e.g. errors.Is(err, ErrNotFound) holds for a 404. Calls that fail in
+// the network or with a server error are retried; POST, PUT and DELETE send an Idempotency-Key
+// that stays the same across the retries of a call, so they are not applied twice.
+func MakeClientEndpoints(baseURL string, options ...ClientOption) (Endpoints, error) {
+	if !strings.HasPrefix(baseURL, "http") {
+		baseURL = "http://" + baseURL
+	}
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/v1")
+	if err != nil {
+		return Endpoints{}, err
+	}
+	graph, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/graphql")
+	if err != nil {
+		return Endpoints{}, err
+	}
+	config := clientConfig{timeout: 10 * time.Second, retries: 2, backoff: 100 * time.Millisecond,
+		httpClient: http.DefaultClient}
+	for _, option := range options {
+		option(&config)
+	}
+
+	clientOptions := []httptransport.ClientOption{
+		httptransport.SetClient(config.httpClient),
+		httptransport.ClientBefore(config.setHeaders),
+	}
+	client := func(method string, enc httptransport.EncodeRequestFunc, dec httptransport.DecodeResponseFunc) endpoint.Endpoint {
+		e := httptransport.NewClient(method, base, enc, dec, clientOptions...).Endpoint()
+		switch method {
+		case http.MethodGet:
+			e = retry(config.retries, config.backoff)(e)
+		case http.MethodPost, http.MethodPut, http.MethodDelete:
+			e = idempotent(retry(config.retries, config.backoff)(e))
+		}
+		return timeout(config.timeout)(e)
+	}
+	// Queries change nothing, so they are retried without an idempotency key.
+	query := func(enc httptransport.EncodeRequestFunc, dec httptransport.DecodeResponseFunc) endpoint.Endpoint {
+		e := httptransport.NewClient(http.MethodPost, graph, enc, dec, clientOptions...).Endpoint()
+		return timeout(config.timeout)(retry(config.retries, config.backoff)(e))
+	}
+
+	return Endpoints{
+		getRolesEndpoint:           client("GET", encodeGetRolesClientRequest, decodeGetRolesClientResponse),
+		GetRolePermissionsEndpoint: client("GET", encodeGetRolePermissionsClientRequest, decodeGetRolePermissionsClientResponse),
+		PutRoleEndpoint:            client("PUT", encodePutRoleClientRequest, decodePutRoleClientResponse),
+		GetUserEndpoint:            client("GET", encodeGetUserClientRequest, decodeGetUserClientResponse),
+		GetUsersRoleEndpoint:       client("GET", encodeGetUsersRoleClientRequest, decodeGetUsersRoleClientResponse),
+		GetRoleExpirationsEndpoint: client("GET", encodeGetRoleExpirationsClientRequest, decodeGetRoleExpirationsClientResponse),
+		GetAuditEventsEndpoint:     query(encodeGetAuditEventsClientRequest, decodeGetAuditEventsClientResponse),
+		ImportUsersEndpoint:        client("POST", encodeImportUsersClientRequest, decodeImportUsersClientResponse),
+		ExportUsersEndpoint:        client("GET", encodeExportUsersClientRequest, decodeExportUsersClientResponse),
+		BatchUsersEndpoint:         client("POST", encodeBatchUsersClientRequest, decodeBatchUsersClientResponse),
+		PlanRolesEndpoint:          client("POST", makeEncodeRolePlanClientRequest("plan"), decodeRolePlanClientResponse),
+		ApplyRolesEndpoint:         client("POST", makeEncodeRolePlanClientRequest("apply"), decodeRolePlanClientResponse),
+		PostUserEndpoint:           client("POST", encodePostUserClientRequest, decodePostUserClientResponse),
+		PutUserEndpoint:            client("PUT", encodePutUserClientRequest, decodePutUserClientResponse),
+		PatchUserEndpoint:          client("PATCH", encodePatchUserClientRequest, decodePatchUserClientResponse),
+		DeleteUserEndpoint:         client("DELETE", encodeDeleteUserClientRequest, decodeDeleteUserClientResponse),
+		PostLoginEndpoint:          client("POST", encodePostLoginClientRequest, decodePostLoginClientResponse),
+		PostUserStatusEndpoint:     client("POST", encodePostUserStatusClientRequest, decodePostUserStatusClientResponse),
+		PostRestoreEndpoint:        client("POST", encodePostRestoreClientRequest, decodePostRestoreClientResponse),
+		PostRenameEndpoint:         client("POST", encodePostRenameClientRequest, decodePostRenameClientResponse),
+		PostUserRoleEndpoint:       client("POST", encodePostUserRoleClientRequest, decodePostUserRoleClientResponse),
+		DeleteUserRoleEndpoint:     client("DELETE", encodeDeleteUserRoleClientRequest, decodeDeleteUserRoleClientResponse),
+
+		GetGroupsEndpoint:          client("GET", encodeGetGroupsClientRequest, decodeGetGroupsClientResponse),
+		PostGroupEndpoint:          client("POST", encodePostGroupClientRequest, decodePostGroupClientResponse),
+		PutGroupEndpoint:           client("PUT", encodePutGroupClientRequest, decodeGroupChangeClientResponse),
+		DeleteGroupEndpoint:        client("DELETE", encodeDeleteGroupClientRequest, decodeGroupChangeClientResponse),
+		PostGroupMemberEndpoint:    client("POST", makeEncodePostGroupMemberClientRequest("members"), decodeGroupChangeClientResponse),
+		DeleteGroupMemberEndpoint:  client("DELETE", makeEncodeDeleteGroupMemberClientRequest("members"), decodeGroupChangeClientResponse),
+		PostGroupManagerEndpoint:   client("POST", makeEncodePostGroupMemberClientRequest("managers"), decodeGroupChangeClientResponse),
+		DeleteGroupManagerEndpoint: client("DELETE", makeEncodeDeleteGroupMemberClientRequest("managers"), decodeGroupChangeClientResponse),
+		PostGroupRoleEndpoint:      client("POST", encodePostGroupRoleClientRequest, decodeGroupChangeClientResponse),
+		DeleteGroupRoleEndpoint:    client("DELETE", encodeDeleteGroupRoleClientRequest, decodeGroupChangeClientResponse),
+
+		PostInvitationEndpoint:   client("POST", encodePostInvitationClientRequest, decodePostInvitationClientResponse),
+		GetInvitationsEndpoint:   client("GET", encodeGetInvitationsClientRequest, decodeGetInvitationsClientResponse),
+		DeleteInvitationEndpoint: client("DELETE", encodeDeleteInvitationClientRequest, decodeDeleteInvitationClientResponse),
+
+		GetOrganizationsEndpoint: client("GET", encodeGetOrganizationsClientRequest, decodeGetOrganizationsClientResponse),
+		PostOrganizationEndpoint: client("POST", encodePostOrganizationClientRequest, decodePostOrganizationClientResponse),
+	}, nil
+}
+
+// setHeaders sends the token, the organization and the idempotency key of the call.
+func (c clientConfig) setHeaders(ctx context.Context, r *http.Request) context.Context {
+	token := c.token
+	if t, ok := ctx.Value(clientTokenKey{}).(string); ok {
+		token = t
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if c.tenant != 0 {
+		r.Header.Set("X-Tenant-ID", strconv.Itoa(c.tenant))
+	}
+	if key, ok := ctx.Value(clientIdempotencyKey{}).(string); ok {
+		r.Header.Set("Idempotency-Key", key)
+	}
+	return ctx
+}
+
+// idempotent gives a call the idempotency key its retries share.
+func idempotent(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		if _, ok := ctx.Value(clientIdempotencyKey{}).(string); !ok {
+			key := make([]byte, 16)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			ctx = context.WithValue(ctx, clientIdempotencyKey{}, hex.EncodeToString(key))
+		}
+		return next(ctx, request)
+	}
+}
+
+// retry repeats calls that failed in the network or with a server error, and calls that
+// found their idempotency key in use, waiting backoff and twice as long before each further try.
+// Requests the server rejected are not repeated.
+func retry(retries int, backoff time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			for attempt := 0; ; attempt++ {
+				response, err = next(ctx, request)
+				if err == nil || attempt >= retries || !retryable(err) {
+					return response, err
+				}
+				select {
+				case <-ctx.Done():
+					return nil, err
+				case <-time.After(backoff << uint(attempt)):
+				}
+			}
+		}
+	}
+}
+
+func retryable(err error) bool {
+	var netErr *url.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, errServerFailed), errors.Is(err, ErrIdempotencyInProgress):
+		return true
+	}
+	return errors.As(err, &netErr)
+}
+
+func timeout(d time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, request)
+		}
+	}
+}
+
+// clientErrors are the errors encodeError answers with, the general one of a status first.
+var clientErrors = []error{
+	ErrNotFound, ErrInvalidArgument, ErrAlreadyExists, ErrInconsistentIDs, ErrInvitationInvalid,
+	ErrForbidden, ErrAccountDisabled, ErrLastAdministrator, ErrIdempotencyInProgress,
+	ErrValidation, ErrIdempotencyKeyReused, ErrRequestTooLarge, ErrPreconditionRequired,
+}
+
+// decodeClientError turns an error response of encodeError back into the error of this package
+// it was made from. The message of the server is kept.
+func decodeClientError(r *http.Response) error {
+	var body struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}
+	_ = json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&body)
+	return clientError(r.StatusCode, body.Error, body.Fields)
+}
+
+// clientError returns the error of this package the server answered with status and message.
+func clientError(status int, message string, fields []FieldError) error {
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	var match error
+	for _, sentinel := range clientErrors {
+		if codeFrom(sentinel) != status {
+			continue
+		}
+		if strings.HasSuffix(message, sentinel.Error()) {
+			match = sentinel
+			break
+		}
+		if match == nil {
+			match = sentinel
+		}
+	}
+	switch {
+	case match == nil && status >= http.StatusInternalServerError:
+		return fmt.Errorf("%s (%d): %w", message, status, errServerFailed)
+	case match == nil:
+		return fmt.Errorf("%s (%d)", message, status)
+	case message == match.Error():
+		return match
+	}
+	return fmt.Errorf("%s: %w", strings.TrimSuffix(message, ": "+match.Error()), match)
+}
+
+// decodeClientResponse decodes the JSON body of a response into response, or the error it answers with.
+func decodeClientResponse(r *http.Response, response interface{}) error {
+	if r.StatusCode >= http.StatusMultipleChoices {
+		return decodeClientError(r)
+	}
+	if err := json.NewDecoder(r.Body).Decode(response); err != nil {
+		return fmt.Errorf("decode response: %v", err)
+	}
+	return nil
+}
+
+// clientPath appends the segments to the path of the request, each escaped.
+func clientPath(r *http.Request, segments ...string) {
+	escaped := r.URL.EscapedPath()
+	for _, segment := range segments {
+		r.URL.Path += "/" + segment
+		escaped += "/" + url.PathEscape(segment)
+	}
+	r.URL.RawPath = escaped
+}
+
+func clientQuery(r *http.Request, key, value string) {
+	query := r.URL.Query()
+	query.Set(key, value)
+	r.URL.RawQuery = query.Encode()
+}
+
+func clientBody(r *http.Request, contentType string, body []byte) {
+	r.Header.Set("Content-Type", contentType)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+}
+
+func clientJSON(r *http.Request, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	clientBody(r, "application/json", data)
+	return nil
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+func encodeGetRolesClientRequest(_ context.Context, r *http.Request, _ interface{}) error {
+	clientPath(r, "roles")
+	return nil
+}
+
+func encodeGetRolePermissionsClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(getRolePermissionsRequest)
+	clientPath(r, "roles", strconv.Itoa(req.RoleID), "permissions")
+	return nil
+}
+
+func encodePutRoleClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(putRoleRequest)
+	clientPath(r, "roles", strconv.Itoa(req.Role.ID))
+	return clientJSON(r, req.Role)
+}
+
+// encodeGetUserClientRequest asks for the user of the token, the server ignores other names.
+func encodeGetUserClientRequest(_ context.Context, r *http.Request, _ interface{}) error {
+	clientPath(r, "user")
+	return nil
+}
+
+func encodeGetUsersRoleClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(getUsersRoleRequest)
+	clientPath(r, "usersrole")
+	if req.Filter.IncludeDeleted {
+		clientQuery(r, "include_deleted", "true")
+	}
+	return nil
+}
+
+// encodeGetRoleExpirationsClientRequest rounds within up to whole days.
+func encodeGetRoleExpirationsClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(getRoleExpirationsRequest)
+	clientPath(r, "usersrole", "expiring")
+	day := 24 * time.Hour
+	clientQuery(r, "days", strconv.Itoa(int((req.Within+day-1)/day)))
+	return nil
+}
+
+// auditEventFields are the fields of the AuditEvent type of schema.graphql that app.AuditEvent holds.
+const auditEventFields = "id createTime actor action userName details"
+
+// encodeGetAuditEventsClientRequest queries the latest events, or those of each user of the
+// filter under an alias of its own. Without a limit the defaults of the schema apply.
+// The server narrows the events to the caller's scope itself, ManagedBy is not sent.
+func encodeGetAuditEventsClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(getAuditEventsRequest)
+	events := "auditEvents { " + auditEventFields + " }"
+	if req.Filter.Limit > 0 {
+		events = "auditEvents(limit: " + strconv.Itoa(req.Filter.Limit) + ") { " + auditEventFields + " }"
+	}
+	if len(req.Filter.UserNames) == 0 {
+		return clientJSON(r, map[string]interface{}{"query": "{ " + events + " }"})
+	}
+
+	var params, fields []string
+	variables := map[string]interface{}{}
+	for i, name := range req.Filter.UserNames {
+		alias := "u" + strconv.Itoa(i)
+		params = append(params, "$"+alias+": String!")
+		fields = append(fields, alias+": user(name: $"+alias+") { "+events+" }")
+		variables[alias] = name
+	}
+	query := "query(" + strings.Join(params, ", ") + ") { " + strings.Join(fields, " ") + " }"
+	return clientJSON(r, map[string]interface{}{"query": query, "variables": variables})
+}
+
+func encodeImportUsersClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(importUsersRequest)
+	clientPath(r, "users", "import")
+	clientQuery(r, "dry_run", strconv.FormatBool(req.Options.DryRun))
+	if req.Options.Upsert {
+		clientQuery(r, "mode", "upsert")
+	}
+	if req.Options.ChunkSize != 0 {
+		clientQuery(r, "chunk_size", strconv.Itoa(req.Options.ChunkSize))
+	}
+	var file bytes.Buffer
+	if err := WriteUserRecords(&file, FormatNDJSON, req.Records); err != nil {
+		return err
+	}
+	clientBody(r, "application/x-ndjson", file.Bytes())
+	return nil
+}
+
+func encodeExportUsersClientRequest(_ context.Context, r *http.Request, _ interface{}) error {
+	clientPath(r, "users", "export")
+	r.Header.Set("Accept", "application/x-ndjson")
+	return nil
+}
+
+func encodeBatchUsersClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	clientPath(r, "users", "batch")
+	return clientJSON(r, request.(batchUsersRequest).Batch)
+}
+
+func makeEncodeRolePlanClientRequest(action string) httptransport.EncodeRequestFunc {
+	return func(_ context.Context, r *http.Request, request interface{}) error {
+		clientPath(r, "usersrole", action)
+		return clientJSON(r, request.(rolePlanRequest).Desired)
+	}
+}
+
+// encodePostUserClientRequest sends invite_code next to the user when an invitation is redeemed.
+func encodePostUserClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(postUserRequest)
+	clientPath(r, "user")
+	return clientJSON(r, struct {
+		app.User
+		InviteCode string `json:"invite_code,omitempty"`
+	}{req.User, req.InviteCode})
+}
+
+func encodePutUserClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	clientPath(r, "user")
+	return clientJSON(r, request.(putUserRequest).User)
+}
+
+func encodePatchUserClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(patchUserRequest)
+	clientPath(r, "user", req.UserName)
+	if req.Patch.MergePatch != nil {
+		clientBody(r, "application/merge-patch+json", req.Patch.MergePatch)
+		return nil
+	}
+	data, err := json.Marshal(req.Patch.JSONPatch)
+	if err != nil {
+		return err
+	}
+	clientBody(r, "application/json-patch+json", data)
+	return nil
+}
+
+func encodeDeleteUserClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	clientPath(r, "user", request.(deleteUserRequest).UserName)
+	return nil
+}
+
+// encodePostLoginClientRequest records the login of the user of the token.
+func encodePostLoginClientRequest(_ context.Context, r *http.Request, _ interface{}) error {
+	clientPath(r, "user", "login")
+	return nil
+}
+
+// encodePostUserStatusClientRequest picks the route of the status, unlocking is enabling.
+func encodePostUserStatusClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(postUserStatusRequest)
+	var action string
+	switch req.Change.Status {
+	case app.UserStatusDisabled:
+		action = "disable"
+	case app.UserStatusLocked:
+		action = "lock"
+	case app.UserStatusActive:
+		action = "enable"
+	default:
+		return fmt.Errorf("status %q: %w", req.Change.Status, ErrInvalidArgument)
+	}
+	clientPath(r, "user", req.UserName, action)
+	return clientJSON(r, req.Change)
+}
+
+func encodePostRestoreClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	clientPath(r, "user", request.(postRestoreRequest).UserName, "restore")
+	return nil
+}
+
+func encodePostRenameClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(postRenameRequest)
+	clientPath(r, "user", req.UserName, "rename")
+	return clientJSON(r, map[string]string{"user_name": req.NewName})
+}
+
+func encodePostUserRoleClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(postUserRoleRequest)
+	clientPath(r, "user", req.UserName, "roles")
+	return clientJSON(r, req.Role)
+}
+
+func encodeDeleteUserRoleClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(deleteUserRoleRequest)
+	clientPath(r, "user", req.UserName, "roles", strconv.Itoa(req.RoleID))
+	return nil
+}
+
+func encodeGetGroupsClientRequest(_ context.Context, r *http.Request, _ interface{}) error {
+	clientPath(r, "groups")
+	return nil
+}
+
+func encodePostGroupClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	clientPath(r, "groups")
+	return clientJSON(r, request.(postGroupRequest).Group)
+}
+
+func encodePutGroupClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(putGroupRequest)
+	clientPath(r, "groups", strconv.Itoa(req.Group.ID))
+	return clientJSON(r, req.Group)
+}
+
+func encodeDeleteGroupClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	clientPath(r, "groups", strconv.Itoa(request.(deleteGroupRequest).GroupID))
+	return nil
+}
+
+// makeEncodePostGroupMemberClientRequest adds a member or, with list "managers", a manager.
+func makeEncodePostGroupMemberClientRequest(list string) httptransport.EncodeRequestFunc {
+	return func(_ context.Context, r *http.Request, request interface{}) error {
+		req := request.(groupMemberRequest)
+		clientPath(r, "groups", strconv.Itoa(req.GroupID), list)
+		return clientJSON(r, map[string]string{"user_name": req.UserName})
+	}
+}
+
+func makeEncodeDeleteGroupMemberClientRequest(list string) httptransport.EncodeRequestFunc {
+	return func(_ context.Context, r *http.Request, request interface{}) error {
+		req := request.(groupMemberRequest)
+		clientPath(r, "groups", strconv.Itoa(req.GroupID), list, req.UserName)
+		return nil
+	}
+}
+
+func encodePostGroupRoleClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(groupRoleRequest)
+	clientPath(r, "groups", strconv.Itoa(req.GroupID), "roles")
+	return clientJSON(r, req.Role)
+}
+
+func encodeDeleteGroupRoleClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(groupRoleRequest)
+	clientPath(r, "groups", strconv.Itoa(req.GroupID), "roles", strconv.Itoa(req.Role.ID))
+	return nil
+}
+
+func encodePostInvitationClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	clientPath(r, "invitations")
+	return clientJSON(r, request.(postInvitationRequest).Invitation)
+}
+
+func encodeGetInvitationsClientRequest(_ context.Context, r *http.Request, _ interface{}) error {
+	clientPath(r, "invitations")
+	return nil
+}
+
+func encodeDeleteInvitationClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	clientPath(r, "invitations", strconv.Itoa(request.(deleteInvitationRequest).ID))
+	return nil
+}
+
+func encodeGetOrganizationsClientRequest(_ context.Context, r *http.Request, _ interface{}) error {
+	clientPath(r, "organizations")
+	return nil
+}
+
+func encodePostOrganizationClientRequest(_ context.Context, r *http.Request, request interface{}) error {
+	clientPath(r, "organizations")
+	return clientJSON(r, request.(postOrganizationRequest).Organization)
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+func decodeGetRolesClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getRolesResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeGetRolePermissionsClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getRolePermissionsResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePutRoleClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp putRoleResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeGetUserClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getUserResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeGetUsersRoleClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getUsersRoleResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeGetRoleExpirationsClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getRoleExpirationsResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+// graphAuditEventResponse is an AuditEvent of schema.graphql, whose id is a string.
+type graphAuditEventResponse struct {
+	ID         string                 `json:"id"`
+	CreateTime *time.Time             `json:"createTime"`
+	Actor      string                 `json:"actor"`
+	Action     string                 `json:"action"`
+	UserName   *string                `json:"userName"`
+	Details    map[string]interface{} `json:"details"`
+}
+
+// decodeGetAuditEventsClientResponse collects the events of all users of the query, the latest
+// first, and turns the first error of the response into the error of this package it stands for.
+func decodeGetAuditEventsClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var body struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []struct {
+			Message    string `json:"message"`
+			Extensions struct {
+				Status int          `json:"status"`
+				Fields []FieldError `json:"fields"`
+			} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := decodeClientResponse(r, &body); err != nil {
+		return nil, err
+	}
+	if len(body.Errors) > 0 {
+		graphErr := body.Errors[0]
+		if graphErr.Extensions.Status == 0 {
+			graphErr.Extensions.Status = http.StatusBadRequest
+		}
+		return nil, clientError(graphErr.Extensions.Status, graphErr.Message, graphErr.Extensions.Fields)
+	}
+
+	var graphEvents []graphAuditEventResponse
+	for field, data := range body.Data {
+		var events []graphAuditEventResponse
+		if field == "auditEvents" {
+			if err := json.Unmarshal(data, &events); err != nil {
+				return nil, fmt.Errorf("decode response: %v", err)
+			}
+		} else {
+			var user *struct {
+				AuditEvents []graphAuditEventResponse `json:"auditEvents"`
+			}
+			if err := json.Unmarshal(data, &user); err != nil {
+				return nil, fmt.Errorf("decode response: %v", err)
+			}
+			if user != nil {
+				events = user.AuditEvents
+			}
+		}
+		graphEvents = append(graphEvents, events...)
+	}
+
+	resp := getAuditEventsResponse{Events: make([]app.AuditEvent, 0, len(graphEvents))}
+	for _, e := range graphEvents {
+		id, err := strconv.ParseInt(e.ID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("decode response: event id %q: %v", e.ID, err)
+		}
+		event := app.AuditEvent{ID: id, CreateTime: e.CreateTime, Actor: e.Actor, Action: e.Action, Details: e.Details}
+		if e.UserName != nil {
+			event.UserName = *e.UserName
+		}
+		resp.Events = append(resp.Events, event)
+	}
+	sort.Slice(resp.Events, func(i, j int) bool { return resp.Events[i].ID > resp.Events[j].ID })
+	return resp, nil
+}
+
+func decodeImportUsersClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp importUsersResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+// decodeExportUsersClientResponse reads the NDJSON file of the export. A file the server
+// cut short with an {"error": ...} line fails as a whole.
+func decodeExportUsersClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode >= http.StatusMultipleChoices {
+		return nil, decodeClientError(r)
+	}
+	records, err := ReadUserRecords(r.Body, FormatNDJSON)
+	if err != nil {
+		return nil, fmt.Errorf("decode response: %v: %w", err, errServerFailed)
+	}
+	return exportUsersResponse{Records: records}, nil
+}
+
+func decodeBatchUsersClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp batchUsersResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeRolePlanClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp rolePlanResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePostUserClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp postUserResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePutUserClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp putUserResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePatchUserClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp patchUserResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeDeleteUserClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp deleteUserResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePostLoginClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp postLoginResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePostUserStatusClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp postUserStatusResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePostRestoreClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp postRestoreResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePostRenameClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp postRenameResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePostUserRoleClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp postUserRoleResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeDeleteUserRoleClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp deleteUserRoleResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeGetGroupsClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getGroupsResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePostGroupClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp postGroupResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeGroupChangeClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp groupChangeResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePostInvitationClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp postInvitationResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeGetInvitationsClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getInvitationsResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeDeleteInvitationClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp deleteInvitationResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodeGetOrganizationsClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getOrganizationsResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
+
+func decodePostOrganizationClientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp postOrganizationResponse
+	err := decodeClientResponse(r, &resp)
+	return resp, err
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+	"time"
+)
+
+func TestDecodeClientError(t *testing.T) {
+	for _, tc := range []struct {
+		name, body string
+		status     int
+		is         error
+		message    string
+	}{
+		{"sentinel", `{"error":"not found"}`, http.StatusNotFound, ErrNotFound, "not found"},
+		{"wrapped sentinel", `{"error":"GetUser: user bob: not found"}`, http.StatusNotFound, ErrNotFound,
+			"GetUser: user bob: not found"},
+		{"later sentinel of the status", `{"error":"PostUser: ` + ErrIdempotencyInProgress.Error() + `"}`,
+			http.StatusConflict, ErrIdempotencyInProgress, "PostUser: " + ErrIdempotencyInProgress.Error()},
+		{"unknown message of the status", `{"error":"something"}`, http.StatusConflict, ErrLastAdministrator,
+			"something: " + ErrLastAdministrator.Error()},
+		{"validation", `{"error":"invalid","fields":[{"field":"user_name","reason":"is required"}]}`,
+			http.StatusUnprocessableEntity, ErrValidation, "invalid user_name is required"},
+		{"no body", ``, http.StatusRequestEntityTooLarge, ErrRequestTooLarge,
+			"Request Entity Too Large: " + ErrRequestTooLarge.Error()},
+		{"server error", `{"error":"boom"}`, http.StatusInternalServerError, errServerFailed, "boom (500): server failed"},
+		{"proxy error", `<html>`, http.StatusBadGateway, errServerFailed, "Bad Gateway (502): server failed"},
+		{"unmapped status", `{"error":"teapot"}`, http.StatusTeapot, nil, "teapot (418)"},
+	} {
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(tc.status)
+		_, _ = rec.WriteString(tc.body)
+		err := decodeClientError(rec.Result())
+		if tc.is != nil && !errors.Is(err, tc.is) {
+			t.Errorf("%s: %v is not %v", tc.name, err, tc.is)
+		}
+		if tc.is == nil && retryable(err) {
+			t.Errorf("%s: %v is retried", tc.name, err)
+		}
+		if err.Error() != tc.message {
+			t.Errorf("%s: message %q, want %q", tc.name, err.Error(), tc.message)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		errs     []error
+		calls    int
+		canceled bool
+		err      error
+	}{
+		{"success", []error{nil}, 1, false, nil},
+		{"server errors until success", []error{errServerFailed, errServerFailed, nil}, 3, false, nil},
+		{"key in use", []error{ErrIdempotencyInProgress, nil}, 2, false, nil},
+		{"retries exhausted", []error{errServerFailed, errServerFailed, errServerFailed, nil}, 3, false, errServerFailed},
+		{"rejected request", []error{ErrNotFound, nil}, 1, false, ErrNotFound},
+		{"canceled call", []error{errServerFailed, nil}, 1, true, errServerFailed},
+	} {
+		calls := 0
+		next := func(context.Context, interface{}) (interface{}, error) {
+			err := tc.errs[calls]
+			calls++
+			return "ok", err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		if tc.canceled {
+			cancel()
+		}
+		_, err := retry(2, time.Millisecond)(next)(ctx, nil)
+		cancel()
+		if calls != tc.calls || !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: %d calls, error %v, want %d, %v", tc.name, calls, err, tc.calls, tc.err)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		retryable bool
+	}{
+		{errServerFailed, true},
+		{ErrIdempotencyInProgress, true},
+		{&url.Error{Op: "Post", URL: "http://users/v1/user", Err: errors.New("connection refused")}, true},
+		{ErrNotFound, false},
+		{context.DeadlineExceeded, false},
+		{&url.Error{Op: "Post", URL: "http://users/v1/user", Err: context.Canceled}, false},
+	} {
+		if got := retryable(tc.err); got != tc.retryable {
+			t.Errorf("retryable(%v) = %v, want %v", tc.err, got, tc.retryable)
+		}
+	}
+}
+
+// TestClientEndpoints checks that the retries of a call share its Idempotency-Key and that
+// audit events are read from /graphql.
+func TestClientEndpoints(t *testing.T) {
+	var keys []string
+	var query struct {
+		Query     string            `json:"query"`
+		Variables map[string]string `json:"variables"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/user/bob/disable":
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			if len(keys) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{}`))
+		case "/graphql":
+			if r.Header.Get("Authorization") != "Bearer t" {
+				t.Errorf("Authorization %q", r.Header.Get("Authorization"))
+			}
+			query.Query, query.Variables = "", nil
+			_ = json.NewDecoder(r.Body).Decode(&query)
+			if query.Variables == nil {
+				_, _ = w.Write([]byte(`{"errors":[{"message":"auditEvents: role is not administrator","extensions":{"status":403}}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":{
+				"u0":{"auditEvents":[{"id":"3","createTime":"2026-01-02T03:04:05Z","actor":"alice","action":"user.created","userName":"bob","details":{"role":"viewer"}}]},
+				"u1":null,
+				"u2":{"auditEvents":[{"id":"7","createTime":null,"actor":"alice","action":"role.granted","userName":"carol","details":null}]}}}`))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+		}
+	}))
+	defer srv.Close()
+	e, err := MakeClientEndpoints(srv.URL, ClientToken("t"), ClientRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = e.PostUserStatus(context.Background(), "bob", app.StatusChange{Status: app.UserStatusDisabled}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Idempotency-Keys %q, want one key for both attempts", keys)
+	}
+
+	events, err := e.GetAuditEvents(context.Background(), app.AuditFilter{UserNames: []string{"bob", "gone", "carol"}, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := []app.AuditEvent{
+		{ID: 7, Actor: "alice", Action: "role.granted", UserName: "carol"},
+		{ID: 3, CreateTime: &created, Actor: "alice", Action: "user.created", UserName: "bob",
+			Details: map[string]interface{}{"role": "viewer"}},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events %+v, want %+v", events, want)
+	}
+	if query.Variables["u1"] != "gone" {
+		t.Errorf("variables %v", query.Variables)
+	}
+
+	if _, err = e.GetAuditEvents(context.Background(), app.AuditFilter{}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("error %v, want ErrForbidden", err)
+	}
+	if want := "{ auditEvents { " + auditEventFields + " } }"; query.Query != want {
+		t.Errorf("query %q, want %q", query.Query, want)
+	}
+}
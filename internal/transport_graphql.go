@@ -0,0 +1,961 @@
+package internal
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	"github.com/graph-gophers/graphql-go/trace/tracer"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testgenerate_backend_user/internal/app"
+	"time"
+)
+
+//go:embed schema.graphql
+var graphSchema string
+
+// graphQLHandler serves the users, roles, groups and audit events as a graph at /graphql.
+// Fields are resolved by the endpoints of the HTTP API on requests decoded by the decoders
+// of their routes, so they are authorized the same way. Queries nested deeper than
+// GRAPHQL_MAX_DEPTH are rejected before they run, and a query stops resolving fields once it
+// resolved GRAPHQL_MAX_COMPLEXITY of them, see complexityTracer.
+type graphQLHandler struct {
+	e      Endpoints
+	schema *graphql.Schema
+	logger UnitLogHandler
+}
+
+func makeGraphQLHandler(e Endpoints, logger UnitLogHandler) http.Handler {
+	return graphQLHandler{
+		e: e,
+		schema: graphql.MustParseSchema(graphSchema, &graphRoot{},
+			graphql.MaxDepth(app.GetEnvAsInt("GRAPHQL_MAX_DEPTH", 12)),
+			graphql.Tracer(complexityTracer{app.GetEnvAsInt("GRAPHQL_MAX_COMPLEXITY", 1000)})),
+		logger: logger,
+	}
+}
+
+func (h graphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := authenticate(r.Context(), r)
+	var params struct {
+		Query         string                 `json:"query" validate:"required"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+		Extensions    map[string]interface{} `json:"extensions"`
+	}
+	if err := decodeJSON(r, &params); err != nil {
+		h.logger.Handle(ctx, err)
+		encodeError(ctx, err, w)
+		return
+	}
+
+	ctx = context.WithValue(ctx, graphLoadersKey{}, &graphLoaders{e: h.e})
+	response := h.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+	for _, err := range response.Errors {
+		if err.ResolverError == nil {
+			continue
+		}
+		h.logger.Handle(ctx, err.ResolverError)
+		err.Extensions = map[string]interface{}{"status": codeFrom(err.ResolverError)}
+		var invalid *ValidationError
+		if errors.As(err.ResolverError, &invalid) {
+			err.Extensions["fields"] = invalid.Fields
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+type graphCostKey struct{}
+
+// complexityTracer limits the fields a query resolves to max. Every resolved field counts one,
+// so the fields below a list count once per element; introspection is free, it does not touch
+// the database. Fields past the limit get a context that is done, which graphql-go reports as
+// an error of the field instead of running its resolver.
+type complexityTracer struct {
+	max int
+}
+
+func (t complexityTracer) TraceQuery(ctx context.Context, _, _ string, _ map[string]interface{},
+	_ map[string]*introspection.Type) (context.Context, tracer.QueryFinishFunc) {
+	return context.WithValue(ctx, graphCostKey{}, new(int64)), func([]*gqlerrors.QueryError) {}
+}
+
+func (t complexityTracer) TraceField(ctx context.Context, _, typeName, fieldName string, _ bool,
+	_ map[string]interface{}) (context.Context, tracer.FieldFinishFunc) {
+	finish := func(*gqlerrors.QueryError) {}
+	cost, ok := ctx.Value(graphCostKey{}).(*int64)
+	if !ok || strings.HasPrefix(typeName, "__") || strings.HasPrefix(fieldName, "__") {
+		return ctx, finish
+	}
+	if atomic.AddInt64(cost, 1) > int64(t.max) {
+		return complexityExceeded{ctx, t.max}, finish
+	}
+	return ctx, finish
+}
+
+// complexityExceeded is the context of a field past the limit of complexityTracer.
+type complexityExceeded struct {
+	context.Context
+	max int
+}
+
+var closedDone = func() chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}()
+
+func (c complexityExceeded) Done() <-chan struct{} { return closedDone }
+
+func (c complexityExceeded) Err() error {
+	return fmt.Errorf("query complexity exceeds the limit of %d", c.max)
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+type graphLoadersKey struct{}
+
+// graphLoaders load the users, roles and groups of a request once, however many fields ask
+// for them, so nested fields do not query Postgres for each parent. Audit events of users are
+// batched by auditLoader.
+type graphLoaders struct {
+	e      Endpoints
+	users  lazy[userIndex]
+	roles  lazy[roleIndex]
+	groups lazy[groupIndex]
+	audit  auditLoader
+}
+
+func loadersFrom(ctx context.Context) *graphLoaders {
+	return ctx.Value(graphLoadersKey{}).(*graphLoaders)
+}
+
+// lazy holds the result of a load that runs at most once.
+type lazy[T any] struct {
+	once  sync.Once
+	value T
+	err   error
+}
+
+func (l *lazy[T]) get(load func() (T, error)) (T, error) {
+	l.once.Do(func() { l.value, l.err = load() })
+	return l.value, l.err
+}
+
+type userIndex struct {
+	list   []app.User
+	byName map[string]app.User
+}
+
+type roleIndex struct {
+	list []app.Role
+	byID map[int]app.Role
+}
+
+type groupIndex struct {
+	list     []app.Group
+	byID     map[int]app.Group
+	byMember map[string][]app.Group
+}
+
+// serveRoute runs the endpoint of a route on a request decoded by the decoder of the route.
+func serveRoute(ctx context.Context, e endpoint.Endpoint, decode httptransport.DecodeRequestFunc, method string,
+	vars map[string]string, query url.Values, body interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, method, vars, query, body)
+	if err != nil {
+		return nil, err
+	}
+	request, err := decode(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	response, err := e(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := response.(errorer); ok && f.error() != nil {
+		return nil, f.error()
+	}
+	return response, nil
+}
+
+// listUsers lists the users in the caller's scope like GET /usersrole.
+func (l *graphLoaders) listUsers(ctx context.Context, includeDeleted bool) ([]app.User, error) {
+	response, err := serveRoute(ctx, l.e.GetUsersRoleEndpoint, decodeUsersRoleRequest, http.MethodGet, nil,
+		url.Values{"include_deleted": {strconv.FormatBool(includeDeleted)}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	users := response.(getUsersRoleResponse).Users
+	l.audit.see(users)
+	return users, nil
+}
+
+func (l *graphLoaders) userIndex(ctx context.Context) (userIndex, error) {
+	return l.users.get(func() (userIndex, error) {
+		users, err := l.listUsers(ctx, false)
+		if err != nil {
+			return userIndex{}, err
+		}
+		index := userIndex{users, make(map[string]app.User, len(users))}
+		for _, user := range users {
+			index.byName[user.Name] = user
+		}
+		return index, nil
+	})
+}
+
+func (l *graphLoaders) roleIndex(ctx context.Context) (roleIndex, error) {
+	return l.roles.get(func() (roleIndex, error) {
+		response, err := serveRoute(ctx, l.e.getRolesEndpoint, decodeRolesRequest, http.MethodGet, nil, nil, nil)
+		if err != nil {
+			return roleIndex{}, err
+		}
+		roles := response.(getRolesResponse).Roles
+		index := roleIndex{roles, make(map[int]app.Role, len(roles))}
+		for _, role := range roles {
+			index.byID[role.ID] = role
+		}
+		return index, nil
+	})
+}
+
+func (l *graphLoaders) groupIndex(ctx context.Context) (groupIndex, error) {
+	return l.groups.get(func() (groupIndex, error) {
+		response, err := serveRoute(ctx, l.e.GetGroupsEndpoint, decodeGroupsRequest, http.MethodGet, nil, nil, nil)
+		if err != nil {
+			return groupIndex{}, err
+		}
+		groups := response.(getGroupsResponse).Groups
+		index := groupIndex{groups, make(map[int]app.Group, len(groups)), map[string][]app.Group{}}
+		for _, group := range groups {
+			index.byID[group.ID] = group
+			for _, member := range group.Members {
+				index.byMember[member] = append(index.byMember[member], group)
+			}
+		}
+		return index, nil
+	})
+}
+
+// auditLoader batches the audit events of users: the first user of a request that asks for
+// its events loads the events of all users the request has listed, in one query.
+type auditLoader struct {
+	mu     sync.Mutex
+	seen   []string
+	loaded map[int]map[string][]app.AuditEvent
+}
+
+func (a *auditLoader) see(users []app.User) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, user := range users {
+		a.seen = append(a.seen, user.Name)
+	}
+}
+
+func (a *auditLoader) load(ctx context.Context, e Endpoints, userName string, limit int) ([]app.AuditEvent, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if events, ok := a.loaded[limit][userName]; ok {
+		return events, nil
+	}
+
+	scope, err := auditScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch := map[string][]app.AuditEvent{userName: nil}
+	names := []string{userName}
+	for _, name := range a.seen {
+		if _, ok := a.loaded[limit][name]; ok {
+			continue
+		}
+		if _, ok := batch[name]; !ok {
+			batch[name] = nil
+			names = append(names, name)
+		}
+	}
+	events, err := e.GetAuditEvents(ctx, app.AuditFilter{UserNames: names, ManagedBy: scope.Lead, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		batch[event.UserName] = append(batch[event.UserName], event)
+	}
+
+	if a.loaded == nil {
+		a.loaded = map[int]map[string][]app.AuditEvent{}
+	}
+	if a.loaded[limit] == nil {
+		a.loaded[limit] = map[string][]app.AuditEvent{}
+	}
+	for name, userEvents := range batch {
+		a.loaded[limit][name] = userEvents
+	}
+	return batch[userName], nil
+}
+
+// auditScope authorizes reading audit events like listing users: team leads read the events
+// about the members of their groups only.
+func auditScope(ctx context.Context) (userScope, error) {
+	caller, err := getPermissionParams(ctx)
+	if err != nil {
+		return userScope{}, err
+	}
+	return scopeOf(caller, PermUsersRead)
+}
+
+// auditLimit checks the limit argument of audit event listings.
+func auditLimit(limit int32) (int, error) {
+	if limit < 1 {
+		return 0, fmt.Errorf("limit must be positive: %w", ErrInvalidArgument)
+	}
+	return int(limit), nil
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+// graphRoot resolves the queries and the mutations of schema.graphql.
+type graphRoot struct{}
+
+func (graphRoot) Me(ctx context.Context) (*graphUser, error) {
+	response, err := serveRoute(ctx, loadersFrom(ctx).e.GetUserEndpoint, decodeUserRequest, http.MethodGet,
+		nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &graphUser{response.(getUserResponse).User}, nil
+}
+
+func (graphRoot) Users(ctx context.Context, args struct{ IncludeDeleted bool }) (*[]*graphUser, error) {
+	l := loadersFrom(ctx)
+	var (
+		users []app.User
+		err   error
+	)
+	if args.IncludeDeleted {
+		users, err = l.listUsers(ctx, true)
+	} else {
+		var index userIndex
+		index, err = l.userIndex(ctx)
+		users = index.list
+	}
+	if err != nil {
+		return nil, err
+	}
+	return graphUsers(users), nil
+}
+
+func (graphRoot) User(ctx context.Context, args struct{ Name string }) (*graphUser, error) {
+	index, err := loadersFrom(ctx).userIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name, err := resolveUserName(ctx, normalizeUserName(args.Name))
+	if err != nil {
+		return nil, err
+	}
+	user, ok := index.byName[name]
+	if !ok {
+		return nil, nil
+	}
+	return &graphUser{user}, nil
+}
+
+func (graphRoot) Roles(ctx context.Context) (*[]*graphRole, error) {
+	index, err := loadersFrom(ctx).roleIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]*graphRole, len(index.list))
+	for i, role := range index.list {
+		roles[i] = &graphRole{role, true}
+	}
+	return &roles, nil
+}
+
+func (graphRoot) Role(ctx context.Context, args struct{ ID int32 }) (*graphRole, error) {
+	index, err := loadersFrom(ctx).roleIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	role, ok := index.byID[int(args.ID)]
+	if !ok {
+		return nil, nil
+	}
+	return &graphRole{role, true}, nil
+}
+
+func (graphRoot) Groups(ctx context.Context) (*[]*graphGroup, error) {
+	index, err := loadersFrom(ctx).groupIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return graphGroups(index.list), nil
+}
+
+func (graphRoot) Group(ctx context.Context, args struct{ ID int32 }) (*graphGroup, error) {
+	index, err := loadersFrom(ctx).groupIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	group, ok := index.byID[int(args.ID)]
+	if !ok {
+		return nil, nil
+	}
+	return &graphGroup{group}, nil
+}
+
+func (graphRoot) AuditEvents(ctx context.Context, args struct{ Limit int32 }) (*[]*graphAuditEvent, error) {
+	scope, err := auditScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := auditLimit(args.Limit)
+	if err != nil {
+		return nil, err
+	}
+	events, err := loadersFrom(ctx).e.GetAuditEvents(ctx, app.AuditFilter{ManagedBy: scope.Lead, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return graphAuditEvents(events), nil
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+// userInput, roleInput and groupInput are sent as the bodies of the routes of the mutations.
+type userInput struct {
+	Name           string        `json:"user_name"`
+	RoleID         *int32        `json:"role_id,omitempty"`
+	Email          *string       `json:"email,omitempty"`
+	DisplayName    *string       `json:"display_name,omitempty"`
+	Locale         *string       `json:"locale,omitempty"`
+	Timezone       *string       `json:"timezone,omitempty"`
+	RoleExpiresAt  *graphql.Time `json:"role_expires_at,omitempty"`
+	FallbackRoleID *int32        `json:"fallback_role_id,omitempty"`
+}
+
+type roleInput struct {
+	Name        string    `json:"role_name"`
+	Parents     *[]int32  `json:"parents,omitempty"`
+	Permissions *[]string `json:"permissions,omitempty"`
+}
+
+type groupInput struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	ParentID    *int32  `json:"parent_id,omitempty"`
+}
+
+// mutate runs the route of a mutation that answers with no content.
+func mutate(ctx context.Context, e endpoint.Endpoint, decode httptransport.DecodeRequestFunc, method string,
+	vars map[string]string, query url.Values, body interface{}) (bool, error) {
+	_, err := serveRoute(ctx, e, decode, method, vars, query, body)
+	return err == nil, err
+}
+
+func (graphRoot) UpdateRole(ctx context.Context, args struct {
+	ID   int32
+	Role roleInput
+}) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.PutRoleEndpoint, decodePutRoleRequest, http.MethodPut,
+		map[string]string{"id": itoa(args.ID)}, nil, args.Role)
+}
+
+func (graphRoot) AddUser(ctx context.Context, args struct {
+	User       userInput
+	InviteCode *string
+}) (bool, error) {
+	body := struct {
+		userInput
+		InviteCode *string `json:"invite_code,omitempty"`
+	}{args.User, args.InviteCode}
+	return mutate(ctx, loadersFrom(ctx).e.PostUserEndpoint, decodePostUserRequest, http.MethodPost, nil, nil, body)
+}
+
+func (graphRoot) UpdateUser(ctx context.Context, args struct {
+	User  userInput
+	Force bool
+}) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.PutUserEndpoint, decodePutRequest, http.MethodPut,
+		nil, forceQuery(args.Force), args.User)
+}
+
+func (graphRoot) PatchUser(ctx context.Context, args struct {
+	Name  string
+	Patch graphJSON
+	Force bool
+}) (*graphUser, error) {
+	patch, err := json.Marshal(args.Patch.value)
+	if err != nil {
+		return nil, fmt.Errorf("patch: %v: %w", err, ErrInvalidArgument)
+	}
+	response, err := serveRoute(ctx, loadersFrom(ctx).e.PatchUserEndpoint, decodePatchUserRequest, http.MethodPatch,
+		map[string]string{"user": args.Name}, forceQuery(args.Force), patch)
+	if err != nil {
+		return nil, err
+	}
+	return &graphUser{response.(patchUserResponse).User}, nil
+}
+
+func (graphRoot) DeleteUser(ctx context.Context, args struct {
+	Name  string
+	Force bool
+}) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.DeleteUserEndpoint, decodeDeleteRequest, http.MethodDelete,
+		map[string]string{"user": args.Name}, forceQuery(args.Force), nil)
+}
+
+func (graphRoot) RestoreUser(ctx context.Context, args struct{ Name string }) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.PostRestoreEndpoint, decodeRestoreRequest, http.MethodPost,
+		map[string]string{"user": args.Name}, nil, nil)
+}
+
+func (graphRoot) RenameUser(ctx context.Context, args struct {
+	Name    string
+	NewName string
+}) (*graphUser, error) {
+	response, err := serveRoute(ctx, loadersFrom(ctx).e.PostRenameEndpoint, decodeRenameRequest, http.MethodPost,
+		map[string]string{"user": args.Name}, nil, map[string]string{"user_name": args.NewName})
+	if err != nil {
+		return nil, err
+	}
+	return &graphUser{response.(postRenameResponse).User}, nil
+}
+
+func setUserStatus(ctx context.Context, userName, status string, force bool, change interface{}) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.PostUserStatusEndpoint, makeDecodeUserStatusRequest(status),
+		http.MethodPost, map[string]string{"user": userName}, forceQuery(force), change)
+}
+
+func (graphRoot) DisableUser(ctx context.Context, args struct {
+	Name   string
+	Reason *string
+	Force  bool
+}) (bool, error) {
+	return setUserStatus(ctx, args.Name, app.UserStatusDisabled, args.Force, app.StatusChange{Reason: deref(args.Reason)})
+}
+
+func (graphRoot) EnableUser(ctx context.Context, args struct{ Name string }) (bool, error) {
+	return setUserStatus(ctx, args.Name, app.UserStatusActive, false, nil)
+}
+
+func (graphRoot) LockUser(ctx context.Context, args struct {
+	Name   string
+	Reason *string
+	Until  *graphql.Time
+	Force  bool
+}) (bool, error) {
+	change := app.StatusChange{Reason: deref(args.Reason)}
+	if args.Until != nil {
+		change.Until = &args.Until.Time
+	}
+	return setUserStatus(ctx, args.Name, app.UserStatusLocked, args.Force, change)
+}
+
+func (graphRoot) UnlockUser(ctx context.Context, args struct{ Name string }) (bool, error) {
+	return setUserStatus(ctx, args.Name, app.UserStatusActive, false, nil)
+}
+
+func (graphRoot) GrantRole(ctx context.Context, args struct {
+	User   string
+	RoleID int32
+}) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.PostUserRoleEndpoint, decodePostUserRoleRequest, http.MethodPost,
+		map[string]string{"user": args.User}, nil, app.Role{ID: int(args.RoleID)})
+}
+
+func (graphRoot) RevokeRole(ctx context.Context, args struct {
+	User   string
+	RoleID int32
+	Force  bool
+}) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.DeleteUserRoleEndpoint, decodeDeleteUserRoleRequest, http.MethodDelete,
+		map[string]string{"user": args.User, "role": itoa(args.RoleID)}, forceQuery(args.Force), nil)
+}
+
+func (graphRoot) CreateGroup(ctx context.Context, args struct{ Group groupInput }) (*graphGroup, error) {
+	response, err := serveRoute(ctx, loadersFrom(ctx).e.PostGroupEndpoint, decodePostGroupRequest, http.MethodPost,
+		nil, nil, args.Group)
+	if err != nil {
+		return nil, err
+	}
+	return &graphGroup{response.(postGroupResponse).Group}, nil
+}
+
+func (graphRoot) UpdateGroup(ctx context.Context, args struct {
+	ID    int32
+	Group groupInput
+}) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.PutGroupEndpoint, decodePutGroupRequest, http.MethodPut,
+		map[string]string{"id": itoa(args.ID)}, nil, args.Group)
+}
+
+func (graphRoot) DeleteGroup(ctx context.Context, args struct{ ID int32 }) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.DeleteGroupEndpoint, decodeDeleteGroupRequest, http.MethodDelete,
+		map[string]string{"id": itoa(args.ID)}, nil, nil)
+}
+
+type groupMemberArgs struct {
+	GroupID int32
+	User    string
+}
+
+func (graphRoot) AddGroupMember(ctx context.Context, args groupMemberArgs) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.PostGroupMemberEndpoint, decodePostGroupMemberRequest, http.MethodPost,
+		map[string]string{"id": itoa(args.GroupID)}, nil, map[string]string{"user_name": args.User})
+}
+
+func (graphRoot) RemoveGroupMember(ctx context.Context, args groupMemberArgs) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.DeleteGroupMemberEndpoint, decodeDeleteGroupMemberRequest,
+		http.MethodDelete, map[string]string{"id": itoa(args.GroupID), "user": args.User}, nil, nil)
+}
+
+// Managers are added and removed like members
+func (graphRoot) AddGroupManager(ctx context.Context, args groupMemberArgs) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.PostGroupManagerEndpoint, decodePostGroupMemberRequest, http.MethodPost,
+		map[string]string{"id": itoa(args.GroupID)}, nil, map[string]string{"user_name": args.User})
+}
+
+func (graphRoot) RemoveGroupManager(ctx context.Context, args groupMemberArgs) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.DeleteGroupManagerEndpoint, decodeDeleteGroupMemberRequest,
+		http.MethodDelete, map[string]string{"id": itoa(args.GroupID), "user": args.User}, nil, nil)
+}
+
+func (graphRoot) GrantGroupRole(ctx context.Context, args struct {
+	GroupID int32
+	RoleID  int32
+}) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.PostGroupRoleEndpoint, decodePostGroupRoleRequest, http.MethodPost,
+		map[string]string{"id": itoa(args.GroupID)}, nil, app.Role{ID: int(args.RoleID)})
+}
+
+func (graphRoot) RevokeGroupRole(ctx context.Context, args struct {
+	GroupID int32
+	RoleID  int32
+}) (bool, error) {
+	return mutate(ctx, loadersFrom(ctx).e.DeleteGroupRoleEndpoint, decodeDeleteGroupRoleRequest, http.MethodDelete,
+		map[string]string{"id": itoa(args.GroupID), "role": itoa(args.RoleID)}, nil, nil)
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+type graphUser struct {
+	u app.User
+}
+
+func graphUsers(users []app.User) *[]*graphUser {
+	resolvers := make([]*graphUser, len(users))
+	for i, user := range users {
+		resolvers[i] = &graphUser{user}
+	}
+	return &resolvers
+}
+
+func (r *graphUser) ID() *string                  { return optString(r.u.ID) }
+func (r *graphUser) Name() string                 { return r.u.Name }
+func (r *graphUser) Email() *string               { return optString(r.u.Email) }
+func (r *graphUser) DisplayName() *string         { return optString(r.u.DisplayName) }
+func (r *graphUser) Status() *string              { return optString(r.u.Status) }
+func (r *graphUser) StatusReason() *string        { return optString(r.u.StatusReason) }
+func (r *graphUser) LockedUntil() *graphql.Time   { return optTime(r.u.LockedUntil) }
+func (r *graphUser) DeletedAt() *graphql.Time     { return optTime(r.u.DeletedAt) }
+func (r *graphUser) CreateTime() *string          { return optString(r.u.CreateTime) }
+func (r *graphUser) LastLoginAt() *graphql.Time   { return optTime(r.u.LastLoginAt) }
+func (r *graphUser) Locale() *string              { return optString(r.u.Locale) }
+func (r *graphUser) Timezone() *string            { return optString(r.u.Timezone) }
+func (r *graphUser) RoleExpiresAt() *graphql.Time { return optTime(r.u.RoleExpiresAt) }
+func (r *graphUser) Aliases() []string            { return r.u.Aliases }
+
+func (r *graphUser) Role() *graphRole {
+	if r.u.RoleID == 0 {
+		return nil
+	}
+	return &graphRole{role: app.Role{ID: r.u.RoleID, Role: r.u.Role}}
+}
+
+func (r *graphUser) FallbackRole() *graphRole {
+	if r.u.FallbackRoleID == 0 {
+		return nil
+	}
+	return &graphRole{role: app.Role{ID: r.u.FallbackRoleID, Role: r.u.FallbackRoleName}}
+}
+
+func (r *graphUser) Roles() []*graphRole {
+	roles := make([]*graphRole, len(r.u.Roles))
+	for i, role := range r.u.Roles {
+		roles[i] = &graphRole{role: role}
+	}
+	return roles
+}
+
+func (r *graphUser) InheritedRoles() []*graphGroupRole {
+	roles := make([]*graphGroupRole, len(r.u.InheritedRoles))
+	for i, role := range r.u.InheritedRoles {
+		roles[i] = &graphGroupRole{role}
+	}
+	return roles
+}
+
+func (r *graphUser) Groups(ctx context.Context) (*[]*graphGroup, error) {
+	index, err := loadersFrom(ctx).groupIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return graphGroups(index.byMember[r.u.Name]), nil
+}
+
+func (r *graphUser) AuditEvents(ctx context.Context, args struct{ Limit int32 }) (*[]*graphAuditEvent, error) {
+	limit, err := auditLimit(args.Limit)
+	if err != nil {
+		return nil, err
+	}
+	l := loadersFrom(ctx)
+	events, err := l.audit.load(ctx, l.e, r.u.Name, limit)
+	if err != nil {
+		return nil, err
+	}
+	return graphAuditEvents(events), nil
+}
+
+// graphRole resolves a role. Roles of users and groups only carry their id and name, the other
+// fields are read from the listing of the roles, which needs roles.read like GET /roles.
+type graphRole struct {
+	role   app.Role
+	listed bool
+}
+
+func (r *graphRole) ID() int32    { return int32(r.role.ID) }
+func (r *graphRole) Name() string { return r.role.Role }
+
+func (r *graphRole) listing(ctx context.Context) (app.Role, error) {
+	if r.listed {
+		return r.role, nil
+	}
+	index, err := loadersFrom(ctx).roleIndex(ctx)
+	if err != nil {
+		return app.Role{}, err
+	}
+	role, ok := index.byID[r.role.ID]
+	if !ok {
+		return app.Role{}, fmt.Errorf("role %d: %w", r.role.ID, ErrNotFound)
+	}
+	return role, nil
+}
+
+func (r *graphRole) OrganizationID(ctx context.Context) (*int32, error) {
+	role, err := r.listing(ctx)
+	if err != nil || role.OrgID == nil {
+		return nil, err
+	}
+	id := int32(*role.OrgID)
+	return &id, nil
+}
+
+func (r *graphRole) Parents(ctx context.Context) (*[]*graphRole, error) {
+	role, err := r.listing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index, err := loadersFrom(ctx).roleIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parents := make([]*graphRole, len(role.Parents))
+	for i, id := range role.Parents {
+		parent, ok := index.byID[id]
+		parents[i] = &graphRole{parent, ok}
+		if !ok {
+			parents[i].role.ID = id
+		}
+	}
+	return &parents, nil
+}
+
+func (r *graphRole) Permissions(ctx context.Context) (*[]string, error) {
+	role, err := r.listing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &role.Permissions, nil
+}
+
+// EffectivePermissions are read from the role graph, which is cached, so roles do not query
+// Postgres one by one.
+func (r *graphRole) EffectivePermissions(ctx context.Context) (*[]string, error) {
+	response, err := serveRoute(ctx, loadersFrom(ctx).e.GetRolePermissionsEndpoint, decodeRolePermissionsRequest,
+		http.MethodGet, map[string]string{"id": strconv.Itoa(r.role.ID)}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	permissions := response.(getRolePermissionsResponse).Permissions
+	return &permissions, nil
+}
+
+type graphGroupRole struct {
+	role app.GroupRole
+}
+
+func (r *graphGroupRole) Role() *graphRole {
+	return &graphRole{role: app.Role{ID: r.role.ID, Role: r.role.Role}}
+}
+
+func (r *graphGroupRole) Group(ctx context.Context) (*graphGroup, error) {
+	return groupByID(ctx, r.role.GroupID)
+}
+
+type graphGroup struct {
+	g app.Group
+}
+
+func graphGroups(groups []app.Group) *[]*graphGroup {
+	resolvers := make([]*graphGroup, len(groups))
+	for i, group := range groups {
+		resolvers[i] = &graphGroup{group}
+	}
+	return &resolvers
+}
+
+// groupByID resolves a group of the listing, nil if the caller cannot see it.
+func groupByID(ctx context.Context, id int) (*graphGroup, error) {
+	index, err := loadersFrom(ctx).groupIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	group, ok := index.byID[id]
+	if !ok {
+		return nil, nil
+	}
+	return &graphGroup{group}, nil
+}
+
+func (r *graphGroup) ID() int32            { return int32(r.g.ID) }
+func (r *graphGroup) Name() string         { return r.g.Name }
+func (r *graphGroup) Description() *string { return optString(r.g.Description) }
+
+func (r *graphGroup) Parent(ctx context.Context) (*graphGroup, error) {
+	if r.g.ParentID == nil {
+		return nil, nil
+	}
+	return groupByID(ctx, *r.g.ParentID)
+}
+
+// Members and Managers leave out the users outside the caller's scope.
+func (r *graphGroup) Members(ctx context.Context) (*[]*graphUser, error) {
+	return groupUsers(ctx, r.g.Members)
+}
+
+func (r *graphGroup) Managers(ctx context.Context) (*[]*graphUser, error) {
+	return groupUsers(ctx, r.g.Managers)
+}
+
+func groupUsers(ctx context.Context, names []string) (*[]*graphUser, error) {
+	index, err := loadersFrom(ctx).userIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*graphUser, 0, len(names))
+	for _, name := range names {
+		if user, ok := index.byName[name]; ok {
+			users = append(users, &graphUser{user})
+		}
+	}
+	return &users, nil
+}
+
+func (r *graphGroup) Roles() []*graphRole {
+	roles := make([]*graphRole, len(r.g.Roles))
+	for i, role := range r.g.Roles {
+		roles[i] = &graphRole{role: role}
+	}
+	return roles
+}
+
+type graphAuditEvent struct {
+	event app.AuditEvent
+}
+
+func graphAuditEvents(events []app.AuditEvent) *[]*graphAuditEvent {
+	resolvers := make([]*graphAuditEvent, len(events))
+	for i, event := range events {
+		resolvers[i] = &graphAuditEvent{event}
+	}
+	return &resolvers
+}
+
+func (r *graphAuditEvent) ID() string                { return strconv.FormatInt(r.event.ID, 10) }
+func (r *graphAuditEvent) CreateTime() *graphql.Time { return optTime(r.event.CreateTime) }
+func (r *graphAuditEvent) Actor() string             { return r.event.Actor }
+func (r *graphAuditEvent) Action() string            { return r.event.Action }
+func (r *graphAuditEvent) UserName() *string         { return optString(r.event.UserName) }
+
+// User is nil for users outside the caller's scope and for deleted users.
+func (r *graphAuditEvent) User(ctx context.Context) (*graphUser, error) {
+	if r.event.UserName == "" {
+		return nil, nil
+	}
+	index, err := loadersFrom(ctx).userIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, ok := index.byName[r.event.UserName]
+	if !ok {
+		return nil, nil
+	}
+	return &graphUser{user}, nil
+}
+
+func (r *graphAuditEvent) Details() *graphJSON {
+	if r.event.Details == nil {
+		return nil
+	}
+	return &graphJSON{r.event.Details}
+}
+
+// graphJSON is the JSON scalar of schema.graphql.
+type graphJSON struct {
+	value interface{}
+}
+
+func (graphJSON) ImplementsGraphQLType(name string) bool { return name == "JSON" }
+
+func (j *graphJSON) UnmarshalGraphQL(input interface{}) error {
+	j.value = input
+	return nil
+}
+
+func (j graphJSON) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.value)
+}
+
+func optString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func optTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
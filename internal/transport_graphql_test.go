@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// complexityItem resolves the items of the schema in TestComplexityTracer and counts the
+// names it resolves.
+type complexityItem struct {
+	names *int64
+}
+
+func (i *complexityItem) Items(args struct{ Limit int32 }) []*complexityItem {
+	items := make([]*complexityItem, args.Limit)
+	for n := range items {
+		items[n] = i
+	}
+	return items
+}
+
+func (i *complexityItem) Name() string {
+	atomic.AddInt64(i.names, 1)
+	return "item"
+}
+
+func (i *complexityItem) Children() []*complexityItem {
+	return []*complexityItem{i, i, i}
+}
+
+func TestComplexityTracer(t *testing.T) {
+	names := new(int64)
+	schema := graphql.MustParseSchema(`
+		type Query { items(limit: Int = 10): [Item!]! }
+		type Item { name: String! children: [Item!]! }`,
+		&complexityItem{names}, graphql.Tracer(complexityTracer{20}))
+
+	for _, tc := range []struct {
+		name, query string
+		exceeded    bool
+		names       int64 // resolved names, at most when exceeded
+	}{
+		{"under the limit", `{ items(limit: 3) { name } }`, false, 3},
+		{"at the limit", `{ items(limit: 19) { name } }`, false, 19},
+		{"default length", `{ items { name } }`, false, 10},
+		{"list past the limit", `{ items(limit: 30) { name } }`, true, 19},
+		{"nested lists", `{ items(limit: 5) { children { children { name } } } }`, true, 19},
+		{"introspection is free", `{ __schema { types { name fields { name } } } items(limit: 19) { name } }`, false, 19},
+	} {
+		atomic.StoreInt64(names, 0)
+		response := schema.Exec(context.Background(), tc.query, "", nil)
+		for _, err := range response.Errors {
+			if !strings.Contains(err.Message, "query complexity exceeds the limit of 20") {
+				t.Errorf("%s: error %v", tc.name, err)
+			}
+		}
+		resolved := atomic.LoadInt64(names)
+		if exceeded := len(response.Errors) != 0; exceeded != tc.exceeded || resolved > tc.names ||
+			(!exceeded && resolved != tc.names) {
+			t.Errorf("%s: exceeded %v with %d names resolved, want %v and %d", tc.name, exceeded,
+				resolved, tc.exceeded, tc.names)
+		}
+	}
+}
+
+// TestGraphQLRejectsAnonymousCallers runs queries and mutations without a token, every field
+// is refused before it reaches the service.
+func TestGraphQLRejectsAnonymousCallers(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	h := makeGraphQLHandler(MakeServerEndpoints(nil), UnitLogHandler{logger})
+
+	for _, query := range []string{
+		`{ roles { id } groups { id } }`,
+		`mutation { deleteGroup(id: 1) }`,
+		`mutation { updateRole(id: 2, role: {name: "user", permissions: ["users.write"]}) }`,
+	} {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+		var response struct {
+			Errors []struct {
+				Extensions struct {
+					Status float64 `json:"status"`
+				} `json:"extensions"`
+			} `json:"errors"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if len(response.Errors) == 0 {
+			t.Errorf("%s: no errors", query)
+		}
+		for _, err := range response.Errors {
+			if err.Extensions.Status != http.StatusPreconditionRequired {
+				t.Errorf("%s: status %v, want %d", query, err.Extensions.Status, http.StatusPreconditionRequired)
+			}
+		}
+	}
+}
@@ -0,0 +1,1050 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testgenerate_backend_user/internal/app"
+	"testgenerate_backend_user/internal/pb"
+	"time"
+)
+
+// MakeGRPCServer serves the endpoints of the HTTP API as the UserService of user.proto, next to
+// the standard health service and server reflection. An RPC is decoded by the decoder of its
+// /v1 route, so both transports authorize the same way, and the endpoints refuse callers without
+// a valid token whatever the transport. Every RPC is logged and counted in
+// requestCount and requestLatency by its full method name and status code.
+func MakeGRPCServer(s Service, logger UnitLogHandler, requestCount metrics.Counter,
+	requestLatency metrics.Histogram) *grpc.Server {
+	e := MakeServerEndpoints(s)
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorHandler(logger),
+		grpctransport.ServerBefore(grpcAuthenticate),
+	}
+	handler := func(e endpoint.Endpoint, decode grpctransport.DecodeRequestFunc,
+		reply func() proto.Message) grpctransport.Handler {
+		return grpctransport.NewServer(e, decode, encodeGRPCResponse(reply), options...)
+	}
+	empty := func() proto.Message { return &emptypb.Empty{} }
+
+	srv := &grpcServer{
+		getRoles: handler(e.getRolesEndpoint, decodeGRPCRolesRequest,
+			func() proto.Message { return &pb.GetRolesResponse{} }),
+		getRolePermissions: handler(e.GetRolePermissionsEndpoint, decodeGRPCRolePermissionsRequest,
+			func() proto.Message { return &pb.GetRolePermissionsResponse{} }),
+		putRole: handler(e.PutRoleEndpoint, decodeGRPCPutRoleRequest, empty),
+
+		getUser: handler(e.GetUserEndpoint, decodeGRPCUserRequest,
+			func() proto.Message { return &pb.UserResponse{} }),
+		listUsers: handler(e.GetUsersRoleEndpoint, decodeGRPCListUsersRequest,
+			func() proto.Message { return &pb.UsersResponse{} }),
+		importUsers: handler(e.ImportUsersEndpoint, decodeGRPCImportUsersRequest,
+			func() proto.Message { return &pb.ImportUsersResponse{} }),
+		exportUsers: handler(e.ExportUsersEndpoint, decodeGRPCExportUsersRequest,
+			func() proto.Message { return &pb.ExportUsersResponse{} }),
+		batchUsers: handler(e.BatchUsersEndpoint, decodeGRPCBatchUsersRequest,
+			func() proto.Message { return &pb.BatchUsersResponse{} }),
+		planRoles: handler(e.PlanRolesEndpoint, makeDecodeGRPCRolePlanRequest(false, PermUsersRead, PermRolesRead),
+			func() proto.Message { return &pb.RolePlanResponse{} }),
+		applyRoles: handler(e.ApplyRolesEndpoint, makeDecodeGRPCRolePlanRequest(true, PermUsersWrite, PermRolesWrite),
+			func() proto.Message { return &pb.RolePlanResponse{} }),
+		getRoleExpirations: handler(e.GetRoleExpirationsEndpoint, decodeGRPCRoleExpirationsRequest,
+			func() proto.Message { return &pb.UsersResponse{} }),
+		addUser:    handler(e.PostUserEndpoint, decodeGRPCAddUserRequest, empty),
+		updateUser: handler(e.PutUserEndpoint, decodeGRPCUpdateUserRequest, empty),
+		patchUser: handler(e.PatchUserEndpoint, decodeGRPCPatchUserRequest,
+			func() proto.Message { return &pb.UserResponse{} }),
+		deleteUser: handler(e.DeleteUserEndpoint, decodeGRPCDeleteUserRequest, empty),
+		login: handler(e.PostLoginEndpoint, decodeGRPCLoginRequest,
+			func() proto.Message { return &pb.UserResponse{} }),
+		disableUser: handler(e.PostUserStatusEndpoint, makeDecodeGRPCUserStatusRequest(app.UserStatusDisabled), empty),
+		enableUser:  handler(e.PostUserStatusEndpoint, makeDecodeGRPCUserStatusRequest(app.UserStatusActive), empty),
+		lockUser:    handler(e.PostUserStatusEndpoint, makeDecodeGRPCUserStatusRequest(app.UserStatusLocked), empty),
+		unlockUser:  handler(e.PostUserStatusEndpoint, makeDecodeGRPCUserStatusRequest(app.UserStatusActive), empty),
+		restoreUser: handler(e.PostRestoreEndpoint, decodeGRPCRestoreRequest, empty),
+		renameUser: handler(e.PostRenameEndpoint, decodeGRPCRenameRequest,
+			func() proto.Message { return &pb.UserResponse{} }),
+		grantRole:  handler(e.PostUserRoleEndpoint, decodeGRPCGrantRoleRequest, empty),
+		revokeRole: handler(e.DeleteUserRoleEndpoint, decodeGRPCRevokeRoleRequest, empty),
+
+		getGroups: handler(e.GetGroupsEndpoint, decodeGRPCGroupsRequest,
+			func() proto.Message { return &pb.GetGroupsResponse{} }),
+		createGroup: handler(e.PostGroupEndpoint, decodeGRPCCreateGroupRequest,
+			func() proto.Message { return &pb.GroupResponse{} }),
+		updateGroup:        handler(e.PutGroupEndpoint, decodeGRPCUpdateGroupRequest, empty),
+		deleteGroup:        handler(e.DeleteGroupEndpoint, decodeGRPCDeleteGroupRequest, empty),
+		addGroupMember:     handler(e.PostGroupMemberEndpoint, decodeGRPCAddGroupMemberRequest, empty),
+		removeGroupMember:  handler(e.DeleteGroupMemberEndpoint, decodeGRPCRemoveGroupMemberRequest, empty),
+		addGroupManager:    handler(e.PostGroupManagerEndpoint, decodeGRPCAddGroupMemberRequest, empty),
+		removeGroupManager: handler(e.DeleteGroupManagerEndpoint, decodeGRPCRemoveGroupMemberRequest, empty),
+		grantGroupRole:     handler(e.PostGroupRoleEndpoint, decodeGRPCGrantGroupRoleRequest, empty),
+		revokeGroupRole:    handler(e.DeleteGroupRoleEndpoint, decodeGRPCRevokeGroupRoleRequest, empty),
+
+		createInvitation: handler(e.PostInvitationEndpoint, decodeGRPCCreateInvitationRequest,
+			func() proto.Message { return &pb.InvitationResponse{} }),
+		getInvitations: handler(e.GetInvitationsEndpoint, decodeGRPCInvitationsRequest,
+			func() proto.Message { return &pb.GetInvitationsResponse{} }),
+		revokeInvitation: handler(e.DeleteInvitationEndpoint, decodeGRPCRevokeInvitationRequest, empty),
+
+		getOrganizations: handler(e.GetOrganizationsEndpoint, decodeGRPCOrganizationsRequest,
+			func() proto.Message { return &pb.GetOrganizationsResponse{} }),
+		createOrganization: handler(e.PostOrganizationEndpoint, decodeGRPCCreateOrganizationRequest,
+			func() proto.Message { return &pb.OrganizationResponse{} }),
+
+		getAuditEvents: handler(e.GetAuditEventsEndpoint, decodeGRPCAuditEventsRequest,
+			func() proto.Message { return &pb.GetAuditEventsResponse{} }),
+	}
+
+	//The decoders limit the bodies of their routes again, imports to IMPORT_MAX_BYTES
+	maxMessage := maxBodyBytes()
+	if maxImportBytes() > maxMessage {
+		maxMessage = maxImportBytes()
+	}
+	server := grpc.NewServer(
+		grpc.MaxRecvMsgSize(int(maxMessage)),
+		grpc.UnaryInterceptor(grpcInstrumenting(logger.logger, requestCount, requestLatency)),
+	)
+	pb.RegisterUserServiceServer(server, srv)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(pb.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+	return server
+}
+
+// grpcAuthenticate authenticates the caller from the "authorization" and "x-tenant-id" metadata
+// like authenticate does from the headers.
+func grpcAuthenticate(ctx context.Context, md metadata.MD) context.Context {
+	r := &http.Request{Header: http.Header{}}
+	for _, key := range []string{"Authorization", "X-Tenant-ID"} {
+		if values := md.Get(key); len(values) > 0 {
+			r.Header.Set(key, values[0])
+		}
+	}
+	return authenticate(ctx, r)
+}
+
+// grpcInstrumenting logs and counts RPCs like the logging and instrumenting middlewares do the
+// methods of Service, including RPCs that fail in authentication or decoding.
+func grpcInstrumenting(logger *logrus.Logger, requestCount metrics.Counter,
+	requestLatency metrics.Histogram) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func(begin time.Time) {
+			code := status.Code(err).String()
+			logger.WithFields(logrus.Fields{
+				"took":  time.Since(begin).Milliseconds(),
+				"code":  code,
+				"error": err,
+			}).Info("rpc == " + info.FullMethod)
+			lvs := []string{"method", info.FullMethod, "code", code}
+			requestCount.With(lvs...).Add(1)
+			requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+		}(time.Now())
+		return handler(ctx, req)
+	}
+}
+
+// grpcError maps the errors of the service to gRPC status codes, like codeFrom does to HTTP ones.
+// Fields of a ValidationError are sent as the field violations of a BadRequest detail.
+func grpcError(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, ErrAlreadyExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, ErrInconsistentIDs), errors.Is(err, ErrInvalidArgument), errors.Is(err, ErrValidation),
+		errors.Is(err, ErrIdempotencyKeyReused):
+		code = codes.InvalidArgument
+	case errors.Is(err, ErrInvitationInvalid), errors.Is(err, ErrLastAdministrator):
+		code = codes.FailedPrecondition
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrAccountDisabled):
+		code = codes.PermissionDenied
+	case errors.Is(err, ErrRequestTooLarge):
+		code = codes.ResourceExhausted
+	case errors.Is(err, ErrIdempotencyInProgress):
+		code = codes.Aborted
+	case errors.Is(err, ErrPreconditionRequired):
+		code = codes.Unauthenticated
+	default:
+		code = codes.Internal
+	}
+	st := status.New(code, err.Error())
+
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		var violations errdetails.BadRequest
+		for _, f := range invalid.Fields {
+			violations.FieldViolations = append(violations.FieldViolations,
+				&errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Reason})
+		}
+		if detailed, errDetails := st.WithDetails(&violations); errDetails == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+// encodeGRPCResponse converts the response of an endpoint into the reply message through its
+// JSON form, the messages are named like the JSON bodies. Streamed exports are collected first.
+func encodeGRPCResponse(reply func() proto.Message) grpctransport.EncodeResponseFunc {
+	return func(_ context.Context, response interface{}) (interface{}, error) {
+		if e, ok := response.(errorer); ok && e.error() != nil {
+			return nil, e.error()
+		}
+		if stream, ok := response.(streamUsersResponse); ok {
+			records := []app.UserRecord{}
+			err := stream.Stream(func(user app.User) error {
+				records = append(records, userRecordOf(user))
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			response = map[string]interface{}{"records": records}
+		}
+
+		body, err := json.Marshal(response)
+		if err != nil {
+			return nil, err
+		}
+		m := reply()
+		if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, m); err != nil {
+			return nil, fmt.Errorf("encode %T: %v", m, err)
+		}
+		return m, nil
+	}
+}
+
+// routeRequest builds the HTTP request of the route an RPC stands for. Proto messages are sent
+// as their JSON form with the field names of user.proto, other bodies as JSON.
+func routeRequest(ctx context.Context, method string, vars map[string]string, query url.Values,
+	body interface{}) (*http.Request, error) {
+	var data []byte
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		data = b
+	case proto.Message:
+		var err error
+		if data, err = (protojson.MarshalOptions{UseProtoNames: true}).Marshal(b); err != nil {
+			return nil, fmt.Errorf("request: %v: %w", err, ErrInvalidArgument)
+		}
+	default:
+		var err error
+		if data, err = json.Marshal(b); err != nil {
+			return nil, fmt.Errorf("request: %v: %w", err, ErrInvalidArgument)
+		}
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, "/?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	return mux.SetURLVars(r, vars), nil
+}
+
+func forceQuery(force bool) url.Values {
+	return url.Values{"force": {strconv.FormatBool(force)}}
+}
+
+func itoa(i int32) string {
+	return strconv.Itoa(int(i))
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+func decodeGRPCRolesRequest(ctx context.Context, _ interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodGet, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRolesRequest(ctx, r)
+}
+
+func decodeGRPCRolePermissionsRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.RoleRequest)
+	r, err := routeRequest(ctx, http.MethodGet, map[string]string{"id": itoa(req.Id)}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRolePermissionsRequest(ctx, r)
+}
+
+func decodeGRPCPutRoleRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.PutRoleRequest)
+	r, err := routeRequest(ctx, http.MethodPut, map[string]string{"id": itoa(req.Id)}, nil, req.GetRole())
+	if err != nil {
+		return nil, err
+	}
+	return decodePutRoleRequest(ctx, r)
+}
+
+func decodeGRPCUserRequest(ctx context.Context, _ interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodGet, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeUserRequest(ctx, r)
+}
+
+func decodeGRPCListUsersRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.ListUsersRequest)
+	query := url.Values{"include_deleted": {strconv.FormatBool(req.IncludeDeleted)}}
+	r, err := routeRequest(ctx, http.MethodGet, nil, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeUsersRoleRequest(ctx, r)
+}
+
+// decodeGRPCImportUsersRequest sends the records as an NDJSON file.
+func decodeGRPCImportUsersRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.ImportUsersRequest)
+	var file bytes.Buffer
+	for _, record := range req.Records {
+		line, err := (protojson.MarshalOptions{UseProtoNames: true}).Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("records: %v: %w", err, ErrInvalidArgument)
+		}
+		file.Write(line)
+		file.WriteByte('\n')
+	}
+	query := url.Values{
+		"dry_run":    {strconv.FormatBool(req.DryRun)},
+		"mode":       {req.Mode},
+		"chunk_size": {itoa(req.ChunkSize)},
+	}
+	r, err := routeRequest(ctx, http.MethodPost, nil, query, file.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	return decodeImportUsersRequest(ctx, r)
+}
+
+func decodeGRPCExportUsersRequest(ctx context.Context, _ interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodGet, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Accept", "application/x-ndjson")
+	return decodeExportUsersRequest(ctx, r)
+}
+
+func decodeGRPCBatchUsersRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.BatchUsersRequest)
+	r, err := routeRequest(ctx, http.MethodPost, nil, forceQuery(req.Force), req.GetBatch())
+	if err != nil {
+		return nil, err
+	}
+	return decodeBatchUsersRequest(ctx, r)
+}
+
+func makeDecodeGRPCRolePlanRequest(apply bool, permissions ...string) grpctransport.DecodeRequestFunc {
+	decode := makeDecodeRolePlanRequest(apply, permissions...)
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		r, err := routeRequest(ctx, http.MethodPost, nil, nil, request.(*pb.DesiredRoles))
+		if err != nil {
+			return nil, err
+		}
+		return decode(ctx, r)
+	}
+}
+
+// decodeGRPCRoleExpirationsRequest looks 7 days ahead like the route when days is not set.
+func decodeGRPCRoleExpirationsRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.GetRoleExpirationsRequest)
+	query := url.Values{}
+	if req.Days != 0 {
+		query.Set("days", itoa(req.Days))
+	}
+	r, err := routeRequest(ctx, http.MethodGet, nil, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRoleExpirationsRequest(ctx, r)
+}
+
+// decodeGRPCAddUserRequest sends invite_code next to the fields of the user, like POST /v1/user.
+func decodeGRPCAddUserRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.AddUserRequest)
+	user, err := (protojson.MarshalOptions{UseProtoNames: true}).Marshal(req.GetUser())
+	if err != nil {
+		return nil, fmt.Errorf("user: %v: %w", err, ErrInvalidArgument)
+	}
+	body := map[string]json.RawMessage{}
+	if err = json.Unmarshal(user, &body); err != nil {
+		return nil, err
+	}
+	if req.InviteCode != "" {
+		body["invite_code"], _ = json.Marshal(req.InviteCode)
+	}
+	r, err := routeRequest(ctx, http.MethodPost, nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	return decodePostUserRequest(ctx, r)
+}
+
+func decodeGRPCUpdateUserRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.UpdateUserRequest)
+	r, err := routeRequest(ctx, http.MethodPut, nil, forceQuery(req.Force), req.GetUser())
+	if err != nil {
+		return nil, err
+	}
+	return decodePutRequest(ctx, r)
+}
+
+// decodeGRPCPatchUserRequest sends merge_patch as application/merge-patch+json
+// and json_patch as application/json-patch+json.
+func decodeGRPCPatchUserRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.PatchUserRequest)
+	var (
+		body        interface{}
+		contentType string
+	)
+	switch patch := req.Patch.(type) {
+	case *pb.PatchUserRequest_MergePatch:
+		body, contentType = patch.MergePatch, "application/merge-patch+json"
+	case *pb.PatchUserRequest_JsonPatch:
+		operations := []app.JSONPatchOperation{}
+		for _, op := range patch.JsonPatch.GetOperations() {
+			operation := app.JSONPatchOperation{Op: op.Op, Path: op.Path}
+			if op.Value != nil {
+				value, err := protojson.Marshal(op.Value)
+				if err != nil {
+					return nil, fmt.Errorf("json_patch: %v: %w", err, ErrInvalidArgument)
+				}
+				operation.Value = value
+			}
+			operations = append(operations, operation)
+		}
+		body, contentType = operations, "application/json-patch+json"
+	default:
+		return nil, fmt.Errorf("merge_patch or json_patch is required: %w", ErrInvalidArgument)
+	}
+
+	r, err := routeRequest(ctx, http.MethodPatch, map[string]string{"user": req.UserName}, forceQuery(req.Force), body)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", contentType)
+	return decodePatchUserRequest(ctx, r)
+}
+
+func decodeGRPCDeleteUserRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.UserRequest)
+	r, err := routeRequest(ctx, http.MethodDelete, map[string]string{"user": req.UserName}, forceQuery(req.Force), nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeleteRequest(ctx, r)
+}
+
+func decodeGRPCLoginRequest(ctx context.Context, _ interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodPost, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLoginRequest(ctx, r)
+}
+
+func makeDecodeGRPCUserStatusRequest(status string) grpctransport.DecodeRequestFunc {
+	decode := makeDecodeUserStatusRequest(status)
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*pb.UserStatusRequest)
+		change := app.StatusChange{Reason: req.Reason}
+		if req.Until != nil {
+			until := req.Until.AsTime()
+			change.Until = &until
+		}
+		r, err := routeRequest(ctx, http.MethodPost, map[string]string{"user": req.UserName}, forceQuery(req.Force), change)
+		if err != nil {
+			return nil, err
+		}
+		return decode(ctx, r)
+	}
+}
+
+func decodeGRPCRestoreRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.UserRequest)
+	r, err := routeRequest(ctx, http.MethodPost, map[string]string{"user": req.UserName}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRestoreRequest(ctx, r)
+}
+
+func decodeGRPCRenameRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.RenameUserRequest)
+	body := map[string]string{"user_name": req.NewName}
+	r, err := routeRequest(ctx, http.MethodPost, map[string]string{"user": req.UserName}, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRenameRequest(ctx, r)
+}
+
+func decodeGRPCGrantRoleRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.GrantRoleRequest)
+	r, err := routeRequest(ctx, http.MethodPost, map[string]string{"user": req.UserName}, nil, req.GetRole())
+	if err != nil {
+		return nil, err
+	}
+	return decodePostUserRoleRequest(ctx, r)
+}
+
+func decodeGRPCRevokeRoleRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.RevokeRoleRequest)
+	vars := map[string]string{"user": req.UserName, "role": itoa(req.RoleId)}
+	r, err := routeRequest(ctx, http.MethodDelete, vars, forceQuery(req.Force), nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeleteUserRoleRequest(ctx, r)
+}
+
+func decodeGRPCGroupsRequest(ctx context.Context, _ interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodGet, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGroupsRequest(ctx, r)
+}
+
+func decodeGRPCCreateGroupRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodPost, nil, nil, request.(*pb.Group))
+	if err != nil {
+		return nil, err
+	}
+	return decodePostGroupRequest(ctx, r)
+}
+
+func decodeGRPCUpdateGroupRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.UpdateGroupRequest)
+	r, err := routeRequest(ctx, http.MethodPut, map[string]string{"id": itoa(req.Id)}, nil, req.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	return decodePutGroupRequest(ctx, r)
+}
+
+func decodeGRPCDeleteGroupRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.GroupRequest)
+	r, err := routeRequest(ctx, http.MethodDelete, map[string]string{"id": itoa(req.Id)}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeleteGroupRequest(ctx, r)
+}
+
+func decodeGRPCAddGroupMemberRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.GroupMemberRequest)
+	body := map[string]string{"user_name": req.UserName}
+	r, err := routeRequest(ctx, http.MethodPost, map[string]string{"id": itoa(req.Id)}, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	return decodePostGroupMemberRequest(ctx, r)
+}
+
+func decodeGRPCRemoveGroupMemberRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.GroupMemberRequest)
+	vars := map[string]string{"id": itoa(req.Id), "user": req.UserName}
+	r, err := routeRequest(ctx, http.MethodDelete, vars, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeleteGroupMemberRequest(ctx, r)
+}
+
+func decodeGRPCGrantGroupRoleRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.GrantGroupRoleRequest)
+	r, err := routeRequest(ctx, http.MethodPost, map[string]string{"id": itoa(req.Id)}, nil, req.GetRole())
+	if err != nil {
+		return nil, err
+	}
+	return decodePostGroupRoleRequest(ctx, r)
+}
+
+func decodeGRPCRevokeGroupRoleRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.RevokeGroupRoleRequest)
+	vars := map[string]string{"id": itoa(req.Id), "role": itoa(req.RoleId)}
+	r, err := routeRequest(ctx, http.MethodDelete, vars, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeleteGroupRoleRequest(ctx, r)
+}
+
+func decodeGRPCCreateInvitationRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodPost, nil, nil, request.(*pb.Invitation))
+	if err != nil {
+		return nil, err
+	}
+	return decodePostInvitationRequest(ctx, r)
+}
+
+func decodeGRPCInvitationsRequest(ctx context.Context, _ interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodGet, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeInvitationsRequest(ctx, r)
+}
+
+func decodeGRPCRevokeInvitationRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.InvitationRequest)
+	r, err := routeRequest(ctx, http.MethodDelete, map[string]string{"id": itoa(req.Id)}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeleteInvitationRequest(ctx, r)
+}
+
+func decodeGRPCOrganizationsRequest(ctx context.Context, _ interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodGet, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeOrganizationsRequest(ctx, r)
+}
+
+func decodeGRPCCreateOrganizationRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	r, err := routeRequest(ctx, http.MethodPost, nil, nil, request.(*pb.Organization))
+	if err != nil {
+		return nil, err
+	}
+	return decodePostOrganizationRequest(ctx, r)
+}
+
+// decodeGRPCAuditEventsRequest authorizes like the auditEvents fields of /graphql,
+// team leads read the events about the members of their teams.
+func decodeGRPCAuditEventsRequest(ctx context.Context, request interface{}) (interface{}, error) {
+	req := request.(*pb.GetAuditEventsRequest)
+	scope, err := auditScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+	limit, err := auditLimit(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	filter := app.AuditFilter{ManagedBy: scope.Lead, Limit: limit}
+	for _, name := range req.UserNames {
+		name, err = resolveUserName(ctx, normalizeUserName(name))
+		if err != nil {
+			return nil, err
+		}
+		filter.UserNames = append(filter.UserNames, name)
+	}
+	return getAuditEventsRequest{filter}, nil
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+// grpcServer implements pb.UserServiceServer with a go-kit handler per RPC.
+type grpcServer struct {
+	pb.UnimplementedUserServiceServer
+
+	getRoles           grpctransport.Handler
+	getRolePermissions grpctransport.Handler
+	putRole            grpctransport.Handler
+	getUser            grpctransport.Handler
+	listUsers          grpctransport.Handler
+	importUsers        grpctransport.Handler
+	exportUsers        grpctransport.Handler
+	batchUsers         grpctransport.Handler
+	planRoles          grpctransport.Handler
+	applyRoles         grpctransport.Handler
+	getRoleExpirations grpctransport.Handler
+	addUser            grpctransport.Handler
+	updateUser         grpctransport.Handler
+	patchUser          grpctransport.Handler
+	deleteUser         grpctransport.Handler
+	login              grpctransport.Handler
+	disableUser        grpctransport.Handler
+	enableUser         grpctransport.Handler
+	lockUser           grpctransport.Handler
+	unlockUser         grpctransport.Handler
+	restoreUser        grpctransport.Handler
+	renameUser         grpctransport.Handler
+	grantRole          grpctransport.Handler
+	revokeRole         grpctransport.Handler
+
+	getGroups          grpctransport.Handler
+	createGroup        grpctransport.Handler
+	updateGroup        grpctransport.Handler
+	deleteGroup        grpctransport.Handler
+	addGroupMember     grpctransport.Handler
+	removeGroupMember  grpctransport.Handler
+	addGroupManager    grpctransport.Handler
+	removeGroupManager grpctransport.Handler
+	grantGroupRole     grpctransport.Handler
+	revokeGroupRole    grpctransport.Handler
+
+	createInvitation grpctransport.Handler
+	getInvitations   grpctransport.Handler
+	revokeInvitation grpctransport.Handler
+
+	getOrganizations   grpctransport.Handler
+	createOrganization grpctransport.Handler
+
+	getAuditEvents grpctransport.Handler
+}
+
+// serve runs the handler of an RPC and maps its error to a status.
+func serve(ctx context.Context, h grpctransport.Handler, req interface{}) (interface{}, error) {
+	_, resp, err := h.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) GetRoles(ctx context.Context, req *emptypb.Empty) (*pb.GetRolesResponse, error) {
+	resp, err := serve(ctx, s.getRoles, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.GetRolesResponse), nil
+}
+
+func (s *grpcServer) GetRolePermissions(ctx context.Context, req *pb.RoleRequest) (*pb.GetRolePermissionsResponse, error) {
+	resp, err := serve(ctx, s.getRolePermissions, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.GetRolePermissionsResponse), nil
+}
+
+func (s *grpcServer) PutRole(ctx context.Context, req *pb.PutRoleRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.putRole, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) GetUser(ctx context.Context, req *emptypb.Empty) (*pb.UserResponse, error) {
+	resp, err := serve(ctx, s.getUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.UserResponse), nil
+}
+
+func (s *grpcServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.UsersResponse, error) {
+	resp, err := serve(ctx, s.listUsers, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.UsersResponse), nil
+}
+
+func (s *grpcServer) ImportUsers(ctx context.Context, req *pb.ImportUsersRequest) (*pb.ImportUsersResponse, error) {
+	resp, err := serve(ctx, s.importUsers, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.ImportUsersResponse), nil
+}
+
+func (s *grpcServer) ExportUsers(ctx context.Context, req *emptypb.Empty) (*pb.ExportUsersResponse, error) {
+	resp, err := serve(ctx, s.exportUsers, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.ExportUsersResponse), nil
+}
+
+func (s *grpcServer) BatchUsers(ctx context.Context, req *pb.BatchUsersRequest) (*pb.BatchUsersResponse, error) {
+	resp, err := serve(ctx, s.batchUsers, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.BatchUsersResponse), nil
+}
+
+func (s *grpcServer) PlanRoles(ctx context.Context, req *pb.DesiredRoles) (*pb.RolePlanResponse, error) {
+	resp, err := serve(ctx, s.planRoles, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.RolePlanResponse), nil
+}
+
+func (s *grpcServer) ApplyRoles(ctx context.Context, req *pb.DesiredRoles) (*pb.RolePlanResponse, error) {
+	resp, err := serve(ctx, s.applyRoles, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.RolePlanResponse), nil
+}
+
+func (s *grpcServer) GetRoleExpirations(ctx context.Context, req *pb.GetRoleExpirationsRequest) (*pb.UsersResponse, error) {
+	resp, err := serve(ctx, s.getRoleExpirations, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.UsersResponse), nil
+}
+
+func (s *grpcServer) AddUser(ctx context.Context, req *pb.AddUserRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.addUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.updateUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) PatchUser(ctx context.Context, req *pb.PatchUserRequest) (*pb.UserResponse, error) {
+	resp, err := serve(ctx, s.patchUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.UserResponse), nil
+}
+
+func (s *grpcServer) DeleteUser(ctx context.Context, req *pb.UserRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.deleteUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) Login(ctx context.Context, req *emptypb.Empty) (*pb.UserResponse, error) {
+	resp, err := serve(ctx, s.login, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.UserResponse), nil
+}
+
+func (s *grpcServer) DisableUser(ctx context.Context, req *pb.UserStatusRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.disableUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) EnableUser(ctx context.Context, req *pb.UserStatusRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.enableUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) LockUser(ctx context.Context, req *pb.UserStatusRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.lockUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) UnlockUser(ctx context.Context, req *pb.UserStatusRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.unlockUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) RestoreUser(ctx context.Context, req *pb.UserRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.restoreUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) RenameUser(ctx context.Context, req *pb.RenameUserRequest) (*pb.UserResponse, error) {
+	resp, err := serve(ctx, s.renameUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.UserResponse), nil
+}
+
+func (s *grpcServer) GrantRole(ctx context.Context, req *pb.GrantRoleRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.grantRole, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) RevokeRole(ctx context.Context, req *pb.RevokeRoleRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.revokeRole, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) GetGroups(ctx context.Context, req *emptypb.Empty) (*pb.GetGroupsResponse, error) {
+	resp, err := serve(ctx, s.getGroups, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.GetGroupsResponse), nil
+}
+
+func (s *grpcServer) CreateGroup(ctx context.Context, req *pb.Group) (*pb.GroupResponse, error) {
+	resp, err := serve(ctx, s.createGroup, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.GroupResponse), nil
+}
+
+func (s *grpcServer) UpdateGroup(ctx context.Context, req *pb.UpdateGroupRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.updateGroup, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) DeleteGroup(ctx context.Context, req *pb.GroupRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.deleteGroup, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) AddGroupMember(ctx context.Context, req *pb.GroupMemberRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.addGroupMember, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) RemoveGroupMember(ctx context.Context, req *pb.GroupMemberRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.removeGroupMember, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) AddGroupManager(ctx context.Context, req *pb.GroupMemberRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.addGroupManager, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) RemoveGroupManager(ctx context.Context, req *pb.GroupMemberRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.removeGroupManager, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) GrantGroupRole(ctx context.Context, req *pb.GrantGroupRoleRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.grantGroupRole, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) RevokeGroupRole(ctx context.Context, req *pb.RevokeGroupRoleRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.revokeGroupRole, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) CreateInvitation(ctx context.Context, req *pb.Invitation) (*pb.InvitationResponse, error) {
+	resp, err := serve(ctx, s.createInvitation, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.InvitationResponse), nil
+}
+
+func (s *grpcServer) GetInvitations(ctx context.Context, req *emptypb.Empty) (*pb.GetInvitationsResponse, error) {
+	resp, err := serve(ctx, s.getInvitations, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.GetInvitationsResponse), nil
+}
+
+func (s *grpcServer) RevokeInvitation(ctx context.Context, req *pb.InvitationRequest) (*emptypb.Empty, error) {
+	resp, err := serve(ctx, s.revokeInvitation, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*emptypb.Empty), nil
+}
+
+func (s *grpcServer) GetOrganizations(ctx context.Context, req *emptypb.Empty) (*pb.GetOrganizationsResponse, error) {
+	resp, err := serve(ctx, s.getOrganizations, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.GetOrganizationsResponse), nil
+}
+
+func (s *grpcServer) CreateOrganization(ctx context.Context, req *pb.Organization) (*pb.OrganizationResponse, error) {
+	resp, err := serve(ctx, s.createOrganization, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.OrganizationResponse), nil
+}
+
+func (s *grpcServer) GetAuditEvents(ctx context.Context, req *pb.GetAuditEventsRequest) (*pb.GetAuditEventsResponse, error) {
+	resp, err := serve(ctx, s.getAuditEvents, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.GetAuditEventsResponse), nil
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"io"
+	"net"
+	"testgenerate_backend_user/internal/pb"
+	"testing"
+)
+
+func TestGRPCError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{fmt.Errorf("GetUser: user bob: %w", ErrNotFound), codes.NotFound},
+		{ErrAlreadyExists, codes.AlreadyExists},
+		{ErrInconsistentIDs, codes.InvalidArgument},
+		{fmt.Errorf("line 3: %w", ErrInvalidArgument), codes.InvalidArgument},
+		{ErrIdempotencyKeyReused, codes.InvalidArgument},
+		{&ValidationError{Fields: []FieldError{{"user_name", "is required"}}}, codes.InvalidArgument},
+		{ErrInvitationInvalid, codes.FailedPrecondition},
+		{fmt.Errorf("DeleteUser: %w", ErrLastAdministrator), codes.FailedPrecondition},
+		{ErrForbidden, codes.PermissionDenied},
+		{ErrAccountDisabled, codes.PermissionDenied},
+		{fmt.Errorf("body is larger than 10 bytes: %w", ErrRequestTooLarge), codes.ResourceExhausted},
+		{ErrIdempotencyInProgress, codes.Aborted},
+		{ErrPreconditionRequired, codes.Unauthenticated},
+		{errors.New("connection refused"), codes.Internal},
+	} {
+		st, ok := status.FromError(grpcError(tc.err))
+		if !ok {
+			t.Errorf("%v: not a status", tc.err)
+			continue
+		}
+		if st.Code() != tc.code || st.Message() != tc.err.Error() {
+			t.Errorf("%v: %s %q, want %s %q", tc.err, st.Code(), st.Message(), tc.code, tc.err.Error())
+		}
+	}
+}
+
+func TestGRPCErrorFieldViolations(t *testing.T) {
+	err := fmt.Errorf("PostUser: %w", &ValidationError{Fields: []FieldError{
+		{"user_name", "is required"},
+		{"email", "must be at most 255 characters"},
+	}})
+	st := status.Convert(grpcError(err))
+	if len(st.Details()) != 1 {
+		t.Fatalf("details %v, want one BadRequest", st.Details())
+	}
+	violations, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok {
+		t.Fatalf("detail %T, want *errdetails.BadRequest", st.Details()[0])
+	}
+	var got []FieldError
+	for _, v := range violations.FieldViolations {
+		got = append(got, FieldError{v.Field, v.Description})
+	}
+	if fmt.Sprint(got) != "[{user_name is required} {email must be at most 255 characters}]" {
+		t.Errorf("field violations %v", got)
+	}
+
+	if st = status.Convert(grpcError(ErrNotFound)); len(st.Details()) != 0 {
+		t.Errorf("details of a NotFound status: %v", st.Details())
+	}
+}
+
+// TestGRPCRejectsAnonymousCalls calls the server without a valid token, the RPCs are refused
+// before they reach the service.
+func TestGRPCRejectsAnonymousCalls(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	server := MakeGRPCServer(nil, UnitLogHandler{logger}, discard.NewCounter(), discard.NewHistogram())
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+	conn, err := grpc.Dial("bufnet", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewUserServiceClient(conn)
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		call  func(ctx context.Context) error
+	}{
+		{"ExportUsers without a token", "", func(ctx context.Context) error {
+			_, err := client.ExportUsers(ctx, &emptypb.Empty{})
+			return err
+		}},
+		{"DeleteUser without a token", "", func(ctx context.Context) error {
+			_, err := client.DeleteUser(ctx, &pb.UserRequest{UserName: "bob"})
+			return err
+		}},
+		{"PutRole with an invalid token", "Bearer invalid", func(ctx context.Context) error {
+			_, err := client.PutRole(ctx, &pb.PutRoleRequest{Id: 2, Role: &pb.Role{Permissions: []string{PermUsersWrite}}})
+			return err
+		}},
+		{"CreateOrganization with an invalid token", "Bearer invalid", func(ctx context.Context) error {
+			_, err := client.CreateOrganization(ctx, &pb.Organization{Name: "acme"})
+			return err
+		}},
+	} {
+		ctx := context.Background()
+		if tc.token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tc.token)
+		}
+		if code := status.Code(tc.call(ctx)); code != codes.Unauthenticated {
+			t.Errorf("%s: %s, want %s", tc.name, code, codes.Unauthenticated)
+		}
+	}
+}
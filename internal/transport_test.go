@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+	"time"
+)
+
+func TestDeprecatedHeaders(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	h := MakeHTTPHandler(nil, UnitLogHandler{logger}, newMemoryIdempotencyStore())
+	for _, tc := range []struct {
+		method, target string
+		link           string
+	}{
+		{"GET", "/roles", `</v1/roles>; rel="successor-version"`},
+		{"OPTIONS", "/roles", `</v1/roles>; rel="successor-version"`},
+		{"DELETE", "/user/j%C3%BCrgen%20m", `</v1/user/j%C3%BCrgen%20m>; rel="successor-version"`},
+		{"POST", "/users/import", `</v1/users/import>; rel="successor-version"`},
+		{"GET", "/v1/roles", ""},
+		{"DELETE", "/v1/user/alice", ""},
+		{"GET", "/openapi.json", ""},
+		{"GET", "/metrics", ""},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+		header := rec.Header()
+		if header.Get("Link") != tc.link {
+			t.Errorf("%s %s: Link %q, want %q", tc.method, tc.target, header.Get("Link"), tc.link)
+		}
+		deprecated := header.Get("Deprecation") != "" || header.Get("Sunset") != ""
+		if deprecated != (tc.link != "") {
+			t.Errorf("%s %s: Deprecation %q, Sunset %q", tc.method, tc.target, header.Get("Deprecation"), header.Get("Sunset"))
+		}
+		if tc.link == "" {
+			continue
+		}
+		if want := "@1792368000"; header.Get("Deprecation") != want {
+			t.Errorf("%s %s: Deprecation %q, want %q", tc.method, tc.target, header.Get("Deprecation"), want)
+		}
+		if want := "Wed, 30 Jun 2027 00:00:00 GMT"; header.Get("Sunset") != want {
+			t.Errorf("%s %s: Sunset %q, want %q", tc.method, tc.target, header.Get("Sunset"), want)
+		}
+	}
+}
+
+func TestUnversionedSunset(t *testing.T) {
+	for _, tc := range []struct {
+		env  string
+		want time.Time
+	}{
+		{"", time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)},
+		{"2027-12-31", time.Date(2027, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{"31.12.2027", time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)},
+	} {
+		t.Setenv("UNVERSIONED_SUNSET", tc.env)
+		if got := unversionedSunset(); !got.Equal(tc.want) {
+			t.Errorf("UNVERSIONED_SUNSET=%q: %v, want %v", tc.env, got, tc.want)
+		}
+	}
+}
+
+func TestStreamFormat(t *testing.T) {
+	for _, tc := range []struct {
+		accept, format string
+	}{
+		{"", ""},
+		{"application/json", ""},
+		{"*/*", ""},
+		{"text/csv", FormatCSV},
+		{"Text/CSV; charset=utf-8", FormatCSV},
+		{"application/x-ndjson", FormatNDJSON},
+		{"text/html, application/x-ndjson;q=0.9", FormatNDJSON},
+		{"application/json, text/csv", ""},
+		{"text/csv, application/json", FormatCSV},
+		{"text/html", ""},
+	} {
+		r := httptest.NewRequest("GET", "/v1/usersrole", nil)
+		r.Header.Set("Accept", tc.accept)
+		if got := streamFormat(r); got != tc.format {
+			t.Errorf("Accept %q: format %q, want %q", tc.accept, got, tc.format)
+		}
+	}
+}
+
+func TestEncodeStream(t *testing.T) {
+	users := []app.User{
+		{Name: "alice", Role: "admin", RoleID: 1, Roles: []app.Role{{ID: 1, Role: "admin"}, {ID: 3, Role: "auditor"}},
+			Email: "alice@example.com", Status: "active"},
+		{Name: "bob", Role: "user", RoleID: 2, DisplayName: `Bob "the builder", Jr.`, Locale: "de-DE"},
+	}
+	stream := func(err error, rows int) func(emit func(app.User) error) error {
+		return func(emit func(app.User) error) error {
+			for _, user := range users[:rows] {
+				if errE := emit(user); errE != nil {
+					return errE
+				}
+			}
+			return err
+		}
+	}
+	failed := fmt.Errorf("cursor: %w", ErrForbidden)
+
+	for _, tc := range []struct {
+		name        string
+		resp        streamUsersResponse
+		status      int
+		contentType string
+		body        string
+		err         bool
+	}{
+		{"ndjson users", streamUsersResponse{FormatNDJSON, false, stream(nil, 2)}, http.StatusOK, "application/x-ndjson",
+			`{"user_name":"alice","role_name":"admin","role_id":1,"email":"alice@example.com","status":"active",` +
+				`"roles":[{"id":1,"role_name":"admin"},{"id":3,"role_name":"auditor"}]}` + "\n" +
+				`{"user_name":"bob","role_name":"user","role_id":2,"display_name":"Bob \"the builder\", Jr.","locale":"de-DE"}` + "\n",
+			false},
+		{"ndjson records", streamUsersResponse{FormatNDJSON, true, stream(nil, 1)}, http.StatusOK, "application/x-ndjson",
+			`{"user_name":"alice","role_name":"admin","roles":["auditor"],"email":"alice@example.com","status":"active"}` + "\n",
+			false},
+		{"csv header and escaping", streamUsersResponse{FormatCSV, true, stream(nil, 2)}, http.StatusOK, "text/csv; charset=utf-8",
+			"user_name,role_name,roles,email,display_name,status,locale,timezone\n" +
+				"alice,admin,auditor,alice@example.com,,active,,\n" +
+				`bob,user,,,"Bob ""the builder"", Jr.",,de-DE,` + "\n",
+			false},
+		{"csv without rows", streamUsersResponse{FormatCSV, true, stream(nil, 0)}, http.StatusOK, "text/csv; charset=utf-8",
+			"user_name,role_name,roles,email,display_name,status,locale,timezone\n", false},
+		{"error before the first row", streamUsersResponse{FormatNDJSON, false, stream(failed, 0)}, http.StatusForbidden,
+			"application/json; charset=utf-8", `{"error":"cursor: role is not administrator"}` + "\n", false},
+		{"ndjson error after a row", streamUsersResponse{FormatNDJSON, true, stream(failed, 1)}, http.StatusOK, "application/x-ndjson",
+			`{"user_name":"alice","role_name":"admin","roles":["auditor"],"email":"alice@example.com","status":"active"}` + "\n" +
+				`{"error":"cursor: role is not administrator"}` + "\n",
+			true},
+	} {
+		rec := httptest.NewRecorder()
+		err := encodeStream(context.Background(), rec, tc.resp)
+		if (err != nil) != tc.err || rec.Code != tc.status || rec.Header().Get("Content-Type") != tc.contentType ||
+			rec.Body.String() != tc.body {
+			t.Errorf("%s: error %v, %d %q\n%s\nwant error %v, %d %q\n%s", tc.name, err, rec.Code,
+				rec.Header().Get("Content-Type"), rec.Body, tc.err, tc.status, tc.contentType, tc.body)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"testgenerate_backend_user/internal/app"
+)
+
+func (u userService) GrantRole(ctx context.Context, userName string, role app.Role, actor string) error {
+	var errG error
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("GrantRole. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("GrantRole conn.BeginTx %v\n", err)
+	}
+	defer func() {
+		if errG != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	if _, errG = protectAccount(ctx, tx, userName); isGuardError(errG) {
+		errG = fmt.Errorf("GrantRole: %w", errG)
+		return errG
+	}
+	if errG != nil {
+		errG = fmt.Errorf("GrantRole lock administrators: %v\n", errG)
+		return errG
+	}
+	errG = resolveRole(ctx, tx, &role)
+	if errG == nil {
+		errG = checkGrantable(role)
+	}
+	if isGuardError(errG) {
+		errG = fmt.Errorf("GrantRole: %w", errG)
+		return errG
+	}
+	if errG != nil {
+		errG = fmt.Errorf("GrantRole select role: %v\n", errG)
+		return errG
+	}
+
+	//A role of an organization is only granted to users of that organization
+	tag, errG := tx.Exec(ctx, `insert into user_roles(user_name, role)
+				select user_name, $2 from users
+				where user_name = $1 and deleted_at is null and org_visible(org_id)
+					and exists(select 1 from user_role r where r.id = $2 and (r.org_id is null or r.org_id = users.org_id))
+			on conflict do nothing`, userName, role.ID)
+	if errG != nil {
+		errG = fmt.Errorf("GrantRole insert into user_roles: %v\n", errG)
+		return errG
+	}
+	if tag.RowsAffected() == 0 {
+		//Either the user does not exist, the role belongs to another organization or it is already granted
+		var exists, granted bool
+		if errG = tx.QueryRow(ctx, `select exists(select 1 from users
+					where user_name = $1 and deleted_at is null and org_visible(org_id)),
+				exists(select 1 from user_roles where user_name = $1 and role = $2)`,
+			userName, role.ID).Scan(&exists, &granted); errG != nil {
+			errG = fmt.Errorf("GrantRole select user: %v\n", errG)
+			return errG
+		}
+		if !exists {
+			errG = fmt.Errorf("GrantRole: user %s: %w", userName, ErrNotFound)
+		} else if !granted {
+			errG = fmt.Errorf("GrantRole: role %d belongs to another organization: %w", role.ID, ErrInvalidArgument)
+		}
+		return errG
+	}
+
+	errG = recordAuditEvent(ctx, tx, actor, "role.granted", userName, map[string]interface{}{
+		"role_id": role.ID,
+	})
+	return errG
+}
+
+func (u userService) RevokeRole(ctx context.Context, userName string, roleID int, actor string) error {
+	var errR error
+	conn, err := connectDB(ctx)
+	if err != nil {
+		return fmt.Errorf("RevokeRole. Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("RevokeRole conn.BeginTx %v\n", err)
+	}
+	defer func() {
+		if errR != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	admins, errR := protectAccount(ctx, tx, userName)
+	if isGuardError(errR) {
+		errR = fmt.Errorf("RevokeRole: %w", errR)
+		return errR
+	}
+	if errR != nil {
+		errR = fmt.Errorf("RevokeRole lock administrators: %v\n", errR)
+		return errR
+	}
+
+	var primary bool
+	errR = tx.QueryRow(ctx, `select users.role = $2 from users
+			join user_roles x on x.user_name = users.user_name and x.role = $2
+			where users.user_name = $1 and users.deleted_at is null and org_visible(users.org_id)
+			for update of users`, userName, roleID).Scan(&primary)
+	if errors.Is(errR, pgx.ErrNoRows) {
+		errR = fmt.Errorf("RevokeRole: role %d of user %s: %w", roleID, userName, ErrNotFound)
+		return errR
+	}
+	if errR != nil {
+		errR = fmt.Errorf("RevokeRole select user_roles: %v\n", errR)
+		return errR
+	}
+	if primary {
+		errR = fmt.Errorf("RevokeRole: role %d is the primary role of %s, change it with PUT /user: %w",
+			roleID, userName, ErrInvalidArgument)
+		return errR
+	}
+
+	_, errR = tx.Exec(ctx, `delete from user_roles where user_name = $1 and role = $2`, userName, roleID)
+	if errR != nil {
+		errR = fmt.Errorf("RevokeRole delete from user_roles: %v\n", errR)
+		return errR
+	}
+	if errR = checkAdministrators(ctx, tx, userName, admins); isGuardError(errR) {
+		errR = fmt.Errorf("RevokeRole: %w", errR)
+		return errR
+	}
+	if errR != nil {
+		errR = fmt.Errorf("RevokeRole count administrators: %v\n", errR)
+		return errR
+	}
+
+	errR = recordAuditEvent(ctx, tx, actor, "role.revoked", userName, map[string]interface{}{
+		"role_id": roleID,
+	})
+	return errR
+}
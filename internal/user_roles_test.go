@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+)
+
+// TestMultipleRoles authenticates callers with several roles: they act with the union of the
+// permissions of their roles, and the first role is the primary one.
+func TestMultipleRoles(t *testing.T) {
+	// role 2 is an editor, 4 a reviewer, 5 a group manager
+	useRoleGraph(t, map[int][]string{
+		2: {PermRolesWrite, PermUsersRead},
+		4: {PermUsersRead, PermUsersWrite},
+		5: {PermGroupsManage},
+	})
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"username": "ann", "role": "editor"}).
+		SignedString([]byte(app.GetEnv("SECRET_KEY", "secretkey")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		roles       []app.Role
+		primary     string
+		roleID      int
+		permissions []string
+	}{
+		{"one role", []app.Role{{ID: 2, Role: "editor"}}, "editor", 2, []string{PermRolesWrite, PermUsersRead}},
+		{"two roles", []app.Role{{ID: 2, Role: "editor"}, {ID: 4, Role: "reviewer"}}, "editor", 2,
+			[]string{PermRolesWrite, PermUsersRead, PermUsersWrite}},
+		{"primary role first", []app.Role{{ID: 4, Role: "reviewer"}, {ID: 2, Role: "editor"}, {ID: 5, Role: "manager"}},
+			"reviewer", 4, []string{PermGroupsManage, PermRolesWrite, PermUsersRead, PermUsersWrite}},
+		{"no roles", nil, "", 0, nil},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/user", nil)
+		r.Header.Set("Authorization", "Bearer "+signed)
+		caller, err := authenticateAccount(context.Background(), r, func(context.Context, string) (account, error) {
+			return account{UserName: "ann", OrgID: 1, Roles: tc.roles}, nil
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		want := map[string]bool{}
+		for _, p := range tc.permissions {
+			want[p] = true
+		}
+		if caller.Role() != tc.primary || caller.RoleID != tc.roleID || !reflect.DeepEqual(caller.Permissions, want) {
+			t.Errorf("%s: primary %q %d, permissions %v, want %q %d, %v", tc.name, caller.Role(), caller.RoleID,
+				caller.Permissions, tc.primary, tc.roleID, want)
+		}
+		for _, role := range tc.roles {
+			if !caller.HasRole(role.Role) {
+				t.Errorf("%s: caller does not have role %s", tc.name, role.Role)
+			}
+		}
+	}
+}
+
+func TestPrincipalHasRole(t *testing.T) {
+	caller := principal{Roles: []string{"Editor", "reviewer"}}
+	for _, tc := range []struct {
+		role string
+		has  bool
+	}{
+		{"editor", true},
+		{"REVIEWER", true},
+		{"administrator", false},
+		{"", false},
+	} {
+		if got := caller.HasRole(tc.role); got != tc.has {
+			t.Errorf("HasRole(%q) = %v, want %v", tc.role, got, tc.has)
+		}
+	}
+}
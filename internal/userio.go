@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+)
+
+// File formats of user import and export. Both hold one user per line,
+// CSV with the header userRecordColumns.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+var userRecordColumns = []string{"user_name", "role_name", "roles", "email", "display_name", "status", "locale", "timezone"}
+
+// formatFromMediaType maps Content-Type and Accept values to a file format.
+func formatFromMediaType(mediaType string) (string, bool) {
+	mediaType = strings.TrimSpace(strings.Split(mediaType, ";")[0])
+	switch strings.ToLower(mediaType) {
+	case "text/csv":
+		return FormatCSV, true
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		return FormatNDJSON, true
+	default:
+		return "", false
+	}
+}
+
+// ReadUserRecords parses an import file. Malformed lines fail the whole file,
+// rows are validated one by one by ImportUsers. Errors of r are wrapped.
+func ReadUserRecords(r io.Reader, format string) ([]app.UserRecord, error) {
+	switch format {
+	case FormatCSV:
+		return readUserCSV(r)
+	case FormatNDJSON:
+		return readUserNDJSON(r)
+	default:
+		return nil, fmt.Errorf("format %q: %w", format, ErrInvalidArgument)
+	}
+}
+
+func readUserCSV(r io.Reader) ([]app.UserRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w: %w", err, ErrInvalidArgument)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := index["user_name"]; !ok {
+		return nil, fmt.Errorf("csv header has no user_name column: %w", ErrInvalidArgument)
+	}
+
+	var records []app.UserRecord
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w: %w", err, ErrInvalidArgument)
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		record := app.UserRecord{
+			Line:        line,
+			UserName:    field("user_name"),
+			Role:        field("role_name"),
+			Email:       field("email"),
+			DisplayName: field("display_name"),
+			Status:      field("status"),
+			Locale:      field("locale"),
+			Timezone:    field("timezone"),
+		}
+		for _, role := range strings.Split(field("roles"), ";") {
+			if role = strings.TrimSpace(role); role != "" {
+				record.Roles = append(record.Roles, role)
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+func readUserNDJSON(r io.Reader) ([]app.UserRecord, error) {
+	var records []app.UserRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record app.UserRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			//A failing read ends the last line early
+			if errR := scanner.Err(); errR != nil {
+				return nil, fmt.Errorf("ndjson: %w: %w", errR, ErrInvalidArgument)
+			}
+			return nil, fmt.Errorf("line %d: %v: %w", line, err, ErrInvalidArgument)
+		}
+		record.Line = line
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ndjson: %w: %w", err, ErrInvalidArgument)
+	}
+	return records, nil
+}
+
+// userRecordWriter writes export files row by row.
+type userRecordWriter struct {
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+func newUserRecordWriter(w io.Writer, format string) (*userRecordWriter, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(userRecordColumns); err != nil {
+			return nil, err
+		}
+		return &userRecordWriter{format: format, csv: cw}, nil
+	case FormatNDJSON:
+		return &userRecordWriter{format: format, json: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("format %q: %w", format, ErrInvalidArgument)
+	}
+}
+
+func (w *userRecordWriter) Write(record app.UserRecord) error {
+	if w.json != nil {
+		return w.json.Encode(record)
+	}
+	return w.csv.Write([]string{record.UserName, record.Role, strings.Join(record.Roles, ";"), record.Email,
+		record.DisplayName, record.Status, record.Locale, record.Timezone})
+}
+
+// Flush writes buffered CSV rows, NDJSON is not buffered.
+func (w *userRecordWriter) Flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// WriteUserRecords writes an export file.
+func WriteUserRecords(w io.Writer, format string, records []app.UserRecord) error {
+	rw, err := newUserRecordWriter(w, format)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err = rw.Write(record); err != nil {
+			return err
+		}
+	}
+	return rw.Flush()
+}
+
+// userRecordOf converts a user of the API into an export row.
+func userRecordOf(user app.User) app.UserRecord {
+	record := app.UserRecord{
+		UserName:    user.Name,
+		Role:        user.Role,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		Status:      user.Status,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+	}
+	for _, role := range user.Roles {
+		if role.ID != user.RoleID {
+			record.Roles = append(record.Roles, role.Role)
+		}
+	}
+	return record
+}
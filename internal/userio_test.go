@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+	"testing"
+)
+
+func TestReadUserCSV(t *testing.T) {
+	for _, tc := range []struct {
+		name, file string
+		records    []app.UserRecord
+		err        error
+	}{
+		{name: "empty file"},
+		{
+			name: "columns in any order and case",
+			file: "Role_Name, USER_NAME ,roles,email\nadmin, alice ,viewer; editor ;,alice@example.com\nviewer,bob\n",
+			records: []app.UserRecord{
+				{Line: 2, UserName: "alice", Role: "admin", Roles: []string{"viewer", "editor"}, Email: "alice@example.com"},
+				{Line: 3, UserName: "bob", Role: "viewer"},
+			},
+		},
+		{
+			name:    "quoted field over two lines",
+			file:    "user_name,display_name\ncarol,\"Carol\nSmith\"\ndave,Dave\n",
+			records: []app.UserRecord{{Line: 2, UserName: "carol", DisplayName: "Carol\nSmith"}, {Line: 4, UserName: "dave", DisplayName: "Dave"}},
+		},
+		{name: "no user_name column", file: "name,role_name\nalice,admin\n", err: ErrInvalidArgument},
+		{name: "bare quote", file: "user_name\nal\"ice\n", err: ErrInvalidArgument},
+	} {
+		records, err := readUserCSV(strings.NewReader(tc.file))
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if !reflect.DeepEqual(records, tc.records) {
+			t.Errorf("%s: records %+v, want %+v", tc.name, records, tc.records)
+		}
+	}
+}
+
+func TestReadUserNDJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name, file string
+		records    []app.UserRecord
+		err        error
+	}{
+		{name: "empty file"},
+		{
+			name: "blank lines keep the line numbers",
+			file: "{\"user_name\":\"alice\",\"role_name\":\"admin\",\"roles\":[\"viewer\"]}\n\n  \n{\"user_name\":\"bob\"}",
+			records: []app.UserRecord{
+				{Line: 1, UserName: "alice", Role: "admin", Roles: []string{"viewer"}},
+				{Line: 4, UserName: "bob"},
+			},
+		},
+		{name: "malformed line", file: "{\"user_name\":\"alice\"}\n{\"user_name\":", err: ErrInvalidArgument},
+		{name: "line over 1 MiB", file: "{\"user_name\":\"" + strings.Repeat("a", 1<<20) + "\"}\n", err: ErrInvalidArgument},
+	} {
+		records, err := readUserNDJSON(strings.NewReader(tc.file))
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if !reflect.DeepEqual(records, tc.records) {
+			t.Errorf("%s: records %+v, want %+v", tc.name, records, tc.records)
+		}
+	}
+}
+
+// TestReadUserRecordsTooLarge checks that a body cut by http.MaxBytesReader is reported as such
+// and not as a malformed file.
+func TestReadUserRecordsTooLarge(t *testing.T) {
+	for format, body := range map[string]string{
+		FormatCSV:    "user_name\n" + strings.Repeat("alice\n", 100),
+		FormatNDJSON: strings.Repeat("{\"user_name\":\"alice\"}\n", 100),
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/v1/users/import", strings.NewReader(body))
+		_, err := ReadUserRecords(http.MaxBytesReader(nil, r.Body, 100), format)
+		var tooLarge *http.MaxBytesError
+		if !errors.As(err, &tooLarge) {
+			t.Errorf("%s: error %v, want *http.MaxBytesError", format, err)
+		}
+	}
+}
+
+func TestWriteUserRecordsRoundTrip(t *testing.T) {
+	records := []app.UserRecord{
+		{Line: 2, UserName: "alice", Role: "admin", Roles: []string{"viewer", "editor"}, Email: "alice@example.com",
+			DisplayName: "Alice, \"Al\"", Status: "active", Locale: "de-DE", Timezone: "Europe/Berlin"},
+		{Line: 3, UserName: "bob", Role: "viewer"},
+	}
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		var buf bytes.Buffer
+		if err := WriteUserRecords(&buf, format, records); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		read, err := ReadUserRecords(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		want := records
+		if format == FormatNDJSON {
+			want = []app.UserRecord{records[0], records[1]}
+			want[0].Line, want[1].Line = 1, 2
+		}
+		if !reflect.DeepEqual(read, want) {
+			t.Errorf("%s: read %+v, want %+v", format, read, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"regexp"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+	"unicode/utf8"
+)
+
+// normalizeUserName is the form user names are stored and looked up in: trimmed and in
+// Unicode NFC, so that the same name typed on different systems is the same user.
+// Names are compared without regard to case by the database.
+func normalizeUserName(name string) string {
+	return norm.NFC.String(strings.TrimSpace(name))
+}
+
+// checkUserName normalizes a name for a new or renamed user and applies the USERNAME_* rules.
+// Violations are reported for field.
+func checkUserName(field, name string) (string, error) {
+	name = normalizeUserName(name)
+	var invalid ValidationError
+
+	minLength, maxLength := app.GetEnvAsInt("USERNAME_MIN_LENGTH", 3), app.GetEnvAsInt("USERNAME_MAX_LENGTH", 64)
+	switch n := utf8.RuneCountInString(name); {
+	case n == 0:
+		invalid.Add(field, "is required")
+	case n < minLength:
+		invalid.Add(field, fmt.Sprintf("must be at least %d characters", minLength))
+	case n > maxLength:
+		invalid.Add(field, fmt.Sprintf("must be at most %d characters", maxLength))
+	}
+
+	pattern, err := regexp.Compile(app.GetEnv("USERNAME_PATTERN", `^[\p{L}\p{N}][\p{L}\p{N}._@-]*$`))
+	if err != nil {
+		return "", fmt.Errorf("USERNAME_PATTERN: %v\n", err)
+	}
+	if name != "" && !pattern.MatchString(name) {
+		invalid.Add(field, "contains characters that are not allowed")
+	}
+
+	for _, reserved := range strings.Split(app.GetEnv("USERNAME_RESERVED", "admin,root,system"), ",") {
+		if reserved = strings.TrimSpace(reserved); reserved != "" && strings.EqualFold(name, reserved) {
+			invalid.Add(field, "is reserved")
+		}
+	}
+	return name, invalid.Err()
+}
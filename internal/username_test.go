@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckUserName(t *testing.T) {
+	for _, tc := range []struct {
+		name, userName string
+		env            map[string]string
+		want           string
+		reasons        []string
+	}{
+		{name: "valid", userName: "alice.smith@example", want: "alice.smith@example"},
+		{name: "trimmed", userName: "  bob  ", want: "bob"},
+		{name: "NFC", userName: "josé", want: "josé"},
+		{name: "letters of any script", userName: "Łukasz_東京", want: "Łukasz_東京"},
+		{name: "empty", userName: "   ", reasons: []string{"is required"}},
+		{name: "too short", userName: "al", reasons: []string{"must be at least 3 characters"}},
+		{name: "short counted in characters", userName: "żółw", want: "żółw",
+			env: map[string]string{"USERNAME_MAX_LENGTH": "4"}},
+		{name: "too long", userName: "alice", env: map[string]string{"USERNAME_MAX_LENGTH": "4"},
+			reasons: []string{"must be at most 4 characters"}},
+		{name: "leading punctuation", userName: ".alice", reasons: []string{"contains characters that are not allowed"}},
+		{name: "space inside", userName: "alice smith", reasons: []string{"contains characters that are not allowed"}},
+		{name: "reserved without case", userName: "Root", reasons: []string{"is reserved"}},
+		{name: "own reserved list", userName: "admin", want: "admin",
+			env: map[string]string{"USERNAME_RESERVED": " support , ops"}},
+		{name: "own reserved name", userName: "ops", env: map[string]string{"USERNAME_RESERVED": " support , ops"},
+			reasons: []string{"is reserved"}},
+		{name: "own pattern", userName: "Alice", env: map[string]string{"USERNAME_PATTERN": `^[a-z]+$`},
+			reasons: []string{"contains characters that are not allowed"}},
+		{name: "several violations", userName: "r!", reasons: []string{"must be at least 3 characters",
+			"contains characters that are not allowed"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			name, err := checkUserName("user_name", tc.userName)
+			var invalid *ValidationError
+			if len(tc.reasons) == 0 {
+				if err != nil {
+					t.Fatalf("error %v", err)
+				}
+				if name != tc.want {
+					t.Errorf("name %q, want %q", name, tc.want)
+				}
+				return
+			}
+			if !errors.As(err, &invalid) || !errors.Is(err, ErrValidation) {
+				t.Fatalf("error %v, want a ValidationError", err)
+			}
+			var reasons []string
+			for _, f := range invalid.Fields {
+				if f.Field != "user_name" {
+					t.Errorf("field %q, want user_name", f.Field)
+				}
+				reasons = append(reasons, f.Reason)
+			}
+			if !reflect.DeepEqual(reasons, tc.reasons) {
+				t.Errorf("reasons %v, want %v", reasons, tc.reasons)
+			}
+		})
+	}
+
+	t.Setenv("USERNAME_PATTERN", "[")
+	if _, err := checkUserName("user_name", "alice"); err == nil || errors.Is(err, ErrValidation) {
+		t.Errorf("invalid USERNAME_PATTERN: error %v", err)
+	}
+}
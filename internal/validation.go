@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testgenerate_backend_user/internal/app"
+	"unicode/utf8"
+)
+
+// FieldError is the reason one field of a request is invalid.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError lists every invalid field of a request. It wraps ErrValidation,
+// and encodeError answers it with 422 and the fields.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		reasons = append(reasons, f.Field+" "+f.Reason)
+	}
+	return "invalid " + strings.Join(reasons, ", ")
+}
+
+func (e *ValidationError) Unwrap() error { return ErrValidation }
+
+// Add records that field is invalid for reason.
+func (e *ValidationError) Add(field, reason string) {
+	e.Fields = append(e.Fields, FieldError{field, reason})
+}
+
+// Err returns e if a field was added, nil otherwise.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// maxBodyBytes caps the JSON bodies of requests.
+func maxBodyBytes() int64 {
+	return int64(app.GetEnvAsInt("MAX_BODY_BYTES", 1<<20))
+}
+
+// maxImportBytes caps the files of POST /users/import.
+func maxImportBytes() int64 {
+	return int64(app.GetEnvAsInt("IMPORT_MAX_BYTES", 16<<20))
+}
+
+// maxRequestBytes is the limit of middlewares that read the body ahead of the decoder:
+// import files may reach IMPORT_MAX_BYTES, other bodies MAX_BODY_BYTES.
+func maxRequestBytes(r *http.Request) int64 {
+	if _, ok := formatFromMediaType(r.Header.Get("Content-Type")); ok {
+		return maxImportBytes()
+	}
+	return maxBodyBytes()
+}
+
+// errEmptyBody is returned by decodeJSON for requests without a body.
+var errEmptyBody = fmt.Errorf("body is required: %w", ErrInvalidArgument)
+
+// decodeJSON is how decoders read JSON bodies. The body has to be a single JSON value of at most
+// MAX_BODY_BYTES without unknown fields. The result is checked with validateStruct.
+// Malformed bodies are answered with 400, fields of the wrong type or failing a rule with 422.
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodyBytes()))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return decodeError(err)
+		}
+		return fmt.Errorf("body holds more than one JSON value: %w", ErrInvalidArgument)
+	}
+	return validateStruct(v)
+}
+
+// readBody reads a body that is parsed later, limited like decodeJSON.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyBytes()))
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	return body, nil
+}
+
+// decodeError explains why a body could not be decoded.
+func decodeError(err error) error {
+	var (
+		syntax   *json.SyntaxError
+		typ      *json.UnmarshalTypeError
+		tooLarge *http.MaxBytesError
+		invalid  ValidationError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return errEmptyBody
+	case errors.As(err, &tooLarge):
+		return fmt.Errorf("body is larger than %d bytes: %w", tooLarge.Limit, ErrRequestTooLarge)
+	case errors.As(err, &syntax):
+		return fmt.Errorf("body is not valid JSON at offset %d: %w", syntax.Offset, ErrInvalidArgument)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("body ends within a JSON value: %w", ErrInvalidArgument)
+	case errors.As(err, &typ):
+		field := typ.Field
+		if field == "" {
+			field = "body"
+		}
+		invalid.Add(field, "must be "+jsonTypeName(typ.Type))
+		return &invalid
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		invalid.Add(strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`), "is not allowed")
+		return &invalid
+	}
+	return fmt.Errorf("body: %v: %w", err, ErrInvalidArgument)
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// validateStruct applies the `validate` tags of the struct v points to and of the structs it holds.
+// Rules are separated by commas: required, min=n and max=n for the length of strings and slices
+// or the value of numbers, and oneof=a b c for strings, which may still be empty unless required.
+// Fields are reported by their JSON names, for example operations[2].user.user_name.
+func validateStruct(v interface{}) error {
+	var invalid ValidationError
+	validateValue(reflect.ValueOf(v), "", &invalid)
+	return invalid.Err()
+}
+
+func validateValue(v reflect.Value, path string, invalid *ValidationError) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if f.Anonymous && name == "" {
+				validateValue(v.Field(i), path, invalid)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if rules := f.Tag.Get("validate"); rules != "" {
+				checkRules(v.Field(i), name, rules, invalid)
+			}
+			validateValue(v.Field(i), name, invalid)
+		}
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Pointer:
+			for i := 0; i < v.Len(); i++ {
+				validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), invalid)
+			}
+		}
+	}
+}
+
+func checkRules(v reflect.Value, field, rules string, invalid *ValidationError) {
+	for _, rule := range strings.Split(rules, ",") {
+		key, arg, _ := strings.Cut(rule, "=")
+		n, _ := strconv.Atoi(arg)
+		switch key {
+		case "required":
+			if v.IsZero() {
+				invalid.Add(field, "is required")
+				return
+			}
+		case "min", "max":
+			size, unit := measure(v)
+			if key == "min" && size < int64(n) {
+				invalid.Add(field, fmt.Sprintf("must be at least %d%s", n, unit))
+			}
+			if key == "max" && size > int64(n) {
+				invalid.Add(field, fmt.Sprintf("must be at most %d%s", n, unit))
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			if s := v.String(); s != "" && !contains(allowed, s) {
+				invalid.Add(field, "must be one of "+strings.Join(allowed, ", "))
+			}
+		}
+	}
+}
+
+// measure returns what min and max compare: the characters of strings, the entries of slices
+// and the value of numbers.
+func measure(v reflect.Value) (int64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return int64(v.Len()), " entries"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), ""
+	}
+	return 0, ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
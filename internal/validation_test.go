@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validationItem struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type validationRequest struct {
+	Name   string           `json:"user_name" validate:"required,min=3"`
+	Status string           `json:"status" validate:"oneof=active disabled"`
+	Count  int              `json:"count" validate:"max=10"`
+	Tags   []string         `json:"tags" validate:"max=2"`
+	Items  []validationItem `json:"items"`
+	Skip   string           `json:"-"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name, body string
+		status     int
+		fields     []FieldError
+	}{
+		{name: "valid", body: `{"user_name":"alice","status":"active","count":3,"tags":["a"],"items":[{"name":"x"}]}`},
+		{name: "trailing whitespace", body: "{\"user_name\":\"alice\"}\n\t "},
+		{name: "empty body", body: "", status: http.StatusBadRequest},
+		{name: "malformed", body: `{"user_name":}`, status: http.StatusBadRequest},
+		{name: "cut off", body: `{"user_name":"alice"`, status: http.StatusBadRequest},
+		{name: "two values", body: `{"user_name":"alice"} {}`, status: http.StatusBadRequest},
+		{name: "unknown field", body: `{"user_name":"alice","password":"x"}`, status: http.StatusUnprocessableEntity,
+			fields: []FieldError{{"password", "is not allowed"}}},
+		{name: "wrong type", body: `{"user_name":"alice","count":"3"}`, status: http.StatusUnprocessableEntity,
+			fields: []FieldError{{"count", "must be an integer"}}},
+		{name: "not an object", body: `[]`, status: http.StatusUnprocessableEntity,
+			fields: []FieldError{{"body", "must be an object"}}},
+		{name: "rules", body: `{"user_name":"al","status":"deleted","count":11,"tags":["a","b","c"],"items":[{"name":"x"},{}]}`,
+			status: http.StatusUnprocessableEntity, fields: []FieldError{
+				{"user_name", "must be at least 3 characters"},
+				{"status", "must be one of active, disabled"},
+				{"count", "must be at most 10"},
+				{"tags", "must be at most 2 entries"},
+				{"items[1].name", "is required"},
+			}},
+		{name: "required", body: `{}`, status: http.StatusUnprocessableEntity, fields: []FieldError{{"user_name", "is required"}}},
+		{name: "too large", body: `{"user_name":"` + strings.Repeat("a", 1<<20) + `"}`, status: http.StatusRequestEntityTooLarge},
+		{name: "too large after the value", body: `{"user_name":"alice"}` + strings.Repeat(" ", 1<<20),
+			status: http.StatusRequestEntityTooLarge},
+	} {
+		var req validationRequest
+		err := decodeJSON(httptest.NewRequest(http.MethodPost, "/v1/user", strings.NewReader(tc.body)), &req)
+		if tc.status == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || codeFrom(err) != tc.status {
+			t.Errorf("%s: error %v, want status %d", tc.name, err, tc.status)
+			continue
+		}
+		var invalid *ValidationError
+		if errors.As(err, &invalid) != (tc.fields != nil) {
+			t.Errorf("%s: error %v, want fields %v", tc.name, err, tc.fields)
+			continue
+		}
+		if invalid != nil && !reflect.DeepEqual(invalid.Fields, tc.fields) {
+			t.Errorf("%s: fields %v, want %v", tc.name, invalid.Fields, tc.fields)
+		}
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	var invalid ValidationError
+	if err := invalid.Err(); err != nil {
+		t.Errorf("Err without fields = %v, want nil", err)
+	}
+	invalid.Add("user_name", "is required")
+	invalid.Add("users[1].email", "must be at most 255 characters")
+	err := invalid.Err()
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("%v does not wrap ErrValidation", err)
+	}
+	if want := "invalid user_name is required, users[1].email must be at most 255 characters"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if codeFrom(err) != http.StatusUnprocessableEntity {
+		t.Errorf("status %d, want 422", codeFrom(err))
+	}
+}
@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// RunPeriodic calls job every interval until ctx is cancelled.
+func RunPeriodic(ctx context.Context, interval time.Duration, job func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+create table if not exists user_invitation
+(
+    id          serial primary key,
+    user_name   varchar(255),
+    email       varchar(255),
+    role        integer      not null references user_role (id),
+    created_by  varchar(255) not null,
+    create_time timestamptz  not null default now(),
+    expires_at  timestamptz  not null,
+    redeemed_at timestamptz,
+    redeemed_by varchar(255),
+    revoked_at  timestamptz,
+    constraint user_invitation_target check (user_name is not null or email is not null)
+);
+
+create index if not exists user_invitation_expires_at on user_invitation (expires_at) where redeemed_at is null;
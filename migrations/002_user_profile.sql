@@ -0,0 +1,12 @@
+alter table users
+    add column if not exists email         varchar(255),
+    add column if not exists display_name  varchar(255),
+    add column if not exists status        varchar(16) not null default 'active',
+    add column if not exists last_login_at timestamptz,
+    add column if not exists locale        varchar(35),
+    add column if not exists timezone      varchar(64);
+
+alter table users
+    add constraint users_status check (status in ('active', 'disabled', 'locked'));
+
+create unique index if not exists users_email_unique on users (lower(email));
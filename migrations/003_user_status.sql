@@ -0,0 +1,7 @@
+alter table users
+    add column if not exists status_reason     text,
+    add column if not exists locked_until      timestamptz,
+    add column if not exists status_changed_at timestamptz,
+    add column if not exists status_changed_by varchar(255);
+
+create index if not exists users_locked_until on users (locked_until) where status = 'locked';
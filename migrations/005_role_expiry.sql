@@ -0,0 +1,17 @@
+alter table users
+    add column if not exists role_expires_at timestamptz,
+    add column if not exists fallback_role   integer references user_role (id);
+
+create index if not exists users_role_expires_at on users (role_expires_at) where role_expires_at is not null;
+
+create table if not exists audit_event
+(
+    id          bigserial primary key,
+    create_time timestamptz  not null default now(),
+    actor       varchar(255) not null,
+    action      varchar(64)  not null,
+    user_name   varchar(255),
+    details     jsonb
+);
+
+create index if not exists audit_event_user_name on audit_event (user_name, create_time);
@@ -0,0 +1,32 @@
+create table if not exists user_roles
+(
+    user_name   varchar(255) not null references users (user_name) on delete cascade,
+    role        integer      not null references user_role (id),
+    create_time timestamptz  not null default now(),
+    primary key (user_name, role)
+);
+
+insert into user_roles(user_name, role)
+select user_name, role from users where role is not null
+on conflict do nothing;
+
+-- users.role stays the primary role and is always one of the user's roles:
+-- changing it replaces the old primary role in user_roles.
+create or replace function users_primary_role() returns trigger as
+$$
+begin
+    if tg_op = 'UPDATE' and old.role is distinct from new.role then
+        delete from user_roles where user_name = new.user_name and role = old.role;
+    end if;
+    if new.role is not null then
+        insert into user_roles(user_name, role) values (new.user_name, new.role) on conflict do nothing;
+    end if;
+    return new;
+end
+$$ language plpgsql;
+
+drop trigger if exists users_primary_role on users;
+create trigger users_primary_role
+    after insert or update of role on users
+    for each row
+execute function users_primary_role();
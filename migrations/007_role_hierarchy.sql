@@ -0,0 +1,23 @@
+create table if not exists role_permission
+(
+    role       integer     not null references user_role (id) on delete cascade,
+    permission varchar(64) not null,
+    primary key (role, permission)
+);
+
+-- a role inherits every permission of its parents
+create table if not exists role_parent
+(
+    role   integer not null references user_role (id) on delete cascade,
+    parent integer not null references user_role (id) on delete cascade,
+    primary key (role, parent),
+    constraint role_parent_self check (role <> parent)
+);
+
+-- administrators keep every right they had before permissions were introduced
+insert into role_permission(role, permission)
+select user_role.id, p.permission
+from user_role,
+     unnest(array ['users.read', 'users.write', 'roles.read', 'roles.write', 'invitations.manage']) as p(permission)
+where lower(user_role.role_name) = 'administrator'
+on conflict do nothing;
@@ -0,0 +1,48 @@
+create table if not exists user_group
+(
+    id          serial primary key,
+    name        varchar(255) not null unique,
+    description text,
+    -- members of a group also get the roles of its parent groups
+    parent      integer references user_group (id) on delete set null,
+    create_time timestamptz  not null default now(),
+    constraint user_group_parent_self check (parent <> id)
+);
+
+create table if not exists group_member
+(
+    group_id    integer      not null references user_group (id) on delete cascade,
+    user_name   varchar(255) not null references users (user_name) on delete cascade,
+    create_time timestamptz  not null default now(),
+    primary key (group_id, user_name)
+);
+
+create index if not exists group_member_user_name on group_member (user_name);
+
+create table if not exists group_role
+(
+    group_id    integer     not null references user_group (id) on delete cascade,
+    role        integer     not null references user_role (id),
+    create_time timestamptz not null default now(),
+    primary key (group_id, role)
+);
+
+-- every group a user belongs to, directly or through nested groups
+create or replace view user_effective_group as
+with recursive g(user_name, group_id) as (
+    select user_name, group_id
+    from group_member
+    union
+    select g.user_name, ug.parent
+    from g
+             join user_group ug on ug.id = g.group_id
+    where ug.parent is not null
+)
+select user_name, group_id
+from g;
+
+insert into role_permission(role, permission)
+select id, 'groups.manage'
+from user_role
+where lower(role_name) = 'administrator'
+on conflict do nothing;
@@ -0,0 +1,145 @@
+create table if not exists organization
+(
+    id          serial primary key,
+    name        varchar(255) not null unique,
+    create_time timestamptz  not null default now()
+);
+
+insert into organization(id, name)
+values (1, 'default')
+on conflict do nothing;
+select setval('organization_id_seq', greatest((select max(id) from organization), 1));
+
+-- The service sets app.tenant_id on every connection: an organization id, or 'all' for
+-- platform superadmins and background jobs. Without it no tenant row is visible.
+create or replace function current_tenant() returns text as
+$$
+select coalesce(current_setting('app.tenant_id', true), '')
+$$ language sql stable;
+
+create or replace function org_visible(org integer) returns boolean as
+$$
+select current_tenant() = 'all' or (current_tenant() <> '' and org = current_tenant()::integer)
+$$ language sql stable;
+
+-- organization of rows created without an explicit one, the default organization for 'all'
+create or replace function current_org() returns integer as
+$$
+select case current_tenant() when '' then null when 'all' then 1 else current_tenant()::integer end
+$$ language sql stable;
+
+-- User names stay unique across organizations as they are the key of users.
+alter table users
+    add column if not exists org_id integer not null default 1 references organization (id);
+alter table users
+    alter column org_id set default current_org();
+create index if not exists users_org_id on users (org_id);
+
+-- Roles without an organization are global and shared by every organization.
+alter table user_role
+    add column if not exists org_id integer references organization (id);
+create unique index if not exists user_role_org_name on user_role (coalesce(org_id, 0), lower(role_name));
+
+alter table user_group
+    add column if not exists org_id integer not null default 1 references organization (id);
+alter table user_group
+    alter column org_id set default current_org();
+alter table user_group
+    drop constraint if exists user_group_name_key;
+create unique index if not exists user_group_org_name on user_group (org_id, lower(name));
+
+alter table user_invitation
+    add column if not exists org_id integer not null default 1 references organization (id);
+alter table user_invitation
+    alter column org_id set default current_org();
+
+alter table audit_event
+    add column if not exists org_id integer references organization (id);
+alter table audit_event
+    alter column org_id set default current_org();
+
+-- Row-level security repeats the filters of the service. It does not apply to superusers:
+-- the service has to connect with a role that owns the tables but is not a superuser,
+-- and it refuses to start otherwise.
+alter table users
+    enable row level security;
+alter table users
+    force row level security;
+drop policy if exists tenant_isolation on users;
+create policy tenant_isolation on users using (org_visible(org_id)) with check (org_visible(org_id));
+
+alter table user_role
+    enable row level security;
+alter table user_role
+    force row level security;
+drop policy if exists tenant_isolation on user_role;
+create policy tenant_isolation on user_role using (org_id is null or org_visible(org_id)) with check (org_visible(org_id));
+
+alter table user_group
+    enable row level security;
+alter table user_group
+    force row level security;
+drop policy if exists tenant_isolation on user_group;
+create policy tenant_isolation on user_group using (org_visible(org_id)) with check (org_visible(org_id));
+
+alter table user_invitation
+    enable row level security;
+alter table user_invitation
+    force row level security;
+drop policy if exists tenant_isolation on user_invitation;
+create policy tenant_isolation on user_invitation using (org_visible(org_id)) with check (org_visible(org_id));
+
+alter table audit_event
+    enable row level security;
+alter table audit_event
+    force row level security;
+drop policy if exists tenant_isolation on audit_event;
+create policy tenant_isolation on audit_event using (org_visible(org_id)) with check (org_visible(org_id));
+
+-- rows of the link tables are visible together with the user, group or role they belong to
+alter table user_roles
+    enable row level security;
+alter table user_roles
+    force row level security;
+drop policy if exists tenant_isolation on user_roles;
+create policy tenant_isolation on user_roles
+    using (exists(select 1 from users u where u.user_name = user_roles.user_name));
+
+alter table group_member
+    enable row level security;
+alter table group_member
+    force row level security;
+drop policy if exists tenant_isolation on group_member;
+create policy tenant_isolation on group_member
+    using (exists(select 1 from user_group g where g.id = group_member.group_id));
+
+alter table group_role
+    enable row level security;
+alter table group_role
+    force row level security;
+drop policy if exists tenant_isolation on group_role;
+create policy tenant_isolation on group_role
+    using (exists(select 1 from user_group g where g.id = group_role.group_id));
+
+alter table role_permission
+    enable row level security;
+alter table role_permission
+    force row level security;
+drop policy if exists tenant_isolation on role_permission;
+create policy tenant_isolation on role_permission
+    using (exists(select 1 from user_role r where r.id = role_permission.role));
+
+alter table role_parent
+    enable row level security;
+alter table role_parent
+    force row level security;
+drop policy if exists tenant_isolation on role_parent;
+create policy tenant_isolation on role_parent
+    using (exists(select 1 from user_role r where r.id = role_parent.role));
+
+-- needs PostgreSQL 15, otherwise the view reads with the rights of its owner
+alter view user_effective_group set (security_invoker = true);
+
+insert into user_role(role_name)
+select 'superadmin'
+where not exists(select 1 from user_role where lower(role_name) = 'superadmin');
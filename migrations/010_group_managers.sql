@@ -0,0 +1,19 @@
+-- Managers of a group administer its members and the members of its subgroups
+-- without holding users.read or users.write themselves.
+create table if not exists group_manager
+(
+    group_id    integer      not null references user_group (id) on delete cascade,
+    user_name   varchar(255) not null references users (user_name) on delete cascade,
+    create_time timestamptz  not null default now(),
+    primary key (group_id, user_name)
+);
+
+create index if not exists group_manager_user_name on group_manager (user_name);
+
+alter table group_manager
+    enable row level security;
+alter table group_manager
+    force row level security;
+drop policy if exists tenant_isolation on group_manager;
+create policy tenant_isolation on group_manager
+    using (exists(select 1 from user_group g where g.id = group_manager.group_id));
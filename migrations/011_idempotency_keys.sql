@@ -0,0 +1,17 @@
+-- Responses of mutating requests sent with an Idempotency-Key, replayed when the request is
+-- repeated. scope holds the organization and the user the key belongs to. status is null
+-- while the first request is running.
+create table if not exists idempotency_key
+(
+    scope       varchar(255) not null,
+    key         varchar(255) not null,
+    fingerprint varchar(64)  not null,
+    status      integer,
+    header      jsonb,
+    body        bytea,
+    create_time timestamptz  not null default now(),
+    expires_at  timestamptz  not null,
+    primary key (scope, key)
+);
+
+create index if not exists idempotency_key_expires_at on idempotency_key (expires_at);
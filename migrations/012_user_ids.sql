@@ -0,0 +1,55 @@
+-- Every user gets a stable id. user_name stays unique but can change: references follow
+-- a rename, and the old name stays resolvable as an alias for a grace period.
+alter table users
+    add column if not exists id uuid not null default gen_random_uuid();
+create unique index if not exists users_id on users (id);
+
+alter table user_roles
+    drop constraint if exists user_roles_user_name_fkey,
+    add constraint user_roles_user_name_fkey foreign key (user_name)
+        references users (user_name) on delete cascade on update cascade;
+alter table group_member
+    drop constraint if exists group_member_user_name_fkey,
+    add constraint group_member_user_name_fkey foreign key (user_name)
+        references users (user_name) on delete cascade on update cascade;
+alter table group_manager
+    drop constraint if exists group_manager_user_name_fkey,
+    add constraint group_manager_user_name_fkey foreign key (user_name)
+        references users (user_name) on delete cascade on update cascade;
+
+-- audit events keep the name at the time of the event and find the user by id after renames
+alter table audit_event
+    add column if not exists user_id uuid;
+update audit_event e
+set user_id = u.id
+from users u
+where u.user_name = e.user_name
+  and e.user_id is null;
+create index if not exists audit_event_user_id on audit_event (user_id, create_time);
+
+create table if not exists user_alias
+(
+    alias       varchar(255) primary key,
+    user_id     uuid        not null references users (id) on delete cascade,
+    create_time timestamptz not null default now(),
+    expires_at  timestamptz not null
+);
+
+create index if not exists user_alias_user_id on user_alias (user_id);
+
+alter table user_alias
+    enable row level security;
+alter table user_alias
+    force row level security;
+drop policy if exists tenant_isolation on user_alias;
+create policy tenant_isolation on user_alias
+    using (exists(select 1 from users u where u.id = user_alias.user_id));
+
+-- the current name of a user, following the alias of a renamed user; unknown names are returned as they are
+create or replace function resolve_user_name(name varchar) returns varchar as
+$$
+select coalesce((select u.user_name from users u where u.user_name = name),
+                (select u.user_name from user_alias a join users u on u.id = a.user_id
+                 where a.alias = name and a.expires_at > now()),
+                name)
+$$ language sql stable;
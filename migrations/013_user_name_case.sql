@@ -0,0 +1,11 @@
+-- User names are unique regardless of case and resolve without regard to case.
+-- Names that differ in case only have to be renamed before this migration.
+create unique index if not exists users_user_name_lower on users (lower(user_name));
+
+create or replace function resolve_user_name(name varchar) returns varchar as
+$$
+select coalesce((select u.user_name from users u where lower(u.user_name) = lower(name)),
+                (select u.user_name from user_alias a join users u on u.id = a.user_id
+                 where lower(a.alias) = lower(name) and a.expires_at > now()),
+                name)
+$$ language sql stable;